@@ -3,6 +3,7 @@ module github.com/lestrrat-go/jwx
 go 1.15
 
 require (
+	github.com/cloudflare/circl v1.1.0
 	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.0.0-20210816181553-5444fa50b93d
 	github.com/goccy/go-json v0.7.8
 	github.com/lestrrat-go/backoff/v2 v2.0.8
@@ -13,7 +14,7 @@ require (
 	github.com/lestrrat-go/option v1.0.0
 	github.com/pkg/errors v0.9.1
 	github.com/stretchr/testify v1.7.0
-	golang.org/x/crypto v0.0.0-20201217014255-9d1352758620
+	golang.org/x/crypto v0.0.0-20210921155107-089bfa567519
 	golang.org/x/mod v0.4.1 // indirect
 	golang.org/x/tools v0.0.0-20210114065538-d78b04bdf963 // indirect
 )
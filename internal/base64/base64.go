@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/binary"
+	"io"
 
 	"github.com/pkg/errors"
 )
@@ -23,6 +24,19 @@ func EncodeToString(src []byte) string {
 	return base64.RawURLEncoding.EncodeToString(src)
 }
 
+// NewEncoder returns a stream encoder that writes unpadded base64url
+// encoded data to `w`. The encoder must be closed to flush any partially
+// written blocks.
+func NewEncoder(w io.Writer) io.WriteCloser {
+	return base64.NewEncoder(base64.RawURLEncoding, w)
+}
+
+// NewDecoder returns a stream decoder that reads unpadded base64url
+// encoded data from `r`
+func NewDecoder(r io.Reader) io.Reader {
+	return base64.NewDecoder(base64.RawURLEncoding, r)
+}
+
 func EncodeUint64ToString(v uint64) string {
 	data := make([]byte, 8)
 	binary.BigEndian.PutUint64(data, v)
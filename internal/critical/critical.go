@@ -0,0 +1,64 @@
+// Package critical implements the processing of the "crit" header field
+// that is shared by the jws and jwe packages (RFC7515 Section 4.1.11,
+// RFC7516 Section 4.1.13)
+package critical
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Headers describes the protected headers that list the critical
+// extensions. Both jws.Headers and jwe.Headers satisfy it.
+type Headers interface {
+	Get(string) (interface{}, bool)
+}
+
+// Check makes sure that `crit`, the value of the "crit" header field
+// in `protected`, is well-formed, and that all of the extensions listed
+// in it are understood.
+//
+// `registered` lists the header names defined by the specifications,
+// which must not be listed in "crit". `understood` reports if an
+// extension can be processed.
+func Check(protected Headers, crit []string, registered map[string]struct{}, understood func(string) bool) error {
+	if len(crit) == 0 {
+		return errors.New(`"crit" must not be empty`)
+	}
+
+	seen := make(map[string]struct{})
+	for _, name := range crit {
+		if _, ok := seen[name]; ok {
+			return errors.Errorf(`duplicate critical extension %q`, name)
+		}
+		seen[name] = struct{}{}
+
+		if _, ok := registered[name]; ok {
+			return errors.Errorf(`"crit" must not contain registered header %q`, name)
+		}
+
+		if _, ok := protected.Get(name); !ok {
+			return errors.Errorf(`critical extension %q is not present in the protected header`, name)
+		}
+
+		if !understood(name) {
+			return errors.Errorf(`unsupported critical extension %q`, name)
+		}
+	}
+	return nil
+}
+
+// Handle calls `handle` for each extension listed in `crit`, except for
+// those listed in `builtin`, which are processed by the caller itself.
+// Check must have been called beforehand.
+func Handle(crit []string, builtin map[string]struct{}, handle func(string) error) error {
+	for _, name := range crit {
+		if _, ok := builtin[name]; ok {
+			continue
+		}
+
+		if err := handle(name); err != nil {
+			return errors.Wrapf(err, `failed to process critical extension %q`, name)
+		}
+	}
+	return nil
+}
@@ -8,19 +8,25 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/lestrrat-go/jwx/internal/ecutil"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jws"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
@@ -140,6 +146,124 @@ func GenerateX25519Jwk() (jwk.Key, error) {
 	return k, nil
 }
 
+func GenerateEd448Key() (ed448.PrivateKey, error) {
+	_, priv, err := ed448.GenerateKey(rand.Reader)
+	return priv, err
+}
+
+func GenerateEd448Jwk() (jwk.Key, error) {
+	key, err := GenerateEd448Key()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to generate Ed448 private key`)
+	}
+
+	k, err := jwk.New(key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to generate jwk.OKPPrivateKey`)
+	}
+
+	return k, nil
+}
+
+func GenerateX448Key() (x448.PrivateKey, error) {
+	_, priv, err := x448.GenerateKey(rand.Reader)
+	return priv, err
+}
+
+func GenerateX448Jwk() (jwk.Key, error) {
+	key, err := GenerateX448Key()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to generate X448 private key`)
+	}
+
+	k, err := jwk.New(key)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to generate jwk.OKPPrivateKey`)
+	}
+
+	return k, nil
+}
+
+// GenerateCertificateChain creates a certificate chain for `pubkey`,
+// which consists of the leaf certificate (valid for `dnsName`) and an
+// intermediate CA certificate, in that order. The root CA certificate
+// that the chain leads to is returned separately.
+//
+// The extended key usages of the leaf certificate default to
+// `x509.ExtKeyUsageServerAuth` unless `extKeyUsages` is given.
+func GenerateCertificateChain(pubkey interface{}, dnsName string, extKeyUsages ...x509.ExtKeyUsage) (*x509.Certificate, []*x509.Certificate, error) {
+	if len(extKeyUsages) == 0 {
+		extKeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+
+	rootKey, err := GenerateEcdsaKey(jwa.P256)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to generate root key`)
+	}
+	intermediateKey, err := GenerateEcdsaKey(jwa.P256)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to generate intermediate key`)
+	}
+
+	now := time.Now()
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "jwxtest root"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	intermediateTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "jwxtest intermediate"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: dnsName},
+		DNSNames:     []string{dnsName},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  extKeyUsages,
+	}
+
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, &rootKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to create root certificate`)
+	}
+	root, err := x509.ParseCertificate(rootDER)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to parse root certificate`)
+	}
+
+	intermediateDER, err := x509.CreateCertificate(rand.Reader, intermediateTemplate, root, &intermediateKey.PublicKey, rootKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to create intermediate certificate`)
+	}
+	intermediate, err := x509.ParseCertificate(intermediateDER)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to parse intermediate certificate`)
+	}
+
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, intermediate, pubkey, intermediateKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to create leaf certificate`)
+	}
+	leaf, err := x509.ParseCertificate(leafDER)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to parse leaf certificate`)
+	}
+
+	return root, []*x509.Certificate{leaf, intermediate}, nil
+}
+
 func WriteFile(template string, src io.Reader) (string, func(), error) {
 	file, cleanup, err := CreateTempFile(template)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"crypto/rsa"
 
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/lestrrat-go/blackmagic"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
@@ -175,3 +176,57 @@ func Ed25519PublicKey(dst, src interface{}) error {
 	}
 	return blackmagic.AssignIfCompatible(dst, ptr)
 }
+
+func Ed448PrivateKey(dst, src interface{}) error {
+	if jwkKey, ok := src.(jwk.Key); ok {
+		var raw ed448.PrivateKey
+		if err := jwkKey.Raw(&raw); err != nil {
+			return errors.Wrapf(err, `failed to produce ed448.PrivateKey from %T`, src)
+		}
+		src = &raw
+	}
+
+	var ptr *ed448.PrivateKey
+	switch src := src.(type) {
+	case ed448.PrivateKey:
+		ptr = &src
+	case *ed448.PrivateKey:
+		ptr = src
+	default:
+		return errors.Errorf(`expected ed448.PrivateKey or *ed448.PrivateKey, got %T`, src)
+	}
+	return blackmagic.AssignIfCompatible(dst, ptr)
+}
+
+func Ed448PublicKey(dst, src interface{}) error {
+	if jwkKey, ok := src.(jwk.Key); ok {
+		var raw ed448.PublicKey
+		if err := jwkKey.Raw(&raw); err != nil {
+			return errors.Wrapf(err, `failed to produce ed448.PublicKey from %T`, src)
+		}
+		src = &raw
+	}
+
+	var ptr *ed448.PublicKey
+	switch src := src.(type) {
+	case ed448.PublicKey:
+		ptr = &src
+	case *ed448.PublicKey:
+		ptr = src
+	case *crypto.PublicKey:
+		tmp, ok := (*src).(ed448.PublicKey)
+		if !ok {
+			return errors.New(`failed to retrieve ed448.PublicKey out of *crypto.PublicKey`)
+		}
+		ptr = &tmp
+	case crypto.PublicKey:
+		tmp, ok := src.(ed448.PublicKey)
+		if !ok {
+			return errors.New(`failed to retrieve ed448.PublicKey out of crypto.PublicKey`)
+		}
+		ptr = &tmp
+	default:
+		return errors.Errorf(`expected ed448.PublicKey or *ed448.PublicKey, got %T`, src)
+	}
+	return blackmagic.AssignIfCompatible(dst, ptr)
+}
@@ -10,6 +10,11 @@ import (
 	"github.com/pkg/errors"
 )
 
+// compressionLevel is the level used to compress plaintext when "zip" is
+// "DEF". It is shared by compress() and the streaming encrypter so that
+// both produce the same output
+const compressionLevel = flate.BestSpeed
+
 func uncompress(plaintext []byte) ([]byte, error) {
 	return ioutil.ReadAll(flate.NewReader(bytes.NewReader(plaintext)))
 }
@@ -22,7 +27,10 @@ func compress(plaintext []byte, alg jwa.CompressionAlgorithm) ([]byte, error) {
 	buf := pool.GetBytesBuffer()
 	defer pool.ReleaseBytesBuffer(buf)
 
-	w, _ := flate.NewWriter(buf, 1)
+	w, err := flate.NewWriter(buf, compressionLevel)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create compression writer`)
+	}
 	in := plaintext
 	for len(in) > 0 {
 		n, err := w.Write(in)
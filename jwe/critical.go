@@ -0,0 +1,163 @@
+package jwe
+
+import (
+	"sync"
+
+	"github.com/lestrrat-go/jwx/internal/critical"
+	"github.com/pkg/errors"
+)
+
+// CriticalHandler processes a header extension that is listed in the
+// "crit" header field (RFC7516 Section 4.1.13).
+//
+// HandleCritical is called with the name of the extension and the
+// protected headers of the message, after the message has been
+// successfully decrypted. If it returns an error, decryption fails.
+type CriticalHandler interface {
+	HandleCritical(string, Headers) error
+}
+
+// CriticalHandlerFunc is a CriticalHandler represented by a function
+type CriticalHandlerFunc func(string, Headers) error
+
+func (f CriticalHandlerFunc) HandleCritical(name string, hdrs Headers) error {
+	return f(name, hdrs)
+}
+
+var muCriticalHandlers sync.RWMutex
+var criticalHandlers = make(map[string]CriticalHandler)
+
+// registeredHeaderNames lists the header names defined in RFC7516
+// and RFC7518, which must not be listed in "crit"
+var registeredHeaderNames = map[string]struct{}{
+	AgreementPartyUInfoKey:    {},
+	AgreementPartyVInfoKey:    {},
+	AlgorithmKey:              {},
+	CompressionKey:            {},
+	ContentEncryptionKey:      {},
+	ContentTypeKey:            {},
+	CriticalKey:               {},
+	EphemeralPublicKeyKey:     {},
+	JWKKey:                    {},
+	JWKSetURLKey:              {},
+	KeyIDKey:                  {},
+	TypeKey:                   {},
+	X509CertChainKey:          {},
+	X509CertThumbprintKey:     {},
+	X509CertThumbprintS256Key: {},
+	X509URLKey:                {},
+	"iv":                      {},
+	"tag":                     {},
+	"p2s":                     {},
+	"p2c":                     {},
+}
+
+// RegisterCriticalHandler registers a handler for the header extension
+// `name`, so that messages which list `name` in their "crit" header field
+// can be decrypted. This option has a global effect. To register a handler
+// for a single call to `jwe.Decrypt()`, use `jwe.WithCriticalHandler()`.
+//
+// Passing a nil handler removes the handler for `name`.
+//
+// Messages which list extensions that do not have a handler fail
+// decryption.
+func RegisterCriticalHandler(name string, h CriticalHandler) {
+	muCriticalHandlers.Lock()
+	defer muCriticalHandlers.Unlock()
+	if h == nil {
+		delete(criticalHandlers, name)
+		return
+	}
+	criticalHandlers[name] = h
+}
+
+func lookupCriticalHandler(name string, local map[string]CriticalHandler) (CriticalHandler, bool) {
+	if h, ok := local[name]; ok && h != nil {
+		return h, true
+	}
+
+	muCriticalHandlers.RLock()
+	defer muCriticalHandlers.RUnlock()
+	h, ok := criticalHandlers[name]
+	return h, ok
+}
+
+// checkCritical makes sure that the "crit" header field is well-formed,
+// and that all of the extensions listed in it are understood.
+func checkCritical(m *Message, local map[string]CriticalHandler) error {
+	if h := m.unprotectedHeaders; h != nil {
+		if _, ok := h.Get(CriticalKey); ok {
+			return errors.New(`"crit" must be in the protected header`)
+		}
+	}
+
+	protected := m.protectedHeaders
+	var crit []string
+	if protected != nil {
+		crit = protected.Critical()
+	}
+
+	for _, r := range m.recipients {
+		h := r.Headers()
+		if h == nil {
+			continue
+		}
+		if _, ok := h.Get(CriticalKey); !ok {
+			continue
+		}
+		// Messages in compact serialization carry a copy of the
+		// protected headers as their recipient headers
+		if !equalCritical(crit, h.Critical()) {
+			return errors.New(`"crit" must be in the protected header`)
+		}
+	}
+
+	if protected == nil {
+		return nil
+	}
+
+	if _, ok := protected.Get(CriticalKey); !ok {
+		return nil
+	}
+
+	return critical.Check(protected, crit, registeredHeaderNames, func(name string) bool {
+		_, ok := lookupCriticalHandler(name, local)
+		return ok
+	})
+}
+
+func equalCritical(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// handleCritical calls the handlers for the extensions listed in the
+// "crit" header field. checkCritical must have been called beforehand.
+func handleCritical(protected Headers, local map[string]CriticalHandler) error {
+	if protected == nil {
+		return nil
+	}
+
+	return critical.Handle(protected.Critical(), nil, func(name string) error {
+		h, ok := lookupCriticalHandler(name, local)
+		if !ok {
+			return errors.New(`unsupported critical extension`)
+		}
+		return h.HandleCritical(name, protected)
+	})
+}
+
+func (p *criticalHandlerPair) addTo(m map[string]CriticalHandler) map[string]CriticalHandler {
+	if m == nil {
+		m = make(map[string]CriticalHandler)
+	}
+	m[p.name] = p.handler
+	return m
+}
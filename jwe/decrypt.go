@@ -17,6 +17,7 @@ import (
 	"github.com/lestrrat-go/jwx/jwe/internal/content_crypt"
 	"github.com/lestrrat-go/jwx/jwe/internal/keyenc"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
 	"github.com/pkg/errors"
 )
 
@@ -279,7 +280,7 @@ func (d *Decrypter) BuildKeyDecrypter() (keyenc.Decrypter, error) {
 		return keyenc.NewAES(alg, sharedkey)
 	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
 		switch d.pubkey.(type) {
-		case x25519.PublicKey:
+		case x25519.PublicKey, x448.PublicKey:
 			return keyenc.NewECDHESDecrypt(alg, d.ctalg, d.pubkey, d.apu, d.apv, d.privkey), nil
 		default:
 			var pubkey ecdsa.PublicKey
@@ -4,7 +4,6 @@ import (
 	"context"
 	"sync"
 
-	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
 )
@@ -24,15 +23,74 @@ func releaseEncryptCtx(ctx *encryptCtx) {
 	ctx.contentEncrypter = nil
 	ctx.generator = nil
 	ctx.keyEncrypters = nil
+	ctx.recipientHeaders = nil
 	ctx.compress = jwa.NoCompress
 	encryptCtxPool.Put(ctx)
 }
 
 // Encrypt takes the plaintext and encrypts into a JWE message.
 func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
+	cek, recipients, err := e.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	// If there's only one recipient, you want to include that in the
+	// protected header
+	if len(recipients) == 1 {
+		h, err := e.protected.Merge(context.TODO(), recipients[0].Headers())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to merge protected headers")
+		}
+		e.protected = h
+	}
+
+	aad, err := e.protected.Encode()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to base64 encode protected headers")
+	}
+
+	plaintext, err = compress(plaintext, e.compress)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to compress payload before encryption`)
+	}
+
+	// ...on the other hand, there's only one content cipher.
+	iv, ciphertext, tag, err := e.contentEncrypter.Encrypt(cek, plaintext, aad)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt payload")
+	}
+
+	msg := NewMessage()
+
+	// The protected header is already part of the AAD used for
+	// encryption. Do not store it in the "aad" field.
+	if err := msg.Set(CipherTextKey, ciphertext); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, CipherTextKey)
+	}
+	if err := msg.Set(InitializationVectorKey, iv); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, InitializationVectorKey)
+	}
+	if err := msg.Set(ProtectedHeadersKey, e.protected); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, ProtectedHeadersKey)
+	}
+	if err := msg.Set(RecipientsKey, recipients); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, RecipientsKey)
+	}
+	if err := msg.Set(TagKey, tag); err != nil {
+		return nil, errors.Wrapf(err, `failed to set %s`, TagKey)
+	}
+
+	return msg, nil
+}
+
+// prepare generates the content encryption key, populates the "enc" and
+// "zip" fields in the protected header, and creates the recipients
+// holding the encrypted versions of the content encryption key.
+func (e *encryptCtx) prepare() ([]byte, []Recipient, error) {
 	bk, err := e.generator.Generate()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to generate key")
+		return nil, nil, errors.Wrap(err, "failed to generate key")
 	}
 	cek := bk.Bytes()
 
@@ -42,13 +100,13 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 	}
 
 	if err := e.protected.Set(ContentEncryptionKey, e.contentEncrypter.Algorithm()); err != nil {
-		return nil, errors.Wrap(err, `failed to set "enc" in protected header`)
+		return nil, nil, errors.Wrap(err, `failed to set "enc" in protected header`)
 	}
 
 	compression := e.compress
 	if compression != jwa.NoCompress {
 		if err := e.protected.Set(CompressionKey, compression); err != nil {
-			return nil, errors.Wrap(err, `failed to set "zip" in protected header`)
+			return nil, nil, errors.Wrap(err, `failed to set "zip" in protected header`)
 		}
 	}
 
@@ -58,87 +116,45 @@ func (e encryptCtx) Encrypt(plaintext []byte) (*Message, error) {
 	recipients := make([]Recipient, len(e.keyEncrypters))
 	for i, enc := range e.keyEncrypters {
 		r := NewRecipient()
+		if i < len(e.recipientHeaders) && e.recipientHeaders[i] != nil {
+			h, err := r.Headers().Merge(context.TODO(), e.recipientHeaders[i])
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "failed to merge recipient headers")
+			}
+			if err := r.SetHeaders(h); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to set recipient headers")
+			}
+		}
 		if err := r.Headers().Set(AlgorithmKey, enc.Algorithm()); err != nil {
-			return nil, errors.Wrap(err, "failed to set header")
+			return nil, nil, errors.Wrap(err, "failed to set header")
 		}
 		if v := enc.KeyID(); v != "" {
 			if err := r.Headers().Set(KeyIDKey, v); err != nil {
-				return nil, errors.Wrap(err, "failed to set header")
+				return nil, nil, errors.Wrap(err, "failed to set header")
 			}
 		}
 
 		enckey, err := enc.Encrypt(cek)
 		if err != nil {
-			return nil, errors.Wrap(err, `failed to encrypt key`)
+			return nil, nil, errors.Wrap(err, `failed to encrypt key`)
 		}
 		if enc.Algorithm() == jwa.ECDH_ES || enc.Algorithm() == jwa.DIRECT {
 			if len(e.keyEncrypters) > 1 {
-				return nil, errors.Errorf("unable to support multiple recipients for ECDH-ES")
+				return nil, nil, errors.Errorf("unable to support multiple recipients for %s", enc.Algorithm())
 			}
 			cek = enckey.Bytes()
 		} else {
 			if err := r.SetEncryptedKey(enckey.Bytes()); err != nil {
-				return nil, errors.Wrap(err, "failed to set encrypted key")
+				return nil, nil, errors.Wrap(err, "failed to set encrypted key")
 			}
 		}
 		if hp, ok := enckey.(populater); ok {
 			if err := hp.Populate(r.Headers()); err != nil {
-				return nil, errors.Wrap(err, "failed to populate")
+				return nil, nil, errors.Wrap(err, "failed to populate")
 			}
 		}
 		recipients[i] = r
 	}
 
-	// If there's only one recipient, you want to include that in the
-	// protected header
-	if len(recipients) == 1 {
-		h, err := e.protected.Merge(context.TODO(), recipients[0].Headers())
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to merge protected headers")
-		}
-		e.protected = h
-	}
-
-	aad, err := e.protected.Encode()
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to base64 encode protected headers")
-	}
-
-	plaintext, err = compress(plaintext, compression)
-	if err != nil {
-		return nil, errors.Wrap(err, `failed to compress payload before encryption`)
-	}
-
-	// ...on the other hand, there's only one content cipher.
-	iv, ciphertext, tag, err := e.contentEncrypter.Encrypt(cek, plaintext, aad)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to encrypt payload")
-	}
-
-	msg := NewMessage()
-
-	decodedAad, err := base64.Decode(aad)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode base64")
-	}
-	if err := msg.Set(AuthenticatedDataKey, decodedAad); err != nil {
-		return nil, errors.Wrapf(err, `failed to set %s`, AuthenticatedDataKey)
-	}
-	if err := msg.Set(CipherTextKey, ciphertext); err != nil {
-		return nil, errors.Wrapf(err, `failed to set %s`, CipherTextKey)
-	}
-	if err := msg.Set(InitializationVectorKey, iv); err != nil {
-		return nil, errors.Wrapf(err, `failed to set %s`, InitializationVectorKey)
-	}
-	if err := msg.Set(ProtectedHeadersKey, e.protected); err != nil {
-		return nil, errors.Wrapf(err, `failed to set %s`, ProtectedHeadersKey)
-	}
-	if err := msg.Set(RecipientsKey, recipients); err != nil {
-		return nil, errors.Wrapf(err, `failed to set %s`, RecipientsKey)
-	}
-	if err := msg.Set(TagKey, tag); err != nil {
-		return nil, errors.Wrapf(err, `failed to set %s`, TagKey)
-	}
-
-	return msg, nil
+	return cek, recipients, nil
 }
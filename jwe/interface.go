@@ -69,6 +69,7 @@ type contentEncrypter interface {
 //nolint:govet
 type encryptCtx struct {
 	keyEncrypters    []keyenc.Encrypter
+	recipientHeaders []Headers
 	protected        Headers
 	contentEncrypter contentEncrypter
 	generator        keygen.Generator
@@ -39,16 +39,14 @@ func unpad(buf []byte, n int) ([]byte, error) {
 		return nil, errors.Errorf("input buffer must be multiple of block size %d", n)
 	}
 
-	count := 0
 	last := buf[lbuf-1]
-	for i := lbuf - 1; i >= 0; i-- {
+	if last == 0 || int(last) > n || int(last) > lbuf {
+		return nil, errors.New("invalid padding")
+	}
+	for i := lbuf - int(last); i < lbuf; i++ {
 		if buf[i] != last {
-			break
+			return nil, errors.New("invalid padding")
 		}
-		count++
-	}
-	if count != int(last) {
-		return nil, errors.New("invalid padding")
 	}
 
 	return buf[:lbuf-int(last)], nil
@@ -1,7 +1,10 @@
 package aescbc
 
 import (
+	"bytes"
 	"crypto/aes"
+	"crypto/rand"
+	"io/ioutil"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -81,3 +84,79 @@ func TestPad(t *testing.T) {
 		}
 	}
 }
+
+func TestStream(t *testing.T) {
+	for _, keysize := range []int{32, 48, 64} {
+		for _, size := range []int{0, 1, 15, 16, 17, 1000, 65537} {
+			key := make([]byte, keysize)
+			nonce := make([]byte, NonceSize)
+			plaintext := make([]byte, size)
+			aad := []byte(`eyJhbGciOiJkaXIiLCJlbmMiOiJBMTI4Q0JDLUhTMjU2In0`)
+			for _, b := range [][]byte{key, nonce, plaintext} {
+				_, _ = rand.Read(b)
+			}
+
+			c, err := New(key, aes.NewCipher)
+			if !assert.NoError(t, err, `aescbc.New should succeed`) {
+				return
+			}
+			expected := c.Seal(nil, nonce, plaintext, aad)
+
+			var buf bytes.Buffer
+			enc, err := c.NewStreamEncrypter(nonce, aad, &buf)
+			if !assert.NoError(t, err, `NewStreamEncrypter should succeed`) {
+				return
+			}
+			// Write in uneven chunks
+			for p := plaintext; len(p) > 0; {
+				n := 7
+				if n > len(p) {
+					n = len(p)
+				}
+				_, err := enc.Write(p[:n])
+				if !assert.NoError(t, err, `enc.Write should succeed`) {
+					return
+				}
+				p = p[n:]
+			}
+			if !assert.NoError(t, enc.Close(), `enc.Close should succeed`) {
+				return
+			}
+
+			tagOffset := len(expected) - c.tagsize
+			if !assert.Equal(t, expected[:tagOffset], buf.Bytes(), `ciphertext should match (size = %d)`, size) {
+				return
+			}
+			if !assert.Equal(t, expected[tagOffset:], enc.Tag(), `tag should match (size = %d)`, size) {
+				return
+			}
+
+			auth, err := c.NewStreamAuthenticator(nonce, aad)
+			if !assert.NoError(t, err, `NewStreamAuthenticator should succeed`) {
+				return
+			}
+			_, _ = auth.Write(buf.Bytes())
+			if !assert.NoError(t, auth.Verify(enc.Tag()), `auth.Verify should succeed`) {
+				return
+			}
+
+			auth, _ = c.NewStreamAuthenticator(nonce, aad[1:])
+			_, _ = auth.Write(buf.Bytes())
+			if !assert.Error(t, auth.Verify(enc.Tag()), `auth.Verify should fail with different aad`) {
+				return
+			}
+
+			dec, err := c.NewStreamDecrypter(nonce, bytes.NewReader(buf.Bytes()))
+			if !assert.NoError(t, err, `NewStreamDecrypter should succeed`) {
+				return
+			}
+			decrypted, err := ioutil.ReadAll(dec)
+			if !assert.NoError(t, err, `ioutil.ReadAll should succeed`) {
+				return
+			}
+			if !assert.Equal(t, plaintext, decrypted, `plaintext should match (size = %d)`, size) {
+				return
+			}
+		}
+	}
+}
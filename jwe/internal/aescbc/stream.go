@@ -0,0 +1,229 @@
+package aescbc
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// StreamEncrypter encrypts the plaintext written to it using AES-CBC,
+// and writes the resulting ciphertext to the underlying writer.
+// The authentication tag is available via Tag() after Close() is called.
+type StreamEncrypter struct {
+	dst     io.Writer
+	mode    cipher.BlockMode
+	mac     hash.Hash
+	aadlen  int
+	tagsize int
+	pending []byte
+	tag     []byte
+	closed  bool
+}
+
+// NewStreamEncrypter creates a new StreamEncrypter that writes
+// ciphertext to `dst`.
+func (c Hmac) NewStreamEncrypter(nonce, aad []byte, dst io.Writer) (*StreamEncrypter, error) {
+	if len(nonce) != NonceSize {
+		return nil, errors.Errorf("invalid nonce size %d", len(nonce))
+	}
+
+	mac := hmac.New(c.hash, c.integrityKey)
+	mac.Write(aad)
+	mac.Write(nonce)
+	return &StreamEncrypter{
+		dst:     dst,
+		mode:    cipher.NewCBCEncrypter(c.blockCipher, nonce),
+		mac:     mac,
+		aadlen:  len(aad),
+		tagsize: c.tagsize,
+	}, nil
+}
+
+func (e *StreamEncrypter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New(`write to closed stream encrypter`)
+	}
+
+	e.pending = append(e.pending, p...)
+	bs := e.mode.BlockSize()
+	full := len(e.pending) - len(e.pending)%bs
+	if full == 0 {
+		return len(p), nil
+	}
+
+	if err := e.emit(e.pending[:full]); err != nil {
+		return 0, err
+	}
+	e.pending = e.pending[:copy(e.pending, e.pending[full:])]
+	return len(p), nil
+}
+
+func (e *StreamEncrypter) emit(buf []byte) error {
+	e.mode.CryptBlocks(buf, buf)
+	e.mac.Write(buf)
+	if _, err := e.dst.Write(buf); err != nil {
+		return errors.Wrap(err, `failed to write ciphertext`)
+	}
+	return nil
+}
+
+// Close pads and encrypts the remaining plaintext, and computes the
+// authentication tag. It does not close the underlying writer.
+func (e *StreamEncrypter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+
+	if err := e.emit(pad(e.pending, e.mode.BlockSize())); err != nil {
+		return err
+	}
+	e.pending = nil
+
+	var al [8]byte
+	binary.BigEndian.PutUint64(al[:], uint64(e.aadlen*8))
+	e.mac.Write(al[:])
+	e.tag = e.mac.Sum(nil)[:e.tagsize]
+	return nil
+}
+
+// Tag returns the authentication tag. It returns nil until Close() is called.
+func (e *StreamEncrypter) Tag() []byte {
+	return e.tag
+}
+
+// StreamAuthenticator computes the authentication tag over the
+// ciphertext written to it, without decrypting it.
+type StreamAuthenticator struct {
+	mac     hash.Hash
+	aadlen  int
+	tagsize int
+	bs      int
+	size    int
+}
+
+// NewStreamAuthenticator creates a new StreamAuthenticator.
+func (c Hmac) NewStreamAuthenticator(nonce, aad []byte) (*StreamAuthenticator, error) {
+	if len(nonce) != NonceSize {
+		return nil, errors.Errorf("invalid nonce size %d", len(nonce))
+	}
+
+	mac := hmac.New(c.hash, c.integrityKey)
+	mac.Write(aad)
+	mac.Write(nonce)
+	return &StreamAuthenticator{
+		mac:     mac,
+		aadlen:  len(aad),
+		tagsize: c.tagsize,
+		bs:      c.blockCipher.BlockSize(),
+	}, nil
+}
+
+func (a *StreamAuthenticator) Write(p []byte) (int, error) {
+	a.size += len(p)
+	return a.mac.Write(p)
+}
+
+// Verify checks that the ciphertext written so far matches `tag`.
+func (a *StreamAuthenticator) Verify(tag []byte) error {
+	if a.size == 0 || a.size%a.bs != 0 {
+		return errors.Errorf("invalid ciphertext (invalid length: %d %% %d != 0)", a.size, a.bs)
+	}
+
+	var al [8]byte
+	binary.BigEndian.PutUint64(al[:], uint64(a.aadlen*8))
+	a.mac.Write(al[:])
+	expected := a.mac.Sum(nil)[:a.tagsize]
+	if subtle.ConstantTimeCompare(expected, tag) != 1 {
+		return errors.New("invalid ciphertext (tag mismatch)")
+	}
+	return nil
+}
+
+type streamDecrypter struct {
+	src  io.Reader
+	mode cipher.BlockMode
+	in   []byte
+	out  []byte
+	held []byte
+	done bool
+}
+
+// NewStreamDecrypter creates an io.Reader that decrypts the ciphertext
+// read from `src`. The ciphertext is NOT authenticated: callers must
+// verify it using a StreamAuthenticator before releasing the plaintext.
+func (c Hmac) NewStreamDecrypter(nonce []byte, src io.Reader) (io.Reader, error) {
+	if len(nonce) != NonceSize {
+		return nil, errors.Errorf("invalid nonce size %d", len(nonce))
+	}
+	return &streamDecrypter{
+		src:  src,
+		mode: cipher.NewCBCDecrypter(c.blockCipher, nonce),
+	}, nil
+}
+
+func (d *streamDecrypter) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// fill decrypts the next chunk of ciphertext. The last decrypted block
+// is always held back until the end of the input is reached, so that
+// the padding can be removed.
+func (d *streamDecrypter) fill() error {
+	var chunk [4096]byte
+	n, err := d.src.Read(chunk[:])
+	d.in = append(d.in, chunk[:n]...)
+	if err != nil && err != io.EOF {
+		return errors.Wrap(err, `failed to read ciphertext`)
+	}
+
+	bs := d.mode.BlockSize()
+	eof := err == io.EOF
+	if eof && len(d.in)%bs != 0 {
+		return errors.Errorf("invalid ciphertext (invalid length: %d %% %d != 0)", len(d.in), bs)
+	}
+
+	full := len(d.in) - len(d.in)%bs
+	buf := make([]byte, len(d.held)+full)
+	copy(buf, d.held)
+	d.mode.CryptBlocks(buf[len(d.held):], d.in[:full])
+	d.in = d.in[:copy(d.in, d.in[full:])]
+
+	if eof {
+		if len(buf) == 0 {
+			return errors.New("invalid ciphertext (too short)")
+		}
+		plaintext, err := unpad(buf, bs)
+		if err != nil {
+			return errors.Wrap(err, `failed to generate plaintext from decrypted blocks`)
+		}
+		d.out = plaintext
+		d.held = nil
+		d.done = true
+		return nil
+	}
+
+	if len(buf) < bs {
+		d.held = buf
+		return nil
+	}
+	d.out = buf[:len(buf)-bs]
+	d.held = buf[len(buf)-bs:]
+	return nil
+}
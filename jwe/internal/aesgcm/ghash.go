@@ -0,0 +1,207 @@
+// Portions of this file are from Go's crypto/cipher/gcm.go, which is
+// Copyright 2013 The Go Authors. All rights reserved, and is distributed
+// under the following license:
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//    * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//    * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//    * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package aesgcm
+
+import "encoding/binary"
+
+// The GHASH implementation below is based on the generic (non-assembly)
+// GCM implementation found in the Go standard library's crypto/cipher
+// package. The standard library does not expose GHASH, and its AEAD
+// interface requires the entire message to be present in memory, which
+// is why it is reproduced here.
+
+const blockSize = 16
+
+// fieldElement represents a value in GF(2¹²⁸). The bits are stored in
+// reverse order: the coefficient of x⁰ is the MSB of low, and the
+// coefficient of x¹²⁷ is the LSB of high.
+type fieldElement struct {
+	low, high uint64
+}
+
+// reductionTable is stored irreducible polynomial's double & add precomputed
+// to reduce the number of table lookups
+var reductionTable = []uint16{
+	0x0000, 0x1c20, 0x3840, 0x2460, 0x7080, 0x6ca0, 0x48c0, 0x54e0,
+	0xe100, 0xfd20, 0xd940, 0xc560, 0x9180, 0x8da0, 0xa9c0, 0xb5e0,
+}
+
+type ghash struct {
+	// productTable contains the first sixteen powers of the key, H.
+	// However, they are in bit reversed order.
+	productTable [16]fieldElement
+	y            fieldElement
+	pending      [blockSize]byte
+	npending     int
+}
+
+func newGHASH(h []byte) *ghash {
+	var g ghash
+	x := fieldElement{
+		binary.BigEndian.Uint64(h[:8]),
+		binary.BigEndian.Uint64(h[8:]),
+	}
+	g.productTable[reverseBits(1)] = x
+
+	for i := 2; i < 16; i += 2 {
+		g.productTable[reverseBits(i)] = double(&g.productTable[reverseBits(i/2)])
+		g.productTable[reverseBits(i+1)] = add(&g.productTable[reverseBits(i)], &x)
+	}
+	return &g
+}
+
+// reverseBits reverses the order of the bits of 4-bit number in i.
+func reverseBits(i int) int {
+	i = ((i << 2) & 0xc) | ((i >> 2) & 0x3)
+	i = ((i << 1) & 0xa) | ((i >> 1) & 0x5)
+	return i
+}
+
+// add adds two elements of GF(2¹²⁸) and returns the sum.
+func add(x, y *fieldElement) fieldElement {
+	// Addition in a characteristic 2 field is just XOR.
+	return fieldElement{x.low ^ y.low, x.high ^ y.high}
+}
+
+// double returns the result of doubling an element of GF(2¹²⁸).
+func double(x *fieldElement) (double fieldElement) {
+	msbSet := x.high&1 == 1
+
+	// Because of the bit-ordering, doubling is actually a right shift.
+	double.high = x.high >> 1
+	double.high |= x.low << 63
+	double.low = x.low >> 1
+
+	// If the most-significant bit was set before shifting then it,
+	// conceptually, becomes a term of x^128. This is greater than the
+	// irreducible polynomial so the result has to be reduced. The
+	// irreducible polynomial is 1+x+x^2+x^7+x^128. We can subtract that to
+	// eliminate the term at x^128 which also means subtracting the other
+	// four terms. In characteristic 2 fields, subtraction == addition ==
+	// XOR.
+	if msbSet {
+		double.low ^= 0xe100000000000000
+	}
+
+	return
+}
+
+// mul sets y to y*H, where H is the GCM key.
+func (g *ghash) mul(y *fieldElement) {
+	var z fieldElement
+
+	for i := 0; i < 2; i++ {
+		word := y.high
+		if i == 1 {
+			word = y.low
+		}
+
+		// Multiplication works by multiplying z by 16 and adding in
+		// one of the precomputed multiples of H.
+		for j := 0; j < 64; j += 4 {
+			msw := z.high & 0xf
+			z.high >>= 4
+			z.high |= z.low << 60
+			z.low >>= 4
+			z.low ^= uint64(reductionTable[msw]) << 48
+
+			// the values in |table| are ordered for
+			// little-endian bit positions. See the comment
+			// in newGHASH.
+			t := &g.productTable[word&0xf]
+
+			z.low ^= t.low
+			z.high ^= t.high
+			word >>= 4
+		}
+	}
+
+	*y = z
+}
+
+func (g *ghash) updateBlock(block []byte) {
+	g.y.low ^= binary.BigEndian.Uint64(block)
+	g.y.high ^= binary.BigEndian.Uint64(block[8:])
+	g.mul(&g.y)
+}
+
+// Write feeds data into the hash. Partial blocks are buffered until
+// either more data arrives, or flush is called.
+func (g *ghash) Write(p []byte) (int, error) {
+	n := len(p)
+	if g.npending > 0 {
+		c := copy(g.pending[g.npending:], p)
+		g.npending += c
+		p = p[c:]
+		if g.npending < blockSize {
+			return n, nil
+		}
+		g.updateBlock(g.pending[:])
+		g.npending = 0
+	}
+
+	for len(p) >= blockSize {
+		g.updateBlock(p[:blockSize])
+		p = p[blockSize:]
+	}
+
+	if len(p) > 0 {
+		g.npending = copy(g.pending[:], p)
+	}
+	return n, nil
+}
+
+// flush pads any pending partial block with zeros and feeds it into the hash.
+func (g *ghash) flush() {
+	if g.npending == 0 {
+		return
+	}
+	for i := g.npending; i < blockSize; i++ {
+		g.pending[i] = 0
+	}
+	g.updateBlock(g.pending[:])
+	g.npending = 0
+}
+
+// sum finalizes the hash using the lengths (in bytes) of the
+// additional data and the ciphertext, and returns S as defined by
+// NIST SP 800-38D.
+func (g *ghash) sum(aadlen, ctlen uint64) []byte {
+	g.flush()
+	g.y.low ^= aadlen * 8
+	g.y.high ^= ctlen * 8
+	g.mul(&g.y)
+
+	out := make([]byte, blockSize)
+	binary.BigEndian.PutUint64(out, g.y.low)
+	binary.BigEndian.PutUint64(out[8:], g.y.high)
+	return out
+}
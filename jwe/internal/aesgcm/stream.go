@@ -0,0 +1,180 @@
+// Package aesgcm implements AES-GCM over streams of data.
+//
+// The standard library's cipher.AEAD implementation for GCM requires
+// the entire message to be present in memory. The types in this package
+// allow the ciphertext to be produced and authenticated incrementally,
+// while remaining compatible with the output of cipher.NewGCM.
+package aesgcm
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	NonceSize = 12
+	TagSize   = 16
+
+	// maxPlaintextSize is the maximum size of the plaintext that can be
+	// processed using a single nonce, as defined by NIST SP 800-38D
+	maxPlaintextSize = ((1 << 32) - 2) * blockSize
+)
+
+type state struct {
+	hash    *ghash
+	tagMask [blockSize]byte
+	aadlen  uint64
+	ctlen   uint64
+}
+
+// setup computes the hash key, the tag mask, and the counter stream
+// used for encrypting/decrypting the content
+func setup(block cipher.Block, nonce, aad []byte) (*state, cipher.Stream, error) {
+	if block.BlockSize() != blockSize {
+		return nil, nil, errors.New(`aesgcm: block cipher must have a block size of 16`)
+	}
+	if len(nonce) != NonceSize {
+		return nil, nil, errors.Errorf(`aesgcm: invalid nonce size %d`, len(nonce))
+	}
+
+	var h [blockSize]byte
+	block.Encrypt(h[:], h[:])
+
+	var counter [blockSize]byte
+	copy(counter[:], nonce)
+	counter[blockSize-1] = 1
+
+	var s state
+	block.Encrypt(s.tagMask[:], counter[:])
+
+	binary.BigEndian.PutUint32(counter[NonceSize:], 2)
+
+	s.hash = newGHASH(h[:])
+	_, _ = s.hash.Write(aad)
+	s.hash.flush()
+	s.aadlen = uint64(len(aad))
+	return &s, cipher.NewCTR(block, counter[:]), nil
+}
+
+func (s *state) update(ciphertext []byte) error {
+	s.ctlen += uint64(len(ciphertext))
+	if s.ctlen > maxPlaintextSize {
+		return errors.New(`aesgcm: message too large`)
+	}
+	_, _ = s.hash.Write(ciphertext)
+	return nil
+}
+
+func (s *state) tag() []byte {
+	tag := s.hash.sum(s.aadlen, s.ctlen)
+	for i := range tag {
+		tag[i] ^= s.tagMask[i]
+	}
+	return tag
+}
+
+// StreamEncrypter encrypts the plaintext written to it using AES-GCM,
+// and writes the resulting ciphertext to the underlying writer.
+// The authentication tag is available via Tag() after Close() is called.
+type StreamEncrypter struct {
+	dst    io.Writer
+	state  *state
+	ctr    cipher.Stream
+	buf    []byte
+	tag    []byte
+	closed bool
+}
+
+// NewStreamEncrypter creates a new StreamEncrypter that writes
+// ciphertext to `dst`.
+func NewStreamEncrypter(block cipher.Block, nonce, aad []byte, dst io.Writer) (*StreamEncrypter, error) {
+	s, ctr, err := setup(block, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamEncrypter{
+		dst:   dst,
+		state: s,
+		ctr:   ctr,
+	}, nil
+}
+
+func (e *StreamEncrypter) Write(p []byte) (int, error) {
+	if e.closed {
+		return 0, errors.New(`write to closed stream encrypter`)
+	}
+
+	if cap(e.buf) < len(p) {
+		e.buf = make([]byte, len(p))
+	}
+	buf := e.buf[:len(p)]
+	e.ctr.XORKeyStream(buf, p)
+	if err := e.state.update(buf); err != nil {
+		return 0, err
+	}
+	if _, err := e.dst.Write(buf); err != nil {
+		return 0, errors.Wrap(err, `failed to write ciphertext`)
+	}
+	return len(p), nil
+}
+
+// Close computes the authentication tag. It does not close the
+// underlying writer.
+func (e *StreamEncrypter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	e.tag = e.state.tag()
+	return nil
+}
+
+// Tag returns the authentication tag. It returns nil until Close() is called.
+func (e *StreamEncrypter) Tag() []byte {
+	return e.tag
+}
+
+// StreamAuthenticator computes the authentication tag over the
+// ciphertext written to it, without decrypting it.
+type StreamAuthenticator struct {
+	state *state
+}
+
+// NewStreamAuthenticator creates a new StreamAuthenticator.
+func NewStreamAuthenticator(block cipher.Block, nonce, aad []byte) (*StreamAuthenticator, error) {
+	s, _, err := setup(block, nonce, aad)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamAuthenticator{state: s}, nil
+}
+
+func (a *StreamAuthenticator) Write(p []byte) (int, error) {
+	if err := a.state.update(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Verify checks that the ciphertext written so far matches `tag`.
+func (a *StreamAuthenticator) Verify(tag []byte) error {
+	if subtle.ConstantTimeCompare(a.state.tag(), tag) != 1 {
+		return errors.New(`invalid ciphertext (tag mismatch)`)
+	}
+	return nil
+}
+
+// NewStreamDecrypter creates an io.Reader that decrypts the ciphertext
+// read from `src`. The ciphertext is NOT authenticated: callers must
+// verify it using a StreamAuthenticator before releasing the plaintext.
+func NewStreamDecrypter(block cipher.Block, nonce []byte, src io.Reader) (io.Reader, error) {
+	_, ctr, err := setup(block, nonce, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &cipher.StreamReader{S: ctr, R: src}, nil
+}
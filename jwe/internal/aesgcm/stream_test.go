@@ -0,0 +1,151 @@
+package aesgcm
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	for _, keysize := range []int{16, 24, 32} {
+		for _, size := range []int{0, 1, 15, 16, 17, 1000, 65537} {
+			key := make([]byte, keysize)
+			nonce := make([]byte, NonceSize)
+			plaintext := make([]byte, size)
+			aad := []byte(`eyJhbGciOiJkaXIiLCJlbmMiOiJBMTI4R0NNIn0`)
+			for _, b := range [][]byte{key, nonce, plaintext} {
+				_, _ = rand.Read(b)
+			}
+
+			block, err := aes.NewCipher(key)
+			if !assert.NoError(t, err, `aes.NewCipher should succeed`) {
+				return
+			}
+			aead, err := cipher.NewGCM(block)
+			if !assert.NoError(t, err, `cipher.NewGCM should succeed`) {
+				return
+			}
+			expected := aead.Seal(nil, nonce, plaintext, aad)
+
+			var buf bytes.Buffer
+			enc, err := NewStreamEncrypter(block, nonce, aad, &buf)
+			if !assert.NoError(t, err, `NewStreamEncrypter should succeed`) {
+				return
+			}
+			// Write in uneven chunks
+			for p := plaintext; len(p) > 0; {
+				n := 7
+				if n > len(p) {
+					n = len(p)
+				}
+				_, err := enc.Write(p[:n])
+				if !assert.NoError(t, err, `enc.Write should succeed`) {
+					return
+				}
+				p = p[n:]
+			}
+			if !assert.NoError(t, enc.Close(), `enc.Close should succeed`) {
+				return
+			}
+
+			if !assert.True(t, bytes.Equal(expected[:size], buf.Bytes()), `ciphertext should match (size = %d)`, size) {
+				return
+			}
+			if !assert.Equal(t, expected[size:], enc.Tag(), `tag should match (size = %d)`, size) {
+				return
+			}
+
+			auth, err := NewStreamAuthenticator(block, nonce, aad)
+			if !assert.NoError(t, err, `NewStreamAuthenticator should succeed`) {
+				return
+			}
+			_, _ = auth.Write(buf.Bytes())
+			if !assert.NoError(t, auth.Verify(enc.Tag()), `auth.Verify should succeed`) {
+				return
+			}
+
+			auth, _ = NewStreamAuthenticator(block, nonce, aad[1:])
+			_, _ = auth.Write(buf.Bytes())
+			if !assert.Error(t, auth.Verify(enc.Tag()), `auth.Verify should fail with different aad`) {
+				return
+			}
+
+			dec, err := NewStreamDecrypter(block, nonce, bytes.NewReader(buf.Bytes()))
+			if !assert.NoError(t, err, `NewStreamDecrypter should succeed`) {
+				return
+			}
+			decrypted, err := ioutil.ReadAll(dec)
+			if !assert.NoError(t, err, `ioutil.ReadAll should succeed`) {
+				return
+			}
+			if !assert.Equal(t, plaintext, decrypted, `plaintext should match`) {
+				return
+			}
+		}
+	}
+}
+
+// TestGCMCompatibility checks that the output of StreamEncrypter matches
+// crypto/cipher's GCM implementation byte for byte, regardless of how
+// the plaintext is split across calls to Write
+func TestGCMCompatibility(t *testing.T) {
+	key := make([]byte, 32)
+	nonce := make([]byte, NonceSize)
+	_, _ = rand.Read(key)
+	_, _ = rand.Read(nonce)
+
+	block, err := aes.NewCipher(key)
+	if !assert.NoError(t, err, `aes.NewCipher should succeed`) {
+		return
+	}
+	aead, err := cipher.NewGCM(block)
+	if !assert.NoError(t, err, `cipher.NewGCM should succeed`) {
+		return
+	}
+
+	for _, aadsize := range []int{0, 1, 16, 39} {
+		for _, size := range []int{0, 1, 15, 16, 17, 31, 33, 255, 4097, 1<<20 + 3} {
+			for _, chunk := range []int{1, 16, 4096, size + 1} {
+				if chunk == 1 && size > 4097 {
+					continue
+				}
+				aad := make([]byte, aadsize)
+				plaintext := make([]byte, size)
+				_, _ = rand.Read(aad)
+				_, _ = rand.Read(plaintext)
+
+				expected := aead.Seal(nil, nonce, plaintext, aad)
+
+				var buf bytes.Buffer
+				enc, err := NewStreamEncrypter(block, nonce, aad, &buf)
+				if !assert.NoError(t, err, `NewStreamEncrypter should succeed`) {
+					return
+				}
+				for p := plaintext; len(p) > 0; {
+					n := chunk
+					if n > len(p) {
+						n = len(p)
+					}
+					_, err := enc.Write(p[:n])
+					if !assert.NoError(t, err, `enc.Write should succeed`) {
+						return
+					}
+					p = p[n:]
+				}
+				if !assert.NoError(t, enc.Close(), `enc.Close should succeed`) {
+					return
+				}
+
+				actual := append(buf.Bytes(), enc.Tag()...)
+				if !assert.True(t, bytes.Equal(expected, actual), `output should match crypto/cipher (aad = %d, size = %d, chunk = %d)`, aadsize, size, chunk) {
+					return
+				}
+			}
+		}
+	}
+}
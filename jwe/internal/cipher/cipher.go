@@ -47,31 +47,38 @@ func NewAES(alg jwa.ContentEncryptionAlgorithm) (*AesContentCipher, error) {
 	var keysize int
 	var tagsize int
 	var fetcher Fetcher
+	var stream streamer
 	switch alg {
 	case jwa.A128GCM:
 		keysize = 16
 		tagsize = 16
 		fetcher = gcm
+		stream = gcm
 	case jwa.A192GCM:
 		keysize = 24
 		tagsize = 16
 		fetcher = gcm
+		stream = gcm
 	case jwa.A256GCM:
 		keysize = 32
 		tagsize = 16
 		fetcher = gcm
+		stream = gcm
 	case jwa.A128CBC_HS256:
 		tagsize = 16
 		keysize = tagsize * 2
 		fetcher = cbc
+		stream = cbc
 	case jwa.A192CBC_HS384:
 		tagsize = 24
 		keysize = tagsize * 2
 		fetcher = cbc
+		stream = cbc
 	case jwa.A256CBC_HS512:
 		tagsize = 32
 		keysize = tagsize * 2
 		fetcher = cbc
+		stream = cbc
 	default:
 		return nil, errors.Errorf("failed to create AES content cipher: invalid algorithm (%s)", alg)
 	}
@@ -80,6 +87,7 @@ func NewAES(alg jwa.ContentEncryptionAlgorithm) (*AesContentCipher, error) {
 		keysize: keysize,
 		tagsize: tagsize,
 		fetch:   fetcher,
+		stream:  stream,
 	}, nil
 }
 
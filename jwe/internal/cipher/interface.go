@@ -2,6 +2,7 @@ package cipher
 
 import (
 	"crypto/cipher"
+	"io"
 
 	"github.com/lestrrat-go/jwx/jwe/internal/keygen"
 )
@@ -18,10 +19,39 @@ type ContentCipher interface {
 	Decrypt(cek, iv, aad, ciphertext, tag []byte) ([]byte, error)
 }
 
+// StreamCipher knows how to encrypt/decrypt the content incrementally,
+// without having to hold the entire content in memory
+type StreamCipher interface {
+	NewStreamEncrypter(cek, aad []byte, dst io.Writer) ([]byte, StreamEncrypter, error)
+	NewStreamAuthenticator(cek, iv, aad []byte) (StreamAuthenticator, error)
+	NewStreamDecrypter(cek, iv []byte, src io.Reader) (io.Reader, error)
+}
+
+// StreamEncrypter encrypts the content written to it. The authentication
+// tag is available via Tag() after Close() has been called
+type StreamEncrypter interface {
+	io.WriteCloser
+	Tag() []byte
+}
+
+// StreamAuthenticator computes the authentication tag over the ciphertext
+// written to it.
+type StreamAuthenticator interface {
+	io.Writer
+	Verify([]byte) error
+}
+
 type Fetcher interface {
 	Fetch([]byte) (cipher.AEAD, error)
 }
 
+type streamer interface {
+	NonceSize() int
+	NewStreamEncrypter(key, nonce, aad []byte, dst io.Writer) (StreamEncrypter, error)
+	NewStreamAuthenticator(key, nonce, aad []byte) (StreamAuthenticator, error)
+	NewStreamDecrypter(key, nonce []byte, src io.Reader) (io.Reader, error)
+}
+
 type gcmFetcher struct{}
 type cbcFetcher struct{}
 
@@ -29,6 +59,7 @@ type cbcFetcher struct{}
 type AesContentCipher struct {
 	NonceGenerator keygen.Generator
 	fetch          Fetcher
+	stream         streamer
 	keysize        int
 	tagsize        int
 }
@@ -0,0 +1,110 @@
+package cipher
+
+import (
+	"crypto/aes"
+	"io"
+
+	"github.com/lestrrat-go/jwx/jwe/internal/aescbc"
+	"github.com/lestrrat-go/jwx/jwe/internal/aesgcm"
+	"github.com/lestrrat-go/jwx/jwe/internal/keygen"
+	"github.com/pkg/errors"
+)
+
+func (f gcmFetcher) NonceSize() int {
+	return aesgcm.NonceSize
+}
+
+func (f gcmFetcher) NewStreamEncrypter(key, nonce, aad []byte, dst io.Writer) (StreamEncrypter, error) {
+	aescipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher: failed to create AES cipher for GCM")
+	}
+	return aesgcm.NewStreamEncrypter(aescipher, nonce, aad, dst)
+}
+
+func (f gcmFetcher) NewStreamAuthenticator(key, nonce, aad []byte) (StreamAuthenticator, error) {
+	aescipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher: failed to create AES cipher for GCM")
+	}
+	return aesgcm.NewStreamAuthenticator(aescipher, nonce, aad)
+}
+
+func (f gcmFetcher) NewStreamDecrypter(key, nonce []byte, src io.Reader) (io.Reader, error) {
+	aescipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher: failed to create AES cipher for GCM")
+	}
+	return aesgcm.NewStreamDecrypter(aescipher, nonce, src)
+}
+
+func (f cbcFetcher) NonceSize() int {
+	return aescbc.NonceSize
+}
+
+func (f cbcFetcher) NewStreamEncrypter(key, nonce, aad []byte, dst io.Writer) (StreamEncrypter, error) {
+	c, err := aescbc.New(key, aes.NewCipher)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher: failed to create AES cipher for CBC")
+	}
+	return c.NewStreamEncrypter(nonce, aad, dst)
+}
+
+func (f cbcFetcher) NewStreamAuthenticator(key, nonce, aad []byte) (StreamAuthenticator, error) {
+	c, err := aescbc.New(key, aes.NewCipher)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher: failed to create AES cipher for CBC")
+	}
+	return c.NewStreamAuthenticator(nonce, aad)
+}
+
+func (f cbcFetcher) NewStreamDecrypter(key, nonce []byte, src io.Reader) (io.Reader, error) {
+	c, err := aescbc.New(key, aes.NewCipher)
+	if err != nil {
+		return nil, errors.Wrap(err, "cipher: failed to create AES cipher for CBC")
+	}
+	return c.NewStreamDecrypter(nonce, src)
+}
+
+// NewStreamEncrypter creates a StreamEncrypter that writes the ciphertext
+// to `dst`. The generated initialization vector is returned along with it.
+func (c AesContentCipher) NewStreamEncrypter(cek, aad []byte, dst io.Writer) ([]byte, StreamEncrypter, error) {
+	var bs keygen.ByteSource
+	var err error
+	if c.NonceGenerator == nil {
+		bs, err = keygen.NewRandom(c.stream.NonceSize()).Generate()
+	} else {
+		bs, err = c.NonceGenerator.Generate()
+	}
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to generate nonce")
+	}
+	iv := bs.Bytes()
+
+	enc, err := c.stream.NewStreamEncrypter(cek, iv, aad, dst)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, `failed to create stream encrypter`)
+	}
+	return iv, enc, nil
+}
+
+// NewStreamAuthenticator creates a StreamAuthenticator that can be used
+// to verify the ciphertext before it is decrypted.
+func (c AesContentCipher) NewStreamAuthenticator(cek, iv, aad []byte) (StreamAuthenticator, error) {
+	auth, err := c.stream.NewStreamAuthenticator(cek, iv, aad)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create stream authenticator`)
+	}
+	return auth, nil
+}
+
+// NewStreamDecrypter creates an io.Reader that decrypts the ciphertext
+// read from `src`. The ciphertext is not authenticated by the returned
+// reader: use NewStreamAuthenticator to verify it first.
+func (c AesContentCipher) NewStreamDecrypter(cek, iv []byte, src io.Reader) (io.Reader, error) {
+	dec, err := c.stream.NewStreamDecrypter(cek, iv, src)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create stream decrypter`)
+	}
+	return dec, nil
+}
@@ -1,6 +1,8 @@
 package content_crypt //nolint:golint
 
 import (
+	"io"
+
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwe/internal/cipher"
 	"github.com/pkg/errors"
@@ -23,6 +25,38 @@ func (c Generic) Decrypt(cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
 	return c.cipher.Decrypt(cek, iv, ciphertext, tag, aad)
 }
 
+func (c Generic) streamCipher() (cipher.StreamCipher, error) {
+	sc, ok := c.cipher.(cipher.StreamCipher)
+	if !ok {
+		return nil, errors.Errorf(`content cipher for %s does not support streaming`, c.alg)
+	}
+	return sc, nil
+}
+
+func (c Generic) NewStreamEncrypter(cek, aad []byte, dst io.Writer) ([]byte, cipher.StreamEncrypter, error) {
+	sc, err := c.streamCipher()
+	if err != nil {
+		return nil, nil, err
+	}
+	return sc.NewStreamEncrypter(cek, aad, dst)
+}
+
+func (c Generic) NewStreamAuthenticator(cek, iv, aad []byte) (cipher.StreamAuthenticator, error) {
+	sc, err := c.streamCipher()
+	if err != nil {
+		return nil, err
+	}
+	return sc.NewStreamAuthenticator(cek, iv, aad)
+}
+
+func (c Generic) NewStreamDecrypter(cek, iv []byte, src io.Reader) (io.Reader, error) {
+	sc, err := c.streamCipher()
+	if err != nil {
+		return nil, err
+	}
+	return sc.NewStreamDecrypter(cek, iv, src)
+}
+
 func NewGeneric(alg jwa.ContentEncryptionAlgorithm) (*Generic, error) {
 	c, err := cipher.NewAES(alg)
 	if err != nil {
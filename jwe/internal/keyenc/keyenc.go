@@ -25,6 +25,7 @@ import (
 	"github.com/lestrrat-go/jwx/jwe/internal/concatkdf"
 	"github.com/lestrrat-go/jwx/jwe/internal/keygen"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
 	"github.com/pkg/errors"
 )
 
@@ -203,6 +204,8 @@ func NewECDHESEncrypt(alg jwa.KeyEncryptionAlgorithm, enc jwa.ContentEncryptionA
 		generator, err = keygen.NewEcdhes(alg, enc, keysize, key)
 	case x25519.PublicKey:
 		generator, err = keygen.NewX25519(alg, enc, keysize, key)
+	case x448.PublicKey:
+		generator, err = keygen.NewX448(alg, enc, keysize, key)
 	default:
 		return nil, errors.Errorf("unexpected key type %T", keyif)
 	}
@@ -285,6 +288,16 @@ func DeriveZ(privkeyif interface{}, pubkeyif interface{}) ([]byte, error) {
 			return nil, errors.Errorf(`public key must be x25519.PublicKey, was: %T`, pubkeyif)
 		}
 		return curve25519.X25519(privkey.Seed(), pubkey)
+	case x448.PrivateKey:
+		privkey, ok := privkeyif.(x448.PrivateKey)
+		if !ok {
+			return nil, errors.Errorf(`private key must be x448.PrivateKey, was: %T`, privkeyif)
+		}
+		pubkey, ok := pubkeyif.(x448.PublicKey)
+		if !ok {
+			return nil, errors.Errorf(`public key must be x448.PublicKey, was: %T`, pubkeyif)
+		}
+		return x448.X448(privkey.Seed(), pubkey)
 	default:
 		privkey, ok := privkeyif.(*ecdsa.PrivateKey)
 		if !ok {
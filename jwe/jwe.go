@@ -5,6 +5,7 @@ package jwe
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/rsa"
 	"io"
@@ -20,6 +21,7 @@ import (
 	"github.com/lestrrat-go/jwx/jwe/internal/keyenc"
 	"github.com/lestrrat-go/jwx/jwe/internal/keygen"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
 	"github.com/pkg/errors"
 )
 
@@ -28,16 +30,115 @@ var registry = json.NewRegistry()
 // Encrypt takes the plaintext payload and encrypts it in JWE compact format.
 // `key` should be a public key, and it may be a raw key (e.g. rsa.PublicKey) or a jwk.Key
 //
-// Encrypt currently does not support multi-recipient messages.
+// Encrypt only supports single-recipient messages. If you need to encrypt
+// the same payload for multiple recipients, use `jwe.EncryptMulti()`
+//
+// If strict key usage checks are enabled using `jwk.WithStrictKeyUsage()`
+// or `jwe.WithStrictKeyUsage()`, a jwk.Key may only be used if its "use",
+// "key_ops", and "alg" fields permit encrypting using `keyalg`.
 func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) ([]byte, error) {
 	var protected Headers
+	strict := jwk.StrictKeyUsage()
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identProtectedHeader{}:
+			protected = option.Value().(Headers)
+		case identStrictKeyUsage{}:
+			strict = option.Value().(bool)
+		}
+	}
+	if protected == nil {
+		protected = NewHeaders()
+	}
+
+	if strict {
+		if err := validateEncryptionKey(key, keyalg); err != nil {
+			return nil, err
+		}
+	}
+
+	contentcrypt, err := content_crypt.NewGeneric(contentalg)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create AES encrypter`)
+	}
+
+	enc, err := buildKeyEncrypter(keyalg, key, contentcrypt)
+	if err != nil {
+		return nil, err
+	}
+
+	keysize := contentcrypt.KeySize()
+	encctx := getEncryptCtx()
+	defer releaseEncryptCtx(encctx)
+
+	encctx.protected = protected
+	encctx.contentEncrypter = contentcrypt
+	encctx.generator = keygen.NewRandom(keysize)
+	encctx.keyEncrypters = []keyenc.Encrypter{enc}
+	encctx.compress = compressalg
+	msg, err := encctx.Encrypt(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt payload")
+	}
+
+	return Compact(msg)
+}
+
+// EncryptMulti encrypts the payload for multiple recipients, and
+// returns the result in JWE JSON serialization format.
+//
+// Each recipient must be specified using the `jwe.WithKey()` option.
+// A single content encryption key is generated, which in turn is
+// encrypted for each of the recipients using their respective key
+// encryption algorithm. Recipients may use different key encryption
+// algorithms.
+//
+// Algorithms that derive the content encryption key from the recipient's
+// key, namely `jwa.DIRECT` and `jwa.ECDH_ES`, can only be used when
+// there is exactly one recipient.
+//
+// `jwe.WithProtectedHeaders()` can be used to specify the contents of the
+// protected header, which is shared among all recipients.
+func EncryptMulti(payload []byte, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) ([]byte, error) {
+	encctx := getEncryptCtx()
+	defer releaseEncryptCtx(encctx)
+
+	if _, err := setupMultiEncryptCtx(encctx, contentalg, compressalg, options); err != nil {
+		return nil, err
+	}
+
+	msg, err := encctx.Encrypt(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encrypt payload")
+	}
+
+	return JSON(msg)
+}
+
+// setupMultiEncryptCtx configures `encctx` using the recipients specified
+// via `jwe.WithKey()` and the protected headers specified via
+// `jwe.WithProtectedHeaders()`
+func setupMultiEncryptCtx(encctx *encryptCtx, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options []EncryptOption) (*content_crypt.Generic, error) {
+	var protected Headers
+	var recipients []*recipientKey
+	strict := jwk.StrictKeyUsage()
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
 		case identProtectedHeader{}:
 			protected = option.Value().(Headers)
+		case identRecipientKey{}:
+			recipients = append(recipients, option.Value().(*recipientKey))
+		case identStrictKeyUsage{}:
+			strict = option.Value().(bool)
 		}
 	}
+
+	if len(recipients) == 0 {
+		return nil, errors.New(`no recipients provided (use jwe.WithKey() to specify recipients)`)
+	}
+
 	if protected == nil {
 		protected = NewHeaders()
 	}
@@ -47,6 +148,70 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 		return nil, errors.Wrap(err, `failed to create AES encrypter`)
 	}
 
+	encrypters := make([]keyenc.Encrypter, len(recipients))
+	headers := make([]Headers, len(recipients))
+	for i, recipient := range recipients {
+		if strict {
+			if err := validateEncryptionKey(recipient.key, recipient.alg); err != nil {
+				return nil, errors.Wrapf(err, `invalid key for recipient #%d`, i)
+			}
+		}
+
+		enc, err := buildKeyEncrypter(recipient.alg, recipient.key, contentcrypt)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create key encrypter for recipient #%d (alg=%s)`, i, recipient.alg)
+		}
+		encrypters[i] = enc
+
+		// Work on a copy, as the headers are owned by the caller
+		hdrs := NewHeaders()
+		if recipient.headers != nil {
+			cloned, err := recipient.headers.Clone(context.Background())
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to copy headers for recipient #%d`, i)
+			}
+			hdrs = cloned
+		}
+		if jwkKey, ok := recipient.key.(jwk.Key); ok {
+			if kid := jwkKey.KeyID(); kid != "" && hdrs.KeyID() == "" {
+				if err := hdrs.Set(KeyIDKey, kid); err != nil {
+					return nil, errors.Wrapf(err, `failed to set "kid" for recipient #%d`, i)
+				}
+			}
+		}
+		headers[i] = hdrs
+	}
+
+	encctx.protected = protected
+	encctx.contentEncrypter = contentcrypt
+	encctx.generator = keygen.NewRandom(contentcrypt.KeySize())
+	encctx.keyEncrypters = encrypters
+	encctx.recipientHeaders = headers
+	encctx.compress = compressalg
+	return contentcrypt, nil
+}
+
+// validateEncryptionKey checks that `key`, if it is a jwk.Key, may be
+// used to encrypt the content encryption key using `alg`
+func validateEncryptionKey(key interface{}, alg jwa.KeyEncryptionAlgorithm) error {
+	if jwkKey, ok := key.(jwk.Key); ok {
+		return jwk.ValidateKeyUsage(jwkKey, jwk.ForEncryption, alg.String(), jwk.KeyOpEncrypt, jwk.KeyOpWrapKey, jwk.KeyOpDeriveKey)
+	}
+	return nil
+}
+
+// validateDecryptionKey checks that `key`, if it is a jwk.Key, may be
+// used to decrypt the content encryption key using `alg`
+func validateDecryptionKey(key interface{}, alg jwa.KeyEncryptionAlgorithm) error {
+	if jwkKey, ok := key.(jwk.Key); ok {
+		return jwk.ValidateKeyUsage(jwkKey, jwk.ForEncryption, alg.String(), jwk.KeyOpDecrypt, jwk.KeyOpUnwrapKey, jwk.KeyOpDeriveKey)
+	}
+	return nil
+}
+
+// buildKeyEncrypter creates the keyenc.Encrypter that is appropriate for
+// the given key encryption algorithm and key.
+func buildKeyEncrypter(keyalg jwa.KeyEncryptionAlgorithm, key interface{}, contentcrypt *content_crypt.Generic) (keyenc.Encrypter, error) {
 	if jwkKey, ok := key.(jwk.Key); ok {
 		var raw interface{}
 		if err := jwkKey.Raw(&raw); err != nil {
@@ -57,6 +222,7 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 	}
 
 	var enc keyenc.Encrypter
+	var err error
 	switch keyalg {
 	case jwa.RSA1_5:
 		var pubkey rsa.PublicKey
@@ -117,14 +283,14 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 		}
 
 		switch key := key.(type) {
-		case x25519.PublicKey:
-			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, key)
+		case x25519.PublicKey, x448.PublicKey:
+			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentcrypt.Algorithm(), keysize, key)
 		default:
 			var pubkey ecdsa.PublicKey
 			if err := keyconv.ECDSAPublicKey(&pubkey, key); err != nil {
 				return nil, errors.Wrapf(err, "failed to generate public key from key (%T)", key)
 			}
-			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentalg, keysize, &pubkey)
+			enc, err = keyenc.NewECDHESEncrypt(keyalg, contentcrypt.Algorithm(), keysize, &pubkey)
 		}
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create ECDHS key wrap encrypter")
@@ -139,21 +305,7 @@ func Encrypt(payload []byte, keyalg jwa.KeyEncryptionAlgorithm, key interface{},
 		return nil, errors.Errorf(`invalid key encryption algorithm (%s)`, keyalg)
 	}
 
-	keysize := contentcrypt.KeySize()
-	encctx := getEncryptCtx()
-	defer releaseEncryptCtx(encctx)
-
-	encctx.protected = protected
-	encctx.contentEncrypter = contentcrypt
-	encctx.generator = keygen.NewRandom(keysize)
-	encctx.keyEncrypters = []keyenc.Encrypter{enc}
-	encctx.compress = compressalg
-	msg, err := encctx.Encrypt(payload)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to encrypt payload")
-	}
-
-	return Compact(msg)
+	return enc, nil
 }
 
 // DecryptCtx is used internally when jwe.Decrypt is called, and is
@@ -172,9 +324,10 @@ type DecryptCtx interface {
 }
 
 type decryptCtx struct {
-	alg jwa.KeyEncryptionAlgorithm
-	key interface{}
-	msg *Message
+	alg      jwa.KeyEncryptionAlgorithm
+	key      interface{}
+	msg      *Message
+	critical map[string]CriticalHandler
 }
 
 func (ctx *decryptCtx) Algorithm() jwa.KeyEncryptionAlgorithm {
@@ -206,6 +359,14 @@ func (ctx *decryptCtx) SetMessage(m *Message) {
 // The JWE message can be either compact or full JSON format.
 //
 // `key` must be a private key. It can be either in its raw format (e.g. *rsa.PrivateKey) or a jwk.Key
+//
+// If the message contains a "crit" header field, all of the extensions
+// listed in it must be understood. Use `jwe.RegisterCriticalHandler()`
+// or `jwe.WithCriticalHandler()` to handle your own extensions.
+//
+// If strict key usage checks are enabled using `jwk.WithStrictKeyUsage()`
+// or `jwe.WithStrictKeyUsage()`, a jwk.Key may only be used if its "use",
+// "key_ops", and "alg" fields permit decrypting using `alg`.
 func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, options ...DecryptOption) ([]byte, error) {
 	var ctx decryptCtx
 	ctx.key = key
@@ -213,6 +374,7 @@ func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, option
 
 	var dst *Message
 	var postParse PostParser
+	strict := jwk.StrictKeyUsage()
 	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
@@ -220,6 +382,10 @@ func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, option
 			dst = option.Value().(*Message)
 		case identPostParser{}:
 			postParse = option.Value().(PostParser)
+		case identCriticalHandler{}:
+			ctx.critical = option.Value().(*criticalHandlerPair).addTo(ctx.critical)
+		case identStrictKeyUsage{}:
+			strict = option.Value().(bool)
 		}
 	}
 
@@ -235,6 +401,12 @@ func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, option
 		}
 	}
 
+	if strict {
+		if err := validateDecryptionKey(ctx.key, ctx.alg); err != nil {
+			return nil, err
+		}
+	}
+
 	payload, err := doDecryptCtx(&ctx)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed to decrypt message`)
@@ -249,6 +421,167 @@ func Decrypt(buf []byte, alg jwa.KeyEncryptionAlgorithm, key interface{}, option
 	return payload, nil
 }
 
+// KeyMatch is populated by `jwe.DecryptSet()` when the `jwe.WithKeyMatch()`
+// option is specified. It describes the recipient and the key that
+// were used to successfully decrypt the message.
+type KeyMatch struct {
+	// RecipientIndex is the index of the recipient in `(jwe.Message).Recipients()`
+	RecipientIndex int
+	// Recipient is the recipient whose encrypted key was decrypted
+	Recipient Recipient
+	// Key is the key from the jwk.Set that was used to decrypt the message
+	Key jwk.Key
+	// Algorithm is the key encryption algorithm that was used
+	Algorithm jwa.KeyEncryptionAlgorithm
+}
+
+// DecryptSet uses keys stored in a jwk.Set to decrypt the JWE message in `buf`.
+// The JWE message can be either compact or full JSON format.
+//
+// For each recipient in the message, candidate keys are selected from the
+// set using the recipient's headers:
+//
+// * If the recipient specifies a "kid", the key must have the same "kid".
+// * If the recipient specifies "x5t" or "x5t#S256" and the key has the same
+//   field, their values must match.
+// * If the key has an "alg" field, it must match the recipient's "alg".
+//   Otherwise the key type must be compatible with the recipient's "alg".
+// * If the key has a "use" field, it must be "enc".
+// * If strict key usage checks are enabled, and the key has a "key_ops"
+//   field, it must permit decryption.
+//
+// Every candidate key is tried for every recipient, until one succeeds.
+// Use `jwe.WithKeyMatch()` to find out which recipient and key were used.
+//
+// As the keys and algorithms are determined by the set and the message,
+// `jwe.WithPostParser()` cannot be used with this function.
+func DecryptSet(buf []byte, set jwk.Set, options ...DecryptOption) ([]byte, error) {
+	var dst *Message
+	var match *KeyMatch
+	var critical map[string]CriticalHandler
+	strict := jwk.StrictKeyUsage()
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identMessage{}:
+			dst = option.Value().(*Message)
+		case identKeyMatch{}:
+			match = option.Value().(*KeyMatch)
+		case identPostParser{}:
+			return nil, errors.New(`jwe.WithPostParser() cannot be used with jwe.DecryptSet()`)
+		case identCriticalHandler{}:
+			critical = option.Value().(*criticalHandlerPair).addTo(critical)
+		case identStrictKeyUsage{}:
+			strict = option.Value().(bool)
+		}
+	}
+
+	msg, err := parseJSONOrCompact(buf, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse buffer for DecryptSet")
+	}
+
+	md, err := newMessageDecrypter(msg, critical)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastError error
+	for i, recipient := range md.recipients {
+		h, err := md.recipientHeaders(recipient)
+		if err != nil {
+			return nil, err
+		}
+		alg := h.Algorithm()
+
+		for _, key := range jwk.FindKeys(set, recipientKeyFilters(alg, h)...) {
+			if strict {
+				if err := validateDecryptionKey(key, alg); err != nil {
+					lastError = err
+					continue
+				}
+			}
+
+			payload, err := md.decrypt(recipient, alg, key)
+			if err != nil {
+				lastError = err
+				continue
+			}
+
+			if err := md.handleCritical(); err != nil {
+				return nil, err
+			}
+
+			if match != nil {
+				*match = KeyMatch{
+					RecipientIndex: i,
+					Recipient:      recipient,
+					Key:            key,
+					Algorithm:      alg,
+				}
+			}
+
+			if dst != nil {
+				*dst = *msg
+				dst.rawProtectedHeaders = nil
+				dst.storeProtectedHeaders = false
+			}
+			return payload, nil
+		}
+	}
+
+	if lastError != nil {
+		return nil, errors.Errorf(`failed to decrypt message with any of the keys in the jwk.Set object (last error = %s)`, lastError)
+	}
+	return nil, errors.New(`failed to find a key in the jwk.Set object matching any of the recipients`)
+}
+
+// recipientKeyFilters returns the filters that select the keys that
+// may be used to decrypt the content encryption key of a recipient
+func recipientKeyFilters(alg jwa.KeyEncryptionAlgorithm, h Headers) []jwk.KeyFilter {
+	filters := []jwk.KeyFilter{jwk.MatchKeyUsage(jwk.ForEncryption)}
+	if kid := h.KeyID(); kid != "" {
+		filters = append(filters, jwk.MatchKeyID(kid))
+	}
+
+	// Keys that do not specify certificate thumbprints are not excluded
+	if v := h.X509CertThumbprint(); v != "" {
+		filters = append(filters, jwk.KeyFilterFunc(func(key jwk.Key) bool {
+			kv := key.X509CertThumbprint()
+			return kv == "" || kv == v
+		}))
+	}
+	if v := h.X509CertThumbprintS256(); v != "" {
+		filters = append(filters, jwk.KeyFilterFunc(func(key jwk.Key) bool {
+			kv := key.X509CertThumbprintS256()
+			return kv == "" || kv == v
+		}))
+	}
+
+	return append(filters, jwk.KeyFilterFunc(func(key jwk.Key) bool {
+		if keyalg := key.Algorithm(); keyalg != "" {
+			return keyalg == alg.String()
+		}
+		return keyTypeMatchesAlgorithm(key.KeyType(), alg)
+	}))
+}
+
+func keyTypeMatchesAlgorithm(kty jwa.KeyType, alg jwa.KeyEncryptionAlgorithm) bool {
+	switch alg {
+	case jwa.RSA1_5, jwa.RSA_OAEP, jwa.RSA_OAEP_256:
+		return kty == jwa.RSA
+	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
+		return kty == jwa.EC || kty == jwa.OKP
+	case jwa.DIRECT,
+		jwa.A128KW, jwa.A192KW, jwa.A256KW,
+		jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW,
+		jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
+		return kty == jwa.OctetSeq
+	default:
+		return false
+	}
+}
+
 // Parse parses the JWE message into a Message object. The JWE message
 // can be either compact or full JSON format.
 func Parse(buf []byte) (*Message, error) {
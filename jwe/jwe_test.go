@@ -1,6 +1,7 @@
 package jwe_test
 
 import (
+	"bytes"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/elliptic"
@@ -8,6 +9,7 @@ import (
 	"crypto/rsa"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
@@ -20,6 +22,8 @@ import (
 	"github.com/lestrrat-go/jwx/jwe"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -444,6 +448,15 @@ func TestEncode_X25519(t *testing.T) {
 	testEncodeECDHWithKey(t, privkey, pubkey)
 }
 
+func TestEncode_X448(t *testing.T) {
+	pubkey, privkey, err := x448.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err, `x448.GenerateKey should succeed`) {
+		return
+	}
+
+	testEncodeECDHWithKey(t, privkey, pubkey)
+}
+
 func Test_GHIssue207(t *testing.T) {
 	const plaintext = "hi\n"
 	var testcases = []struct {
@@ -747,3 +760,568 @@ func TestCustomField(t *testing.T) {
 		}
 	})
 }
+
+func TestEncryptMulti(t *testing.T) {
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+	sharedkey := []byte("0123456789abcdef")
+
+	rsajwk, err := jwk.New(rsakey.PublicKey)
+	if !assert.NoError(t, err, `jwk.New should succeed`) {
+		return
+	}
+	_ = rsajwk.Set(jwk.KeyIDKey, "rsa-recipient")
+
+	echdrs := jwe.NewHeaders()
+	_ = echdrs.Set(jwe.KeyIDKey, "ec-recipient")
+
+	encrypted, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A256GCM, jwa.Deflate,
+		jwe.WithKey(jwa.RSA_OAEP, rsajwk, nil),
+		jwe.WithKey(jwa.ECDH_ES_A128KW, &eckey.PublicKey, echdrs),
+		jwe.WithKey(jwa.A128KW, sharedkey, nil),
+	)
+	if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+		return
+	}
+
+	msg, err := jwe.Parse(encrypted)
+	if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+		return
+	}
+	if !assert.Len(t, msg.Recipients(), 3, `there should be 3 recipients`) {
+		return
+	}
+	if !assert.Equal(t, "rsa-recipient", msg.Recipients()[0].Headers().KeyID(), `kid should be taken from jwk.Key`) {
+		return
+	}
+	if !assert.Equal(t, "ec-recipient", msg.Recipients()[1].Headers().KeyID(), `kid should be taken from headers`) {
+		return
+	}
+
+	testcases := []struct {
+		Name      string
+		Algorithm jwa.KeyEncryptionAlgorithm
+		Key       interface{}
+	}{
+		{Name: "RSA", Algorithm: jwa.RSA_OAEP, Key: rsakey},
+		{Name: "ECDSA", Algorithm: jwa.ECDH_ES_A128KW, Key: eckey},
+		{Name: "AES", Algorithm: jwa.A128KW, Key: sharedkey},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			decrypted, err := jwe.Decrypt(encrypted, tc.Algorithm, tc.Key)
+			if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+				return
+			}
+			if !assert.Equal(t, examplePayload, string(decrypted), `decrypted payload should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("ECDH-ES with multiple recipients", func(t *testing.T) {
+		_, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A256GCM, jwa.NoCompress,
+			jwe.WithKey(jwa.ECDH_ES, &eckey.PublicKey, nil),
+			jwe.WithKey(jwa.A128KW, sharedkey, nil),
+		)
+		if !assert.Error(t, err, `jwe.EncryptMulti should fail`) {
+			return
+		}
+	})
+	t.Run("Recipient headers are not modified", func(t *testing.T) {
+		hdrs := jwe.NewHeaders()
+		_ = hdrs.Set("x-custom", "value")
+		_, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A256GCM, jwa.NoCompress,
+			jwe.WithKey(jwa.RSA_OAEP, rsajwk, hdrs),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+		if !assert.Empty(t, hdrs.KeyID(), `"kid" should not be added to the caller's headers`) {
+			return
+		}
+	})
+	t.Run("No recipients", func(t *testing.T) {
+		_, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A256GCM, jwa.NoCompress)
+		if !assert.Error(t, err, `jwe.EncryptMulti should fail`) {
+			return
+		}
+	})
+}
+
+func TestDecryptSet(t *testing.T) {
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	rsapub, _ := jwk.New(rsakey.PublicKey)
+	_ = rsapub.Set(jwk.KeyIDKey, "rsa")
+	ecpub, _ := jwk.New(eckey.PublicKey)
+	_ = ecpub.Set(jwk.KeyIDKey, "ec")
+
+	encrypted, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A128CBC_HS256, jwa.NoCompress,
+		jwe.WithKey(jwa.RSA_OAEP, rsapub, nil),
+		jwe.WithKey(jwa.ECDH_ES_A128KW, ecpub, nil),
+	)
+	if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+		return
+	}
+
+	ecpriv, _ := jwk.New(eckey)
+	_ = ecpriv.Set(jwk.KeyIDKey, "ec")
+
+	t.Run("Match by kid", func(t *testing.T) {
+		// A decoy key with the same key type but a different kid
+		decoy, _ := jwxtest.GenerateEcdsaJwk()
+		_ = decoy.Set(jwk.KeyIDKey, "decoy")
+
+		set := jwk.NewSet()
+		set.Add(decoy)
+		set.Add(ecpriv)
+
+		var match jwe.KeyMatch
+		decrypted, err := jwe.DecryptSet(encrypted, set, jwe.WithKeyMatch(&match))
+		if !assert.NoError(t, err, `jwe.DecryptSet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, examplePayload, string(decrypted), `decrypted payload should match`) {
+			return
+		}
+		if !assert.Equal(t, 1, match.RecipientIndex, `recipient index should match`) {
+			return
+		}
+		if !assert.Equal(t, ecpriv, match.Key, `key should match`) {
+			return
+		}
+		if !assert.Equal(t, jwa.ECDH_ES_A128KW, match.Algorithm, `algorithm should match`) {
+			return
+		}
+	})
+	t.Run("Key with mismatched alg", func(t *testing.T) {
+		rsapriv, _ := jwk.New(rsakey)
+		_ = rsapriv.Set(jwk.KeyIDKey, "rsa")
+		_ = rsapriv.Set(jwk.AlgorithmKey, jwa.RSA1_5)
+
+		set := jwk.NewSet()
+		set.Add(rsapriv)
+		_, err := jwe.DecryptSet(encrypted, set)
+		if !assert.Error(t, err, `jwe.DecryptSet should fail`) {
+			return
+		}
+	})
+	t.Run("Key for signatures", func(t *testing.T) {
+		rsapriv, _ := jwk.New(rsakey)
+		_ = rsapriv.Set(jwk.KeyIDKey, "rsa")
+		_ = rsapriv.Set(jwk.KeyUsageKey, jwk.ForSignature)
+
+		set := jwk.NewSet()
+		set.Add(rsapriv)
+		_, err := jwe.DecryptSet(encrypted, set)
+		if !assert.Error(t, err, `jwe.DecryptSet should fail`) {
+			return
+		}
+	})
+	t.Run("Compact serialization", func(t *testing.T) {
+		encrypted, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, rsapub, jwa.A128GCM, jwa.NoCompress)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+
+		rsapriv, _ := jwk.New(rsakey)
+		set := jwk.NewSet()
+		set.Add(ecpriv)
+		set.Add(rsapriv)
+
+		decrypted, err := jwe.DecryptSet(encrypted, set)
+		if !assert.NoError(t, err, `jwe.DecryptSet should succeed`) {
+			return
+		}
+		if !assert.Equal(t, examplePayload, string(decrypted), `decrypted payload should match`) {
+			return
+		}
+	})
+	t.Run("WithPostParser", func(t *testing.T) {
+		var called bool
+		set := jwk.NewSet()
+		set.Add(ecpriv)
+		_, err := jwe.DecryptSet(encrypted, set, jwe.WithPostParser(jwe.PostParseFunc(func(jwe.DecryptCtx) error {
+			called = true
+			return nil
+		})))
+		if !assert.Error(t, err, `jwe.DecryptSet should fail`) {
+			return
+		}
+		if !assert.False(t, called, `post parser should not be called`) {
+			return
+		}
+	})
+}
+
+func TestStream(t *testing.T) {
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	sharedkey := make([]byte, 16)
+	_, _ = rand.Read(sharedkey)
+
+	// large enough to be spooled to a file during decryption
+	largePayload := make([]byte, 3<<20)
+	_, _ = rand.Read(largePayload)
+
+	payloads := map[string][]byte{
+		"empty": {},
+		"small": []byte(examplePayload),
+		"large": largePayload,
+	}
+
+	for _, contentalg := range []jwa.ContentEncryptionAlgorithm{jwa.A128GCM, jwa.A256GCM, jwa.A128CBC_HS256, jwa.A256CBC_HS512} {
+		for _, compressalg := range []jwa.CompressionAlgorithm{jwa.NoCompress, jwa.Deflate} {
+			for name, payload := range payloads {
+				contentalg := contentalg
+				compressalg := compressalg
+				payload := payload
+				t.Run(fmt.Sprintf("%s/%s/%s", contentalg, compressalg, name), func(t *testing.T) {
+					var buf bytes.Buffer
+					w, err := jwe.NewEncryptWriter(&buf, contentalg, compressalg,
+						jwe.WithKey(jwa.RSA_OAEP, &rsakey.PublicKey, nil),
+						jwe.WithKey(jwa.A128KW, sharedkey, nil),
+					)
+					if !assert.NoError(t, err, `jwe.NewEncryptWriter should succeed`) {
+						return
+					}
+					if _, err := io.Copy(w, bytes.NewReader(payload)); !assert.NoError(t, err, `io.Copy should succeed`) {
+						return
+					}
+					if !assert.NoError(t, w.Close(), `w.Close should succeed`) {
+						return
+					}
+
+					// The result should be decryptable using jwe.Decrypt
+					decrypted, err := jwe.Decrypt(buf.Bytes(), jwa.A128KW, sharedkey)
+					if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+						return
+					}
+					if !assert.True(t, bytes.Equal(payload, decrypted), `decrypted payload should match`) {
+						return
+					}
+
+					r, err := jwe.NewDecryptReader(bytes.NewReader(buf.Bytes()), jwa.RSA_OAEP, rsakey)
+					if !assert.NoError(t, err, `jwe.NewDecryptReader should succeed`) {
+						return
+					}
+					defer r.Close()
+
+					decrypted, err = ioutil.ReadAll(r)
+					if !assert.NoError(t, err, `ioutil.ReadAll should succeed`) {
+						return
+					}
+					if !assert.True(t, bytes.Equal(payload, decrypted), `decrypted payload should match`) {
+						return
+					}
+				})
+			}
+		}
+	}
+	t.Run("Decrypt output of jwe.Encrypt", func(t *testing.T) {
+		encrypted, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, &rsakey.PublicKey, jwa.A128CBC_HS256, jwa.Deflate)
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			return
+		}
+		msg, err := jwe.Parse(encrypted)
+		if !assert.NoError(t, err, `jwe.Parse should succeed`) {
+			return
+		}
+		serialized, err := jwe.JSON(msg)
+		if !assert.NoError(t, err, `jwe.JSON should succeed`) {
+			return
+		}
+
+		r, err := jwe.NewDecryptReader(bytes.NewReader(serialized), jwa.RSA_OAEP, rsakey)
+		if !assert.NoError(t, err, `jwe.NewDecryptReader should succeed`) {
+			return
+		}
+		defer r.Close()
+
+		decrypted, err := ioutil.ReadAll(r)
+		if !assert.NoError(t, err, `ioutil.ReadAll should succeed`) {
+			return
+		}
+		if !assert.Equal(t, examplePayload, string(decrypted), `decrypted payload should match`) {
+			return
+		}
+	})
+	t.Run("Tampered ciphertext", func(t *testing.T) {
+		for _, contentalg := range []jwa.ContentEncryptionAlgorithm{jwa.A128GCM, jwa.A128CBC_HS256} {
+			var buf bytes.Buffer
+			w, err := jwe.NewEncryptWriter(&buf, contentalg, jwa.NoCompress, jwe.WithKey(jwa.A128KW, sharedkey, nil))
+			if !assert.NoError(t, err, `jwe.NewEncryptWriter should succeed`) {
+				return
+			}
+			_, _ = w.Write(largePayload)
+			if !assert.NoError(t, w.Close(), `w.Close should succeed`) {
+				return
+			}
+
+			var msg map[string]interface{}
+			if !assert.NoError(t, json.Unmarshal(buf.Bytes(), &msg), `json.Unmarshal should succeed`) {
+				return
+			}
+			ciphertext := []byte(msg["ciphertext"].(string))
+			if ciphertext[100] == 'A' {
+				ciphertext[100] = 'B'
+			} else {
+				ciphertext[100] = 'A'
+			}
+			msg["ciphertext"] = string(ciphertext)
+			tampered, _ := json.Marshal(msg)
+
+			_, err = jwe.NewDecryptReader(bytes.NewReader(tampered), jwa.A128KW, sharedkey)
+			if !assert.Error(t, err, `jwe.NewDecryptReader should fail for %s`, contentalg) {
+				return
+			}
+		}
+	})
+}
+
+func TestCritical(t *testing.T) {
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	encrypt := func(t *testing.T, crit []string, extra map[string]interface{}) []byte {
+		t.Helper()
+		hdrs := jwe.NewHeaders()
+		if crit != nil {
+			_ = hdrs.Set(jwe.CriticalKey, crit)
+		}
+		for k, v := range extra {
+			_ = hdrs.Set(k, v)
+		}
+		encrypted, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, &rsakey.PublicKey, jwa.A128GCM, jwa.NoCompress, jwe.WithProtectedHeaders(hdrs))
+		if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+			t.FailNow()
+		}
+		return encrypted
+	}
+
+	t.Run("Unknown extension", func(t *testing.T) {
+		encrypted := encrypt(t, []string{"x-ext"}, map[string]interface{}{"x-ext": "foo"})
+		_, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey)
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+	t.Run("Registered header in crit", func(t *testing.T) {
+		encrypted := encrypt(t, []string{jwe.ContentEncryptionKey}, nil)
+		_, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey)
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+	t.Run("Per-call handler", func(t *testing.T) {
+		encrypted := encrypt(t, []string{"x-ext"}, map[string]interface{}{"x-ext": "foo"})
+
+		var called bool
+		h := jwe.CriticalHandlerFunc(func(name string, hdrs jwe.Headers) error {
+			called = true
+			v, _ := hdrs.Get(name)
+			if v != "foo" {
+				return errors.Errorf(`unexpected value %v`, v)
+			}
+			return nil
+		})
+
+		decrypted, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey, jwe.WithCriticalHandler("x-ext", h))
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.True(t, called, `handler should be called`) {
+			return
+		}
+		if !assert.Equal(t, examplePayload, string(decrypted), `payload should match`) {
+			return
+		}
+
+		// handler errors make the decryption fail
+		_, err = jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey, jwe.WithCriticalHandler("x-ext", jwe.CriticalHandlerFunc(func(string, jwe.Headers) error {
+			return errors.New(`rejected`)
+		})))
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+	})
+	t.Run("Global handler", func(t *testing.T) {
+		encrypted := encrypt(t, []string{"x-global"}, map[string]interface{}{"x-global": true})
+
+		var called bool
+		jwe.RegisterCriticalHandler("x-global", jwe.CriticalHandlerFunc(func(string, jwe.Headers) error {
+			called = true
+			return nil
+		}))
+		defer jwe.RegisterCriticalHandler("x-global", nil)
+
+		_, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey)
+		if !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+		if !assert.True(t, called, `handler should be called`) {
+			return
+		}
+
+		jwe.RegisterCriticalHandler("x-global", nil)
+		_, err = jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey)
+		if !assert.Error(t, err, `jwe.Decrypt should fail after unregistering the handler`) {
+			return
+		}
+	})
+	t.Run("DecryptSet and NewDecryptReader", func(t *testing.T) {
+		hdrs := jwe.NewHeaders()
+		_ = hdrs.Set(jwe.CriticalKey, []string{"x-ext"})
+		_ = hdrs.Set("x-ext", "foo")
+
+		encrypted, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A128GCM, jwa.NoCompress,
+			jwe.WithKey(jwa.RSA_OAEP, &rsakey.PublicKey, nil),
+			jwe.WithProtectedHeaders(hdrs),
+		)
+		if !assert.NoError(t, err, `jwe.EncryptMulti should succeed`) {
+			return
+		}
+
+		privkey, _ := jwk.New(rsakey)
+		set := jwk.NewSet()
+		set.Add(privkey)
+
+		handler := jwe.WithCriticalHandler("x-ext", jwe.CriticalHandlerFunc(func(string, jwe.Headers) error {
+			return nil
+		}))
+
+		_, err = jwe.DecryptSet(encrypted, set)
+		if !assert.Error(t, err, `jwe.DecryptSet should fail`) {
+			return
+		}
+		_, err = jwe.DecryptSet(encrypted, set, handler)
+		if !assert.NoError(t, err, `jwe.DecryptSet should succeed`) {
+			return
+		}
+
+		_, err = jwe.NewDecryptReader(bytes.NewReader(encrypted), jwa.RSA_OAEP, rsakey)
+		if !assert.Error(t, err, `jwe.NewDecryptReader should fail`) {
+			return
+		}
+		r, err := jwe.NewDecryptReader(bytes.NewReader(encrypted), jwa.RSA_OAEP, rsakey, handler)
+		if !assert.NoError(t, err, `jwe.NewDecryptReader should succeed`) {
+			return
+		}
+		_ = r.Close()
+	})
+}
+
+func TestStrictKeyUsage(t *testing.T) {
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	newKey := func(t *testing.T, raw interface{}, name string, value interface{}) jwk.Key {
+		key, err := jwk.New(raw)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			return nil
+		}
+		if !assert.NoError(t, key.Set(name, value), `key.Set should succeed`) {
+			return nil
+		}
+		return key
+	}
+
+	t.Run("Encrypt", func(t *testing.T) {
+		pubkey := newKey(t, rsakey.PublicKey, jwk.AlgorithmKey, jwa.RS256)
+		if pubkey == nil {
+			return
+		}
+
+		if _, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, pubkey, jwa.A128GCM, jwa.NoCompress); !assert.NoError(t, err, `jwe.Encrypt should succeed when not strict`) {
+			return
+		}
+		_, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, pubkey, jwa.A128GCM, jwa.NoCompress, jwe.WithStrictKeyUsage(true))
+		if !assert.Error(t, err, `jwe.Encrypt should fail`) {
+			return
+		}
+		if !assert.Equal(t, `key may not be used to encrypt using "RSA-OAEP": "alg" is "RS256"`, err.Error(), `error message should match`) {
+			return
+		}
+		if _, err := jwe.EncryptMulti([]byte(examplePayload), jwa.A128GCM, jwa.NoCompress, jwe.WithKey(jwa.RSA_OAEP, pubkey, nil), jwe.WithStrictKeyUsage(true)); !assert.Error(t, err, `jwe.EncryptMulti should fail`) {
+			return
+		}
+	})
+
+	encrypted, err := jwe.Encrypt([]byte(examplePayload), jwa.RSA_OAEP, rsakey.PublicKey, jwa.A128GCM, jwa.NoCompress)
+	if !assert.NoError(t, err, `jwe.Encrypt should succeed`) {
+		return
+	}
+
+	t.Run("Decrypt", func(t *testing.T) {
+		privkey := newKey(t, rsakey, jwk.KeyOpsKey, jwk.KeyOperationList{jwk.KeyOpWrapKey})
+		if privkey == nil {
+			return
+		}
+
+		if _, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, privkey); !assert.NoError(t, err, `jwe.Decrypt should succeed when not strict`) {
+			return
+		}
+		_, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, privkey, jwe.WithStrictKeyUsage(true))
+		if !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+		if !assert.Equal(t, `key may not be used to decrypt: "key_ops" is ["wrapKey"]`, err.Error(), `error message should match`) {
+			return
+		}
+
+		set := jwk.NewSet()
+		set.Add(privkey)
+		if _, err := jwe.DecryptSet(encrypted, set, jwe.WithStrictKeyUsage(true)); !assert.Error(t, err, `jwe.DecryptSet should fail`) {
+			return
+		}
+
+		if !assert.NoError(t, privkey.Set(jwk.KeyOpsKey, jwk.KeyOperationList{jwk.KeyOpUnwrapKey}), `privkey.Set should succeed`) {
+			return
+		}
+		if _, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, privkey, jwe.WithStrictKeyUsage(true)); !assert.NoError(t, err, `jwe.Decrypt should succeed`) {
+			return
+		}
+	})
+	t.Run("Global setting", func(t *testing.T) {
+		jwk.Settings(jwk.WithStrictKeyUsage(true))
+		defer jwk.Settings(jwk.WithStrictKeyUsage(false))
+
+		privkey := newKey(t, rsakey, jwk.KeyUsageKey, jwk.ForSignature)
+		if privkey == nil {
+			return
+		}
+
+		if _, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, privkey); !assert.Error(t, err, `jwe.Decrypt should fail`) {
+			return
+		}
+		if _, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, privkey, jwe.WithStrictKeyUsage(false)); !assert.NoError(t, err, `jwe.Decrypt should succeed when overridden`) {
+			return
+		}
+		if _, err := jwe.NewDecryptReader(bytes.NewReader(encrypted), jwa.RSA_OAEP, privkey); !assert.Error(t, err, `jwe.NewDecryptReader should fail`) {
+			return
+		}
+		if _, err := jwe.Decrypt(encrypted, jwa.RSA_OAEP, rsakey); !assert.NoError(t, err, `jwe.Decrypt should succeed with raw keys`) {
+			return
+		}
+	})
+}
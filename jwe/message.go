@@ -455,19 +455,54 @@ func (m *Message) Decrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]by
 }
 
 func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
-	m := dctx.msg
-	alg := dctx.alg
-	key := dctx.key
+	md, err := newMessageDecrypter(dctx.msg, dctx.critical)
+	if err != nil {
+		return nil, err
+	}
 
-	if jwkKey, ok := key.(jwk.Key); ok {
-		var raw interface{}
-		if err := jwkKey.Raw(&raw); err != nil {
-			return nil, errors.Wrapf(err, `failed to retrieve raw key from %T`, key)
+	var lastError error
+	for _, recipient := range md.recipients {
+		// strategy: try each recipient. If we fail in one of the steps,
+		// keep looping because there might be another key with the same algo
+		if recipient.Headers().Algorithm() != dctx.alg {
+			// algorithms don't match
+			continue
 		}
-		key = raw
+
+		plaintext, err := md.decrypt(recipient, dctx.alg, dctx.key)
+		if err != nil {
+			lastError = err
+			continue
+		}
+
+		if err := md.handleCritical(); err != nil {
+			return nil, err
+		}
+		return plaintext, nil
+	}
+
+	if lastError != nil {
+		return nil, errors.Errorf(`failed to find matching recipient to decrypt key (last error = %s)`, lastError)
+	}
+	return nil, errors.New("failed to find matching recipient")
+}
+
+// messageDecrypter holds the values computed from a Message that are
+// shared among all of its recipients during decryption
+type messageDecrypter struct {
+	msg         *Message
+	headers     Headers
+	aad         []byte
+	computedAad []byte
+	recipients  []Recipient
+	critical    map[string]CriticalHandler
+}
+
+func newMessageDecrypter(m *Message, critical map[string]CriticalHandler) (*messageDecrypter, error) {
+	if err := checkCritical(m, critical); err != nil {
+		return nil, errors.Wrap(err, `invalid "crit" header`)
 	}
 
-	var err error
 	ctx := context.TODO()
 	h, err := m.protectedHeaders.Clone(ctx)
 	if err != nil {
@@ -478,7 +513,6 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 		return nil, errors.Wrap(err, "failed to merge headers for message decryption")
 	}
 
-	enc := m.protectedHeaders.ContentEncryption()
 	var aad []byte
 	if aadContainer := m.authenticatedData; aadContainer != nil {
 		aad = base64.Encode(aadContainer)
@@ -496,15 +530,6 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 		}
 	}
 
-	dec := NewDecrypter(alg, enc, key).
-		AuthenticatedData(aad).
-		ComputedAuthenticatedData(computedAad).
-		InitializationVector(m.initializationVector).
-		Tag(m.tag)
-
-	var plaintext []byte
-	var lastError error
-
 	// if we have no recipients, pretend like we only have one
 	recipients := m.recipients
 	if len(recipients) == 0 {
@@ -515,132 +540,173 @@ func doDecryptCtx(dctx *decryptCtx) ([]byte, error) {
 		recipients = append(recipients, r)
 	}
 
-	for _, recipient := range recipients {
-		// strategy: try each recipient. If we fail in one of the steps,
-		// keep looping because there might be another key with the same algo
-		if recipient.Headers().Algorithm() != alg {
-			// algorithms don't match
-			continue
-		}
+	return &messageDecrypter{
+		msg:         m,
+		headers:     h,
+		aad:         aad,
+		computedAad: computedAad,
+		recipients:  recipients,
+		critical:    critical,
+	}, nil
+}
 
-		h2, err := h.Clone(ctx)
+// handleCritical calls the handlers for the extensions listed in the
+// "crit" header field. It must be called after the message has been
+// successfully decrypted.
+func (md *messageDecrypter) handleCritical() error {
+	if err := handleCritical(md.msg.protectedHeaders, md.critical); err != nil {
+		return errors.Wrap(err, `message decrypted, failed to process "crit"`)
+	}
+	return nil
+}
+
+// recipientHeaders returns the headers that apply to the given recipient,
+// that is, the protected and unprotected headers of the message merged
+// with the per-recipient headers
+func (md *messageDecrypter) recipientHeaders(recipient Recipient) (Headers, error) {
+	ctx := context.TODO()
+	h2, err := md.headers.Clone(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to copy headers (1)`)
+	}
+
+	h2, err = h2.Merge(ctx, recipient.Headers())
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to copy headers (2)`)
+	}
+	return h2, nil
+}
+
+// decrypt attempts to decrypt the message content by decrypting the
+// content encryption key for the given recipient using `alg` and `key`
+func (md *messageDecrypter) decrypt(recipient Recipient, alg jwa.KeyEncryptionAlgorithm, key interface{}) ([]byte, error) {
+	dec, h2, err := md.newDecrypter(recipient, alg, key)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := dec.Decrypt(recipient.EncryptedKey(), md.msg.cipherText)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decrypt`)
+	}
+
+	if h2.Compression() == jwa.Deflate {
+		buf, err := uncompress(plaintext)
 		if err != nil {
-			lastError = errors.Wrap(err, `failed to copy headers (1)`)
-			continue
+			return nil, errors.Wrap(err, `failed to uncompress payload`)
 		}
+		plaintext = buf
+	}
 
-		h2, err = h2.Merge(ctx, recipient.Headers())
-		if err != nil {
-			lastError = errors.Wrap(err, `failed to copy headers (2)`)
-			continue
+	return plaintext, nil
+}
+
+// newDecrypter creates a Decrypter that is configured to decrypt the
+// content encryption key for the given recipient using `alg` and `key`.
+// The headers that apply to the recipient are returned along with it.
+func (md *messageDecrypter) newDecrypter(recipient Recipient, alg jwa.KeyEncryptionAlgorithm, key interface{}) (*Decrypter, Headers, error) {
+	if jwkKey, ok := key.(jwk.Key); ok {
+		var raw interface{}
+		if err := jwkKey.Raw(&raw); err != nil {
+			return nil, nil, errors.Wrapf(err, `failed to retrieve raw key from %T`, key)
 		}
+		key = raw
+	}
 
-		switch alg {
-		case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
-			epkif, ok := h2.Get(EphemeralPublicKeyKey)
-			if !ok {
-				return nil, errors.New("failed to get 'epk' field")
-			}
-			switch epk := epkif.(type) {
-			case jwk.ECDSAPublicKey:
-				var pubkey ecdsa.PublicKey
-				if err := epk.Raw(&pubkey); err != nil {
-					return nil, errors.Wrap(err, "failed to get public key")
-				}
-				dec.PublicKey(&pubkey)
-			case jwk.OKPPublicKey:
-				var pubkey interface{}
-				if err := epk.Raw(&pubkey); err != nil {
-					return nil, errors.Wrap(err, "failed to get public key")
-				}
-				dec.PublicKey(pubkey)
-			default:
-				return nil, errors.Errorf("unexpected 'epk' type %T for alg %s", epkif, alg)
-			}
+	m := md.msg
+	h2, err := md.recipientHeaders(recipient)
+	if err != nil {
+		return nil, nil, err
+	}
 
-			if apu := h2.AgreementPartyUInfo(); len(apu) > 0 {
-				dec.AgreementPartyUInfo(apu)
-			}
+	dec := NewDecrypter(alg, m.protectedHeaders.ContentEncryption(), key).
+		AuthenticatedData(md.aad).
+		ComputedAuthenticatedData(md.computedAad).
+		InitializationVector(m.initializationVector).
+		Tag(m.tag)
 
-			if apv := h2.AgreementPartyVInfo(); len(apv) > 0 {
-				dec.AgreementPartyVInfo(apv)
-			}
-		case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
-			ivB64, ok := h2.Get(InitializationVectorKey)
-			if !ok {
-				return nil, errors.New("failed to get 'iv' field")
-			}
-			ivB64Str, ok := ivB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'iv': %T", ivB64)
-			}
-			tagB64, ok := h2.Get(TagKey)
-			if !ok {
-				return nil, errors.New("failed to get 'tag' field")
-			}
-			tagB64Str, ok := tagB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'tag': %T", tagB64)
-			}
-			iv, err := base64.DecodeString(ivB64Str)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'iv'")
-			}
-			tag, err := base64.DecodeString(tagB64Str)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'tag'")
-			}
-			dec.KeyInitializationVector(iv)
-			dec.KeyTag(tag)
-		case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
-			saltB64, ok := h2.Get(SaltKey)
-			if !ok {
-				return nil, errors.New("failed to get 'p2s' field")
+	switch alg {
+	case jwa.ECDH_ES, jwa.ECDH_ES_A128KW, jwa.ECDH_ES_A192KW, jwa.ECDH_ES_A256KW:
+		epkif, ok := h2.Get(EphemeralPublicKeyKey)
+		if !ok {
+			return nil, nil, errors.New("failed to get 'epk' field")
+		}
+		switch epk := epkif.(type) {
+		case jwk.ECDSAPublicKey:
+			var pubkey ecdsa.PublicKey
+			if err := epk.Raw(&pubkey); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to get public key")
 			}
-			saltB64Str, ok := saltB64.(string)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'p2s': %T", saltB64)
+			dec.PublicKey(&pubkey)
+		case jwk.OKPPublicKey:
+			var pubkey interface{}
+			if err := epk.Raw(&pubkey); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to get public key")
 			}
+			dec.PublicKey(pubkey)
+		default:
+			return nil, nil, errors.Errorf("unexpected 'epk' type %T for alg %s", epkif, alg)
+		}
 
-			count, ok := h2.Get(CountKey)
-			if !ok {
-				return nil, errors.New("failed to get 'p2c' field")
-			}
-			countFlt, ok := count.(float64)
-			if !ok {
-				return nil, errors.Errorf("unexpected type for 'p2c': %T", count)
-			}
-			salt, err := base64.DecodeString(saltB64Str)
-			if err != nil {
-				return nil, errors.Wrap(err, "failed to b64-decode 'salt'")
-			}
-			dec.KeySalt(salt)
-			dec.KeyCount(int(countFlt))
+		if apu := h2.AgreementPartyUInfo(); len(apu) > 0 {
+			dec.AgreementPartyUInfo(apu)
 		}
 
-		plaintext, err = dec.Decrypt(recipient.EncryptedKey(), m.cipherText)
+		if apv := h2.AgreementPartyVInfo(); len(apv) > 0 {
+			dec.AgreementPartyVInfo(apv)
+		}
+	case jwa.A128GCMKW, jwa.A192GCMKW, jwa.A256GCMKW:
+		ivB64, ok := h2.Get(InitializationVectorKey)
+		if !ok {
+			return nil, nil, errors.New("failed to get 'iv' field")
+		}
+		ivB64Str, ok := ivB64.(string)
+		if !ok {
+			return nil, nil, errors.Errorf("unexpected type for 'iv': %T", ivB64)
+		}
+		tagB64, ok := h2.Get(TagKey)
+		if !ok {
+			return nil, nil, errors.New("failed to get 'tag' field")
+		}
+		tagB64Str, ok := tagB64.(string)
+		if !ok {
+			return nil, nil, errors.Errorf("unexpected type for 'tag': %T", tagB64)
+		}
+		iv, err := base64.DecodeString(ivB64Str)
 		if err != nil {
-			lastError = errors.Wrap(err, `failed to decrypt`)
-			continue
+			return nil, nil, errors.Wrap(err, "failed to b64-decode 'iv'")
 		}
-
-		if h2.Compression() == jwa.Deflate {
-			buf, err := uncompress(plaintext)
-			if err != nil {
-				lastError = errors.Wrap(err, `failed to uncompress payload`)
-				continue
-			}
-			plaintext = buf
+		tag, err := base64.DecodeString(tagB64Str)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to b64-decode 'tag'")
+		}
+		dec.KeyInitializationVector(iv)
+		dec.KeyTag(tag)
+	case jwa.PBES2_HS256_A128KW, jwa.PBES2_HS384_A192KW, jwa.PBES2_HS512_A256KW:
+		saltB64, ok := h2.Get(SaltKey)
+		if !ok {
+			return nil, nil, errors.New("failed to get 'p2s' field")
+		}
+		saltB64Str, ok := saltB64.(string)
+		if !ok {
+			return nil, nil, errors.Errorf("unexpected type for 'p2s': %T", saltB64)
 		}
-		break
-	}
 
-	if plaintext == nil {
-		if lastError != nil {
-			return nil, errors.Errorf(`failed to find matching recipient to decrypt key (last error = %s)`, lastError)
+		count, ok := h2.Get(CountKey)
+		if !ok {
+			return nil, nil, errors.New("failed to get 'p2c' field")
+		}
+		countFlt, ok := count.(float64)
+		if !ok {
+			return nil, nil, errors.Errorf("unexpected type for 'p2c': %T", count)
 		}
-		return nil, errors.New("failed to find matching recipient")
+		salt, err := base64.DecodeString(saltB64Str)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to b64-decode 'salt'")
+		}
+		dec.KeySalt(salt)
+		dec.KeyCount(int(countFlt))
 	}
 
-	return plaintext, nil
+	return dec, h2, nil
 }
@@ -3,6 +3,7 @@ package jwe
 import (
 	"context"
 
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/option"
 )
 
@@ -11,6 +12,9 @@ type identMessage struct{}
 type identPostParser struct{}
 type identPrettyFormat struct{}
 type identProtectedHeader struct{}
+type identRecipientKey struct{}
+type identKeyMatch struct{}
+type identStrictKeyUsage struct{}
 
 type DecryptOption interface {
 	Option
@@ -45,6 +49,20 @@ type encryptOption struct {
 
 func (*encryptOption) encryptOption() {}
 
+// EncryptDecryptOption describes an option that can be passed to both
+// the encryption and decryption functions
+type EncryptDecryptOption interface {
+	EncryptOption
+	DecryptOption
+}
+
+type encryptDecryptOption struct {
+	Option
+}
+
+func (*encryptDecryptOption) encryptOption() {}
+func (*encryptDecryptOption) decryptOption() {}
+
 // WithPrettyFormat specifies if the `jwe.JSON` serialization tool
 // should generate pretty-formatted output
 func WithPrettyFormat(b bool) SerializerOption {
@@ -70,9 +88,17 @@ func WithMessage(m *Message) DecryptOption {
 	return &decryptOption{option.New(identMessage{}, m)}
 }
 
+// WithKeyMatch provides a KeyMatch object to be populated by
+// `jwe.DecryptSet()` upon successful decryption. This allows you to
+// find out which recipient and which key from the jwk.Set were used.
+func WithKeyMatch(m *KeyMatch) DecryptOption {
+	return &decryptOption{option.New(identKeyMatch{}, m)}
+}
+
 // WithPostParser specifies the handler to be called immediately
 // after the JWE message has been parsed, but before decryption
-// takes place during `jwe.Decrypt`.
+// takes place during `jwe.Decrypt`. It cannot be used with
+// `jwe.DecryptSet()`.
 //
 // This option exists to allow advanced users that require the use
 // of information stored in the JWE message to determine how the
@@ -85,3 +111,50 @@ func WithMessage(m *Message) DecryptOption {
 func WithPostParser(p PostParser) DecryptOption {
 	return &decryptOption{option.New(identPostParser{}, p)}
 }
+
+type recipientKey struct {
+	alg     jwa.KeyEncryptionAlgorithm
+	key     interface{}
+	headers Headers
+}
+
+// WithKey specifies a recipient for `jwe.EncryptMulti()`. The content
+// encryption key will be encrypted for this recipient using the key
+// encryption algorithm `alg` and `key`. The key may be a raw key
+// (e.g. *rsa.PublicKey) or a jwk.Key.
+//
+// `headers` is used as the per-recipient (unprotected) header. It may be nil.
+// It is copied by `jwe.EncryptMulti()`, and is not modified.
+// If `key` is a jwk.Key with a "kid" and `headers` does not specify one,
+// the key ID is added to the recipient header.
+func WithKey(alg jwa.KeyEncryptionAlgorithm, key interface{}, headers Headers) EncryptOption {
+	return &encryptOption{option.New(identRecipientKey{}, &recipientKey{
+		alg:     alg,
+		key:     key,
+		headers: headers,
+	})}
+}
+
+// WithStrictKeyUsage specifies if a jwk.Key may only be used to encrypt
+// or decrypt if its "use", "key_ops", and "alg" fields permit it. This
+// overrides the global setting specified using `jwk.WithStrictKeyUsage()`
+// for a single call.
+func WithStrictKeyUsage(v bool) EncryptDecryptOption {
+	return &encryptDecryptOption{option.New(identStrictKeyUsage{}, v)}
+}
+
+type identCriticalHandler struct{}
+
+type criticalHandlerPair struct {
+	name    string
+	handler CriticalHandler
+}
+
+// WithCriticalHandler specifies a handler for the header extension `name`
+// listed in the "crit" header field, for a single call to `jwe.Decrypt()`,
+// `jwe.DecryptSet()`, or `jwe.NewDecryptReader()`. Handlers specified
+// using this option take precedence over those registered using
+// `jwe.RegisterCriticalHandler()`.
+func WithCriticalHandler(name string, h CriticalHandler) DecryptOption {
+	return &decryptOption{option.New(identCriticalHandler{}, &criticalHandlerPair{name: name, handler: h})}
+}
@@ -0,0 +1,616 @@
+package jwe
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwe/internal/cipher"
+	"github.com/lestrrat-go/jwx/jwe/internal/content_crypt"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// maxInMemorySpoolSize is the maximum size of ciphertext that
+// NewDecryptReader holds in memory while authenticating it. Anything
+// larger is written to a temporary file.
+const maxInMemorySpoolSize = 1 << 20
+
+type encryptWriter struct {
+	dst    io.Writer
+	b64    io.WriteCloser
+	enc    cipher.StreamEncrypter
+	zw     *flate.Writer
+	w      io.Writer
+	closed bool
+}
+
+// NewEncryptWriter creates an io.WriteCloser that encrypts everything
+// written to it, and writes the resulting JWE message in JSON
+// serialization to `dst`. The content is encrypted (and optionally
+// compressed) as it is written, so the payload never needs to be held
+// in memory in its entirety.
+//
+// Recipients and protected headers are specified the same way as
+// `jwe.EncryptMulti()`, using `jwe.WithKey()` and `jwe.WithProtectedHeaders()`.
+// Only AES-GCM and AES-CBC-HMAC-SHA2 content encryption algorithms are
+// supported.
+//
+// The protected header, recipients, and the initialization vector are
+// written to `dst` immediately. The ciphertext is written as the content
+// is encrypted, and the authentication tag is written when the writer is
+// closed. You MUST call Close() for the message to be complete. Closing
+// the writer does not close `dst`.
+func NewEncryptWriter(dst io.Writer, contentalg jwa.ContentEncryptionAlgorithm, compressalg jwa.CompressionAlgorithm, options ...EncryptOption) (io.WriteCloser, error) {
+	encctx := getEncryptCtx()
+	defer releaseEncryptCtx(encctx)
+
+	contentcrypt, err := setupMultiEncryptCtx(encctx, contentalg, compressalg, options)
+	if err != nil {
+		return nil, err
+	}
+
+	cek, recipients, err := encctx.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	// If there's only one recipient, you want to include that in the
+	// protected header, just like jwe.Encrypt does
+	protected := encctx.protected
+	if len(recipients) == 1 {
+		h, err := protected.Merge(context.TODO(), recipients[0].Headers())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to merge protected headers")
+		}
+		protected = h
+	}
+
+	aad, err := protected.Encode()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to base64 encode protected headers")
+	}
+
+	b64 := base64.NewEncoder(dst)
+	iv, enc, err := contentcrypt.NewStreamEncrypter(cek, aad, b64)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create stream encrypter`)
+	}
+
+	buf := pool.GetBytesBuffer()
+	defer pool.ReleaseBytesBuffer(buf)
+
+	fmt.Fprintf(buf, `{%#v:%#v`, ProtectedHeadersKey, string(aad))
+	if len(recipients) == 1 { // Use flattened format
+		hdrbuf, err := json.Marshal(recipients[0].Headers())
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to encode %s field`, HeadersKey)
+		}
+		fmt.Fprintf(buf, `,%#v:`, HeadersKey)
+		buf.Write(hdrbuf)
+		if ek := recipients[0].EncryptedKey(); len(ek) > 0 {
+			fmt.Fprintf(buf, `,%#v:%#v`, EncryptedKeyKey, base64.EncodeToString(ek))
+		}
+	} else {
+		rbuf, err := json.Marshal(recipients)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to encode %s field`, RecipientsKey)
+		}
+		fmt.Fprintf(buf, `,%#v:`, RecipientsKey)
+		buf.Write(rbuf)
+	}
+	fmt.Fprintf(buf, `,%#v:%#v,%#v:"`, InitializationVectorKey, base64.EncodeToString(iv), CipherTextKey)
+
+	if _, err := dst.Write(buf.Bytes()); err != nil {
+		return nil, errors.Wrap(err, `failed to write message headers`)
+	}
+
+	w := &encryptWriter{
+		dst: dst,
+		b64: b64,
+		enc: enc,
+		w:   enc,
+	}
+	if compressalg != jwa.NoCompress {
+		zw, err := flate.NewWriter(enc, compressionLevel)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to create compression writer`)
+		}
+		w.zw = zw
+		w.w = zw
+	}
+	return w, nil
+}
+
+func (w *encryptWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New(`write to closed encrypt writer`)
+	}
+	return w.w.Write(p)
+}
+
+// Close flushes the remaining content, and writes the authentication
+// tag to complete the message
+func (w *encryptWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	if w.zw != nil {
+		if err := w.zw.Close(); err != nil {
+			return errors.Wrap(err, "failed to close compression writer")
+		}
+	}
+	if err := w.enc.Close(); err != nil {
+		return errors.Wrap(err, `failed to close stream encrypter`)
+	}
+	if err := w.b64.Close(); err != nil {
+		return errors.Wrap(err, `failed to flush ciphertext`)
+	}
+
+	if _, err := fmt.Fprintf(w.dst, `",%#v:%#v}`, TagKey, base64.EncodeToString(w.enc.Tag())); err != nil {
+		return errors.Wrap(err, `failed to write tag`)
+	}
+	return nil
+}
+
+type decryptReader struct {
+	io.Reader
+	spool *spool
+	zr    io.ReadCloser
+}
+
+func (r *decryptReader) Close() error {
+	if r.zr != nil {
+		_ = r.zr.Close()
+	}
+	return r.spool.Close()
+}
+
+// NewDecryptReader reads a JWE message in JSON serialization from `src`,
+// and returns an io.ReadCloser from which the decrypted (and uncompressed)
+// content can be read. `alg` and `key` are used in the same way as in
+// `jwe.Decrypt()`.
+//
+// The ciphertext is authenticated in its entirety before any of the
+// plaintext is made available: if NewDecryptReader returns without an
+// error, the content has been verified. To do this without holding the
+// entire message in memory, the ciphertext is spooled while the message
+// is being read. Ciphertexts larger than 1MB are spooled to a temporary
+// file, which is removed when the returned reader is closed. You MUST
+// call Close() on the returned reader.
+//
+// When the message contains multiple recipients, the first recipient
+// whose content encryption key can be decrypted using `alg` and `key`
+// is used.
+func NewDecryptReader(src io.Reader, alg jwa.KeyEncryptionAlgorithm, key interface{}, options ...DecryptOption) (io.ReadCloser, error) {
+	var dst *Message
+	var critical map[string]CriticalHandler
+	strict := jwk.StrictKeyUsage()
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identMessage{}:
+			dst = option.Value().(*Message)
+		case identCriticalHandler{}:
+			critical = option.Value().(*criticalHandlerPair).addTo(critical)
+		case identStrictKeyUsage{}:
+			strict = option.Value().(bool)
+		}
+	}
+
+	if strict {
+		if err := validateDecryptionKey(key, alg); err != nil {
+			return nil, err
+		}
+	}
+
+	var s spool
+	p := &streamParser{rdr: bufio.NewReader(src)}
+	hdrbuf, err := p.readMessage(&s)
+	if err != nil {
+		_ = s.Close()
+		return nil, errors.Wrap(err, `failed to parse message`)
+	}
+
+	msg := NewMessage()
+	msg.storeProtectedHeaders = true
+	if err := json.Unmarshal(hdrbuf, msg); err != nil {
+		_ = s.Close()
+		return nil, errors.Wrap(err, `failed to parse message`)
+	}
+
+	r, err := decryptSpooledContent(msg, &s, alg, key, critical)
+	if err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	if dst != nil {
+		*dst = *msg
+		dst.rawProtectedHeaders = nil
+		dst.storeProtectedHeaders = false
+	}
+
+	return r, nil
+}
+
+// decryptSpooledContent authenticates the ciphertext stored in `s`, and
+// creates a reader that decrypts it.
+func decryptSpooledContent(msg *Message, s *spool, alg jwa.KeyEncryptionAlgorithm, key interface{}, critical map[string]CriticalHandler) (*decryptReader, error) {
+	md, err := newMessageDecrypter(msg, critical)
+	if err != nil {
+		return nil, err
+	}
+
+	var cek []byte
+	var h2 Headers
+	var lastError error
+	for _, recipient := range md.recipients {
+		if recipient.Headers().Algorithm() != alg {
+			continue
+		}
+
+		dec, h, err := md.newDecrypter(recipient, alg, key)
+		if err != nil {
+			lastError = err
+			continue
+		}
+
+		v, err := dec.DecryptKey(recipient.EncryptedKey())
+		if err != nil {
+			lastError = errors.Wrap(err, `failed to decrypt key`)
+			continue
+		}
+		cek = v
+		h2 = h
+		break
+	}
+
+	if cek == nil {
+		if lastError != nil {
+			return nil, errors.Errorf(`failed to find matching recipient to decrypt key (last error = %s)`, lastError)
+		}
+		return nil, errors.New("failed to find matching recipient")
+	}
+
+	contentcrypt, err := content_crypt.NewGeneric(msg.protectedHeaders.ContentEncryption())
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create content cipher`)
+	}
+
+	computedAad := md.computedAad
+	if md.aad != nil {
+		computedAad = make([]byte, 0, len(md.computedAad)+1+len(md.aad))
+		computedAad = append(append(append(computedAad, md.computedAad...), '.'), md.aad...)
+	}
+
+	auth, err := contentcrypt.NewStreamAuthenticator(cek, msg.initializationVector, computedAad)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create stream authenticator`)
+	}
+
+	ciphertext, err := s.reader()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(auth, ciphertext); err != nil {
+		return nil, errors.Wrap(err, `failed to authenticate ciphertext`)
+	}
+	if err := auth.Verify(msg.tag); err != nil {
+		return nil, errors.Wrap(err, `failed to decrypt payload`)
+	}
+
+	if err := md.handleCritical(); err != nil {
+		return nil, err
+	}
+
+	ciphertext, err = s.reader()
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := contentcrypt.NewStreamDecrypter(cek, msg.initializationVector, ciphertext)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create stream decrypter`)
+	}
+
+	r := &decryptReader{
+		Reader: plaintext,
+		spool:  s,
+	}
+	if h2.Compression() == jwa.Deflate {
+		r.zr = flate.NewReader(plaintext)
+		r.Reader = r.zr
+	}
+	return r, nil
+}
+
+// spool holds the ciphertext while it is being authenticated. Small
+// ciphertexts are kept in memory, larger ones are written to a
+// temporary file
+type spool struct {
+	buf  bytes.Buffer
+	file *os.File
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && s.buf.Len()+len(p) > maxInMemorySpoolSize {
+		f, err := ioutil.TempFile("", "jwe-stream-")
+		if err != nil {
+			return 0, errors.Wrap(err, `failed to create temporary file`)
+		}
+		s.file = f
+		if _, err := f.Write(s.buf.Bytes()); err != nil {
+			return 0, errors.Wrap(err, `failed to write to temporary file`)
+		}
+		s.buf.Reset()
+	}
+
+	if s.file != nil {
+		return s.file.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+func (s *spool) reader() (io.Reader, error) {
+	if s.file == nil {
+		return bytes.NewReader(s.buf.Bytes()), nil
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.Wrap(err, `failed to rewind temporary file`)
+	}
+	return bufio.NewReader(s.file), nil
+}
+
+func (s *spool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	err := s.file.Close()
+	_ = os.Remove(name)
+	s.file = nil
+	return err
+}
+
+// streamParser reads a JWE message in JSON serialization, without
+// reading the ciphertext into memory
+type streamParser struct {
+	rdr *bufio.Reader
+}
+
+// readMessage reads the JSON object. The ciphertext is decoded and
+// written to `s`, and the rest of the members are returned as a JSON object.
+func (p *streamParser) readMessage(s *spool) ([]byte, error) {
+	if err := p.expect('{'); err != nil {
+		return nil, err
+	}
+
+	var hdrbuf bytes.Buffer
+	var foundCipherText bool
+	hdrbuf.WriteByte('{')
+	for {
+		key, err := p.readKey()
+		if err != nil {
+			return nil, err
+		}
+
+		if key == CipherTextKey {
+			if foundCipherText {
+				return nil, errors.Errorf(`duplicate %s`, CipherTextKey)
+			}
+			foundCipherText = true
+			if err := p.expect('"'); err != nil {
+				return nil, errors.Wrapf(err, `invalid %s`, CipherTextKey)
+			}
+			if _, err := io.Copy(s, base64.NewDecoder(&stringReader{rdr: p.rdr})); err != nil {
+				return nil, errors.Wrapf(err, `failed to read %s`, CipherTextKey)
+			}
+		} else {
+			var value bytes.Buffer
+			if err := p.readValue(&value); err != nil {
+				return nil, errors.Wrapf(err, `failed to read value for %s`, key)
+			}
+
+			keybuf, err := json.Marshal(key)
+			if err != nil {
+				return nil, errors.Wrapf(err, `failed to encode key %s`, key)
+			}
+			if hdrbuf.Len() > 1 {
+				hdrbuf.WriteByte(',')
+			}
+			hdrbuf.Write(keybuf)
+			hdrbuf.WriteByte(':')
+			hdrbuf.Write(value.Bytes())
+		}
+
+		c, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case ',':
+		case '}':
+			if !foundCipherText {
+				return nil, errors.Errorf(`%s not found`, CipherTextKey)
+			}
+			hdrbuf.WriteByte('}')
+			return hdrbuf.Bytes(), nil
+		default:
+			return nil, errors.Errorf(`unexpected character %q`, c)
+		}
+	}
+}
+
+// next returns the next non-whitespace byte
+func (p *streamParser) next() (byte, error) {
+	for {
+		c, err := p.rdr.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return c, nil
+	}
+}
+
+func (p *streamParser) expect(want byte) error {
+	c, err := p.next()
+	if err != nil {
+		return err
+	}
+	if c != want {
+		return errors.Errorf(`expected %q, got %q`, want, c)
+	}
+	return nil
+}
+
+// readKey reads an object key, and the colon that follows it
+func (p *streamParser) readKey() (string, error) {
+	if err := p.expect('"'); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	if err := p.readString(&buf); err != nil {
+		return "", err
+	}
+
+	var key string
+	if err := json.Unmarshal(buf.Bytes(), &key); err != nil {
+		return "", errors.Wrap(err, `failed to decode key`)
+	}
+
+	if err := p.expect(':'); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// readValue reads a single JSON value into `buf`, without decoding it
+func (p *streamParser) readValue(buf *bytes.Buffer) error {
+	c, err := p.next()
+	if err != nil {
+		return err
+	}
+	buf.WriteByte(c)
+
+	switch c {
+	case '"':
+		return p.readString(buf)
+	case '{', '[':
+		for depth := 1; depth > 0; {
+			c, err := p.rdr.ReadByte()
+			if err != nil {
+				return err
+			}
+			buf.WriteByte(c)
+			switch c {
+			case '"':
+				if err := p.readString(buf); err != nil {
+					return err
+				}
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+		return nil
+	default:
+		// numbers and literals
+		for {
+			c, err := p.rdr.ReadByte()
+			if err != nil {
+				return err
+			}
+			switch c {
+			case ',', '}', ']', ' ', '\t', '\r', '\n':
+				return p.rdr.UnreadByte()
+			}
+			buf.WriteByte(c)
+		}
+	}
+}
+
+// readString reads the remainder of a JSON string, whose opening
+// quote has already been consumed
+func (p *streamParser) readString(buf *bytes.Buffer) error {
+	for {
+		c, err := p.rdr.ReadByte()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(c)
+		switch c {
+		case '\\':
+			c, err := p.rdr.ReadByte()
+			if err != nil {
+				return err
+			}
+			buf.WriteByte(c)
+		case '"':
+			return nil
+		}
+	}
+}
+
+// stringReader reads the contents of a JSON string up to its closing
+// quote, which is consumed. The opening quote must already have been
+// consumed.
+type stringReader struct {
+	rdr  *bufio.Reader
+	done bool
+}
+
+func (r *stringReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+
+	if r.rdr.Buffered() == 0 {
+		if _, err := r.rdr.Peek(1); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+
+	chunk, _ := r.rdr.Peek(r.rdr.Buffered())
+	if len(chunk) > len(p) {
+		chunk = chunk[:len(p)]
+	}
+
+	if i := bytes.IndexByte(chunk, '"'); i >= 0 {
+		n := copy(p, chunk[:i])
+		_, _ = r.rdr.Discard(i + 1)
+		r.done = true
+		if n == 0 {
+			return 0, io.EOF
+		}
+		return n, nil
+	}
+
+	n := copy(p, chunk)
+	_, _ = r.rdr.Discard(n)
+	return n, nil
+}
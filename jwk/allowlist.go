@@ -0,0 +1,110 @@
+package jwk
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Allowlist is an interface for objects that determine if a URL may
+// be fetched. It is used with `jwk.WithFetchAllowlist()`, and by
+// components that fetch resources whose URLs are specified in
+// untrusted input, such as the "jku" header field of a JWS message.
+type Allowlist interface {
+	IsAllowed(string) bool
+}
+
+// AllowlistFunc is an Allowlist represented by a function
+type AllowlistFunc func(string) bool
+
+func (f AllowlistFunc) IsAllowed(u string) bool {
+	return f(u)
+}
+
+// MapAllowlist is an Allowlist that only allows URLs that exactly
+// match one of the registered URLs.
+type MapAllowlist struct {
+	mu   sync.RWMutex
+	urls map[string]struct{}
+}
+
+// NewMapAllowlist creates a new, empty MapAllowlist
+func NewMapAllowlist() *MapAllowlist {
+	return &MapAllowlist{
+		urls: make(map[string]struct{}),
+	}
+}
+
+// Add adds `u` to the list of allowed URLs
+func (l *MapAllowlist) Add(u string) *MapAllowlist {
+	l.mu.Lock()
+	l.urls[u] = struct{}{}
+	l.mu.Unlock()
+	return l
+}
+
+func (l *MapAllowlist) IsAllowed(u string) bool {
+	l.mu.RLock()
+	_, ok := l.urls[u]
+	l.mu.RUnlock()
+	return ok
+}
+
+type allowlistClient struct {
+	client    HTTPClient
+	allowlist Allowlist
+}
+
+// NewAllowlistClient wraps `cl` so that every URL it fetches, including
+// the targets of redirects, must be allowed by `l`. Otherwise an
+// allowlisted server could redirect the request to any other server.
+//
+// If `cl` is an *http.Client, a copy of it is used that refuses to follow
+// redirects to URLs that are not allowed, so that those URLs are never
+// requested. Other clients may follow redirects on their own, so the
+// final URL of their responses is checked instead.
+//
+// `jwk.Fetch()` and `jwk.AutoRefresh` wrap their client using this
+// function when `jwk.WithFetchAllowlist()` is specified.
+func NewAllowlistClient(cl HTTPClient, l Allowlist) HTTPClient {
+	if hc, ok := cl.(*http.Client); ok {
+		copied := *hc
+		checkRedirect := hc.CheckRedirect
+		copied.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+			if !l.IsAllowed(req.URL.String()) {
+				return errors.Errorf(`redirect to %q is not allowed by the allowlist`, req.URL)
+			}
+			if checkRedirect != nil {
+				return checkRedirect(req, via)
+			}
+			// Same as the default policy of http.Client
+			if len(via) >= 10 {
+				return errors.New(`stopped after 10 redirects`)
+			}
+			return nil
+		}
+		cl = &copied
+	}
+	return &allowlistClient{
+		client:    cl,
+		allowlist: l,
+	}
+}
+
+func (c *allowlistClient) Do(req *http.Request) (*http.Response, error) {
+	if !c.allowlist.IsAllowed(req.URL.String()) {
+		return nil, errors.Errorf(`url %q is not allowed by the allowlist`, req.URL)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Request != nil && res.Request.URL != nil && !c.allowlist.IsAllowed(res.Request.URL.String()) {
+		res.Body.Close()
+		return nil, errors.Errorf(`redirect to %q is not allowed by the allowlist`, res.Request.URL)
+	}
+	return res, nil
+}
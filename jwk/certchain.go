@@ -1,6 +1,10 @@
 package jwk
 
 import (
+	"crypto"
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/subtle"
 	"crypto/x509"
 
 	"github.com/lestrrat-go/jwx/internal/json"
@@ -83,3 +87,91 @@ func (c *CertificateChain) Accept(v interface{}) error {
 	}
 	return nil
 }
+
+// VerifyCertificateChain validates the certificate chain stored in the
+// "x5c" field of `key` against the trusted root certificates in `roots`,
+// and returns the verified leaf certificate. The current time, key usages,
+// and DNS name to check are taken from `opts`. As with `crypto/x509`, an
+// empty `opts.KeyUsages` requires `x509.ExtKeyUsageServerAuth`; use
+// `x509.ExtKeyUsageAny` to accept any extended key usage.
+//
+// `roots` is required, and replaces `opts.Roots`. The system root pool
+// is never used implicitly, as that would allow anybody holding a publicly
+// trusted certificate to produce a valid chain. If you really want to
+// trust the system roots, pass the pool returned by `x509.SystemCertPool()`.
+//
+// When `opts.Intermediates` is nil, the certificates following the leaf
+// in "x5c" are used as intermediates. Otherwise only the pool given in
+// `opts.Intermediates` is used.
+//
+// In addition to validating the chain, the public key of the leaf
+// certificate must match the key material of `key`, and if `key` has
+// the "x5t" and/or "x5t#S256" fields, they must match the thumbprints
+// of the leaf certificate.
+func VerifyCertificateChain(key Key, roots *x509.CertPool, opts x509.VerifyOptions) (*x509.Certificate, error) {
+	if roots == nil {
+		return nil, errors.New(`root certificate pool must be specified`)
+	}
+	opts.Roots = roots
+
+	chain := key.X509CertChain()
+	if len(chain) == 0 {
+		return nil, errors.New(`key does not contain a certificate chain ("x5c")`)
+	}
+
+	leaf := chain[0]
+	if opts.Intermediates == nil {
+		opts.Intermediates = x509.NewCertPool()
+		for _, cert := range chain[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+
+	if _, err := leaf.Verify(opts); err != nil {
+		return nil, errors.Wrap(err, `failed to verify certificate chain`)
+	}
+
+	if err := checkCertificateMatchesKey(leaf, key); err != nil {
+		return nil, err
+	}
+	return leaf, nil
+}
+
+func checkCertificateMatchesKey(cert *x509.Certificate, key Key) error {
+	certkey, err := New(cert.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, `failed to create key from certificate`)
+	}
+
+	pubkey, err := PublicKeyOf(key)
+	if err != nil {
+		return errors.Wrap(err, `failed to get public key`)
+	}
+
+	expected, err := certkey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return errors.Wrap(err, `failed to compute thumbprint of certificate key`)
+	}
+	actual, err := pubkey.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return errors.Wrap(err, `failed to compute thumbprint of key`)
+	}
+	if subtle.ConstantTimeCompare(expected, actual) != 1 {
+		return errors.New(`public key of the leaf certificate does not match the key`)
+	}
+
+	if v := key.X509CertThumbprint(); v != "" {
+		sum := sha1.Sum(cert.Raw) //nolint:gosec
+		if v != base64.EncodeToString(sum[:]) {
+			return errors.New(`"x5t" does not match the leaf certificate`)
+		}
+	}
+
+	if v := key.X509CertThumbprintS256(); v != "" {
+		sum := sha256.Sum256(cert.Raw)
+		if v != base64.EncodeToString(sum[:]) {
+			return errors.New(`"x5t#S256" does not match the leaf certificate`)
+		}
+	}
+	return nil
+}
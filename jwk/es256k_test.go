@@ -7,6 +7,7 @@ import (
 
 	"github.com/lestrrat-go/jwx/internal/ecutil"
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,4 +15,12 @@ func TestES256K(t *testing.T) {
 	if !assert.True(t, ecutil.IsAvailable(jwa.Secp256k1), `jwa.Secp256k1 should be available`) {
 		return
 	}
+
+	key, err := jwk.Generate(jwa.EC, jwk.WithCurve(jwa.Secp256k1))
+	if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+		return
+	}
+	if !assert.Equal(t, jwa.Secp256k1, key.(jwk.ECDSAPrivateKey).Crv(), `curve should be secp256k1`) {
+		return
+	}
 }
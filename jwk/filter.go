@@ -0,0 +1,165 @@
+package jwk
+
+import (
+	"bytes"
+	"crypto"
+
+	"github.com/lestrrat-go/jwx/jwa"
+)
+
+// KeyFilter selects keys in a jwk.Set. Pass filters to `jwk.Filter()`
+// or `jwk.FindKeys()`.
+//
+// The filters for "use", "key_ops", and "alg" select the keys that may
+// be used for the given purpose: keys that do not specify these fields
+// are not restricted, and therefore match. The filters for the other
+// fields only match keys that have the field set to the given value.
+type KeyFilter interface {
+	Match(Key) bool
+}
+
+// KeyFilterFunc is a KeyFilter represented by a function
+type KeyFilterFunc func(Key) bool
+
+func (f KeyFilterFunc) Match(key Key) bool {
+	return f(key)
+}
+
+// MatchKeyType returns a KeyFilter that matches keys of the given "kty"
+func MatchKeyType(kty jwa.KeyType) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		return key.KeyType() == kty
+	})
+}
+
+// MatchKeyID returns a KeyFilter that matches keys with the given "kid"
+func MatchKeyID(kid string) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		return key.KeyID() == kid
+	})
+}
+
+// MatchAlgorithm returns a KeyFilter that matches keys whose "alg" is
+// `alg`, or that do not specify "alg"
+func MatchAlgorithm(alg string) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		keyalg := key.Algorithm()
+		return keyalg == "" || keyalg == alg
+	})
+}
+
+// MatchKeyUsage returns a KeyFilter that matches keys whose "use" is
+// `use`, or that do not specify "use"
+func MatchKeyUsage(use KeyUsageType) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		usage := key.KeyUsage()
+		return usage == "" || usage == use.String()
+	})
+}
+
+// MatchKeyOps returns a KeyFilter that matches keys whose "key_ops"
+// contains all of `ops`, or that do not specify "key_ops"
+func MatchKeyOps(ops ...KeyOperation) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		keyops := key.KeyOps()
+		if len(keyops) == 0 {
+			return true
+		}
+		for _, op := range ops {
+			if !keyops.contains(op) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func (ops KeyOperationList) contains(op KeyOperation) bool {
+	for _, v := range ops {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchCurve returns a KeyFilter that matches EC and OKP keys on the
+// curve `crv`
+func MatchCurve(crv jwa.EllipticCurveAlgorithm) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		switch key := key.(type) {
+		case ECDSAPrivateKey:
+			return key.Crv() == crv
+		case ECDSAPublicKey:
+			return key.Crv() == crv
+		case OKPPrivateKey:
+			return key.Crv() == crv
+		case OKPPublicKey:
+			return key.Crv() == crv
+		default:
+			return false
+		}
+	})
+}
+
+// MatchThumbprint returns a KeyFilter that matches keys whose thumbprint
+// computed using `hash` (RFC 7638) is `thumbprint`. Private keys have
+// the same thumbprint as their public keys.
+func MatchThumbprint(hash crypto.Hash, thumbprint []byte) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		tp, err := key.Thumbprint(hash)
+		return err == nil && bytes.Equal(tp, thumbprint)
+	})
+}
+
+// MatchX509CertThumbprint returns a KeyFilter that matches keys with
+// the given "x5t"
+func MatchX509CertThumbprint(x5t string) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		return key.X509CertThumbprint() == x5t
+	})
+}
+
+// MatchX509CertThumbprintS256 returns a KeyFilter that matches keys
+// with the given "x5t#S256"
+func MatchX509CertThumbprintS256(x5t string) KeyFilter {
+	return KeyFilterFunc(func(key Key) bool {
+		return key.X509CertThumbprintS256() == x5t
+	})
+}
+
+func matchAll(key Key, filters []KeyFilter) bool {
+	for _, f := range filters {
+		if !f.Match(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// FindKeys returns the keys in `set` that match all of the filters,
+// in the order they appear in the set.
+func FindKeys(set Set, filters ...KeyFilter) []Key {
+	var keys []Key
+	n := set.Len()
+	for i := 0; i < n; i++ {
+		key, ok := set.Get(i)
+		if !ok {
+			continue
+		}
+		if matchAll(key, filters) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Filter returns a new jwk.Set containing the keys in `set` that match
+// all of the filters. The keys are shared with the original set.
+func Filter(set Set, filters ...KeyFilter) Set {
+	newSet := NewSet()
+	for _, key := range FindKeys(set, filters...) {
+		newSet.Add(key)
+	}
+	return newSet
+}
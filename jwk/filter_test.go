@@ -0,0 +1,155 @@
+package jwk_test
+
+import (
+	"crypto"
+	"testing"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func keyIDs(keys []jwk.Key) []string {
+	var kids []string
+	for _, key := range keys {
+		kids = append(kids, key.KeyID())
+	}
+	return kids
+}
+
+func TestFilter(t *testing.T) {
+	generate := func(kty jwa.KeyType, kid string, fields map[string]interface{}, options ...jwk.GenerateOption) jwk.Key {
+		key, err := jwk.Generate(kty, append(options, jwk.WithKeyID(kid))...)
+		if err != nil {
+			t.Fatalf(`jwk.Generate failed: %s`, err)
+		}
+		for k, v := range fields {
+			if err := key.Set(k, v); err != nil {
+				t.Fatalf(`key.Set failed: %s`, err)
+			}
+		}
+		return key
+	}
+
+	set := jwk.NewSet()
+	set.Add(generate(jwa.RSA, `rsa-sig`, map[string]interface{}{jwk.KeyUsageKey: jwk.ForSignature, jwk.AlgorithmKey: jwa.RS256}, jwk.WithKeySize(1024)))
+	set.Add(generate(jwa.RSA, `rsa-enc`, map[string]interface{}{jwk.KeyUsageKey: jwk.ForEncryption, jwk.AlgorithmKey: jwa.RSA_OAEP}, jwk.WithKeySize(1024)))
+	set.Add(generate(jwa.EC, `ec-p256`, map[string]interface{}{jwk.KeyOpsKey: jwk.KeyOperationList{jwk.KeyOpSign, jwk.KeyOpVerify}}))
+	set.Add(generate(jwa.EC, `ec-p384`, map[string]interface{}{jwk.KeyOpsKey: jwk.KeyOperationList{jwk.KeyOpVerify}}, jwk.WithCurve(jwa.P384)))
+	set.Add(generate(jwa.OKP, `ed25519`, map[string]interface{}{jwk.X509CertThumbprintKey: `x5t`, jwk.X509CertThumbprintS256Key: `x5t#S256`}))
+	set.Add(generate(jwa.OctetSeq, `oct`, nil))
+
+	okpkey, _ := set.LookupKeyID(`ed25519`)
+	pubkey, err := jwk.PublicKeyOf(okpkey)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	tp, err := pubkey.Thumbprint(crypto.SHA256)
+	if !assert.NoError(t, err, `pubkey.Thumbprint should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name     string
+		Filters  []jwk.KeyFilter
+		Expected []string
+	}{
+		{
+			Name:     `No filters`,
+			Expected: []string{`rsa-sig`, `rsa-enc`, `ec-p256`, `ec-p384`, `ed25519`, `oct`},
+		},
+		{
+			Name:     `kty`,
+			Filters:  []jwk.KeyFilter{jwk.MatchKeyType(jwa.RSA)},
+			Expected: []string{`rsa-sig`, `rsa-enc`},
+		},
+		{
+			Name:     `kid`,
+			Filters:  []jwk.KeyFilter{jwk.MatchKeyID(`ec-p384`)},
+			Expected: []string{`ec-p384`},
+		},
+		{
+			Name:     `use`,
+			Filters:  []jwk.KeyFilter{jwk.MatchKeyUsage(jwk.ForSignature)},
+			Expected: []string{`rsa-sig`, `ec-p256`, `ec-p384`, `ed25519`, `oct`},
+		},
+		{
+			Name:     `alg`,
+			Filters:  []jwk.KeyFilter{jwk.MatchAlgorithm(jwa.RS256.String())},
+			Expected: []string{`rsa-sig`, `ec-p256`, `ec-p384`, `ed25519`, `oct`},
+		},
+		{
+			Name:     `key_ops`,
+			Filters:  []jwk.KeyFilter{jwk.MatchKeyOps(jwk.KeyOpSign)},
+			Expected: []string{`rsa-sig`, `rsa-enc`, `ec-p256`, `ed25519`, `oct`},
+		},
+		{
+			Name:     `crv`,
+			Filters:  []jwk.KeyFilter{jwk.MatchCurve(jwa.P384)},
+			Expected: []string{`ec-p384`},
+		},
+		{
+			Name:     `crv (OKP)`,
+			Filters:  []jwk.KeyFilter{jwk.MatchCurve(jwa.Ed25519)},
+			Expected: []string{`ed25519`},
+		},
+		{
+			Name:     `Thumbprint of the public key`,
+			Filters:  []jwk.KeyFilter{jwk.MatchThumbprint(crypto.SHA256, tp)},
+			Expected: []string{`ed25519`},
+		},
+		{
+			Name:     `x5t`,
+			Filters:  []jwk.KeyFilter{jwk.MatchX509CertThumbprint(`x5t`)},
+			Expected: []string{`ed25519`},
+		},
+		{
+			Name:     `x5t#S256`,
+			Filters:  []jwk.KeyFilter{jwk.MatchX509CertThumbprintS256(`x5t#S256`)},
+			Expected: []string{`ed25519`},
+		},
+		{
+			Name:     `Multiple filters`,
+			Filters:  []jwk.KeyFilter{jwk.MatchKeyType(jwa.EC), jwk.MatchKeyOps(jwk.KeyOpSign)},
+			Expected: []string{`ec-p256`},
+		},
+		{
+			Name: `Custom filter`,
+			Filters: []jwk.KeyFilter{jwk.KeyFilterFunc(func(key jwk.Key) bool {
+				return key.KeyType() != jwa.OctetSeq
+			}), jwk.MatchKeyUsage(jwk.ForEncryption)},
+			Expected: []string{`rsa-enc`, `ec-p256`, `ec-p384`, `ed25519`},
+		},
+		{
+			Name:    `No matches`,
+			Filters: []jwk.KeyFilter{jwk.MatchKeyID(`unknown`)},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			if !assert.Equal(t, tc.Expected, keyIDs(jwk.FindKeys(set, tc.Filters...)), `jwk.FindKeys should return matching keys in order`) {
+				return
+			}
+
+			filtered := jwk.Filter(set, tc.Filters...)
+			if !assert.Equal(t, len(tc.Expected), filtered.Len(), `jwk.Filter should return a set with the matching keys`) {
+				return
+			}
+			for i, kid := range tc.Expected {
+				key, ok := filtered.Get(i)
+				if !assert.True(t, ok, `filtered.Get should succeed`) {
+					return
+				}
+				if !assert.Equal(t, kid, key.KeyID(), `key ID should match`) {
+					return
+				}
+			}
+		})
+	}
+
+	if !assert.Equal(t, 6, set.Len(), `original set should not be modified`) {
+		return
+	}
+}
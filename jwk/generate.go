@@ -0,0 +1,166 @@
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+
+	"github.com/cloudflare/circl/sign/ed448"
+	"github.com/lestrrat-go/jwx/internal/ecutil"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
+	"github.com/pkg/errors"
+)
+
+// Generate creates a new private key of the given key type.
+//
+//   * jwa.RSA creates an RSA key of the size given by `jwk.WithKeySize()` (default 2048 bits)
+//   * jwa.EC creates an ECDSA key on the curve given by `jwk.WithCurve()` (default P-256).
+//     Any curve that is available can be used, including secp256k1 when the
+//     `jwx_es256k` build tag is specified
+//   * jwa.OKP creates an Ed25519, Ed448, X25519, or X448 key, depending on the curve
+//     given by `jwk.WithCurve()` (default Ed25519)
+//   * jwa.OctetSeq creates a symmetric key of the size given by `jwk.WithKeySize()`
+//     (default 256 bits)
+//
+// The "alg", "use", "key_ops", and "kid" fields can be set using
+// `jwk.WithKeyAlgorithm()`, `jwk.WithKeyUsage()`, `jwk.WithKeyOps()`, and
+// `jwk.WithKeyID()`. Unless `jwk.WithKeyID()` is specified, the key ID is
+// computed from the thumbprint of the key, using the hash given by
+// `jwk.WithThumbprintHash()`.
+//
+// Use `jwk.PublicKeyOf()` to obtain the corresponding public key.
+func Generate(kty jwa.KeyType, options ...GenerateOption) (Key, error) {
+	var size int
+	var crv jwa.EllipticCurveAlgorithm
+	var rnd io.Reader = rand.Reader
+	var kid string
+	var hasKeyID bool
+	var thumbprintOptions []Option
+	fields := make(map[string]interface{})
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identKeySize{}:
+			size = option.Value().(int)
+		case identCurve{}:
+			crv = option.Value().(jwa.EllipticCurveAlgorithm)
+		case identRandReader{}:
+			rnd = option.Value().(io.Reader)
+		case identKeyID{}:
+			kid = option.Value().(string)
+			hasKeyID = true
+		case identThumbprintHash{}:
+			thumbprintOptions = append(thumbprintOptions, option)
+		case identKeyUsage{}:
+			fields[KeyUsageKey] = option.Value()
+		case identKeyOps{}:
+			fields[KeyOpsKey] = option.Value()
+		case identKeyAlgorithm{}:
+			fields[AlgorithmKey] = option.Value()
+		}
+	}
+
+	rawkey, err := generateRawKey(kty, size, crv, rnd)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := New(rawkey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create new JWK from raw key`)
+	}
+
+	for name, value := range fields {
+		if err := key.Set(name, value); err != nil {
+			return nil, errors.Wrapf(err, `failed to set field %s`, name)
+		}
+	}
+
+	if hasKeyID {
+		if err := key.Set(KeyIDKey, kid); err != nil {
+			return nil, errors.Wrapf(err, `failed to set field %s`, KeyIDKey)
+		}
+	} else {
+		if err := AssignKeyID(key, thumbprintOptions...); err != nil {
+			return nil, errors.Wrap(err, `failed to assign key ID`)
+		}
+	}
+	return key, nil
+}
+
+func generateRawKey(kty jwa.KeyType, size int, crv jwa.EllipticCurveAlgorithm, rnd io.Reader) (interface{}, error) {
+	switch kty {
+	case jwa.RSA:
+		if size == 0 {
+			size = 2048
+		}
+		v, err := rsa.GenerateKey(rnd, size)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate rsa private key`)
+		}
+		return v, nil
+	case jwa.EC:
+		if crv == "" {
+			crv = jwa.P256
+		}
+		ecrv, ok := ecutil.CurveForAlgorithm(crv)
+		if !ok {
+			return nil, errors.Errorf(`invalid elliptic curve for ECDSA: %s (expected one of %v)`, crv, ecutil.AvailableAlgorithms())
+		}
+		v, err := ecdsa.GenerateKey(ecrv, rnd)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to generate ECDSA private key`)
+		}
+		return v, nil
+	case jwa.OKP:
+		if crv == "" {
+			crv = jwa.Ed25519
+		}
+		switch crv {
+		case jwa.Ed25519:
+			_, priv, err := ed25519.GenerateKey(rnd)
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to generate ed25519 private key`)
+			}
+			return priv, nil
+		case jwa.Ed448:
+			_, priv, err := ed448.GenerateKey(rnd)
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to generate ed448 private key`)
+			}
+			return priv, nil
+		case jwa.X25519:
+			_, priv, err := x25519.GenerateKey(rnd)
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to generate x25519 private key`)
+			}
+			return priv, nil
+		case jwa.X448:
+			_, priv, err := x448.GenerateKey(rnd)
+			if err != nil {
+				return nil, errors.Wrap(err, `failed to generate x448 private key`)
+			}
+			return priv, nil
+		default:
+			return nil, errors.Errorf(`invalid elliptic curve for OKP: %s (expected %s/%s/%s/%s)`, crv, jwa.Ed25519, jwa.Ed448, jwa.X25519, jwa.X448)
+		}
+	case jwa.OctetSeq:
+		if size == 0 {
+			size = 256
+		}
+		if size < 0 || size%8 != 0 {
+			return nil, errors.Errorf(`invalid size for symmetric key: %d (must be a positive multiple of 8)`, size)
+		}
+		octets := make([]byte, size/8)
+		if _, err := io.ReadFull(rnd, octets); err != nil {
+			return nil, errors.Wrap(err, `failed to generate symmetric key`)
+		}
+		return octets, nil
+	default:
+		return nil, errors.Errorf(`invalid key type %s`, kty)
+	}
+}
@@ -0,0 +1,166 @@
+package jwk
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// SetProvider supplies the jwk.Set served by the handler created by
+// `jwk.NewHandler()`. A jwk.KeyRing can be used as a SetProvider.
+type SetProvider interface {
+	PublicSet() (Set, error)
+}
+
+// SetProviderFunc is a SetProvider represented by a function
+type SetProviderFunc func() (Set, error)
+
+func (f SetProviderFunc) PublicSet() (Set, error) {
+	return f()
+}
+
+type setHandler struct {
+	provider SetProvider
+	maxAge   time.Duration
+
+	mu           sync.Mutex
+	etag         string
+	lastModified time.Time
+}
+
+// NewHandler creates an http.Handler that serves the jwk.Set obtained
+// from `p`, for example at "/.well-known/jwks.json". The set is obtained
+// on each request, so changes to it are served immediately.
+//
+// Only the public keys are served: private keys are converted using
+// `jwk.PublicSetOf()`, and sets that contain symmetric keys are not
+// served at all.
+//
+// Responses have the "Cache-Control" header set according to
+// `jwk.WithCacheMaxAge()`, as well as "ETag" and "Last-Modified" headers,
+// and conditional requests using "If-None-Match" or "If-Modified-Since"
+// are answered with "304 Not Modified" when the set has not changed.
+// These work with the corresponding features of jwk.AutoRefresh.
+func NewHandler(p SetProvider, options ...HandlerOption) http.Handler {
+	maxAge := time.Hour
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identCacheMaxAge{}:
+			maxAge = option.Value().(time.Duration)
+		}
+	}
+
+	return &setHandler{
+		provider: p,
+		maxAge:   maxAge,
+	}
+}
+
+func (h *setHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		w.Header().Set(`Allow`, `GET, HEAD`)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	buf, err := h.marshalSet()
+	if err != nil {
+		http.Error(w, `failed to retrieve key set`, http.StatusInternalServerError)
+		return
+	}
+
+	etag, lastModified := h.version(buf)
+
+	hdr := w.Header()
+	if h.maxAge > 0 {
+		hdr.Set(`Cache-Control`, `public, max-age=`+strconv.FormatInt(int64(h.maxAge/time.Second), 10))
+	} else {
+		hdr.Set(`Cache-Control`, `no-cache`)
+	}
+	hdr.Set(`ETag`, etag)
+	hdr.Set(`Last-Modified`, lastModified.Format(http.TimeFormat))
+
+	if notModified(req, etag, lastModified) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	hdr.Set(`Content-Type`, `application/jwk-set+json`)
+	hdr.Set(`Content-Length`, strconv.Itoa(len(buf)))
+	w.WriteHeader(http.StatusOK)
+	if req.Method != http.MethodHead {
+		_, _ = w.Write(buf)
+	}
+}
+
+func (h *setHandler) marshalSet() ([]byte, error) {
+	set, err := h.provider.PublicSet()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to obtain key set`)
+	}
+
+	for i := 0; i < set.Len(); i++ {
+		key, _ := set.Get(i)
+		if key.KeyType() == jwa.OctetSeq {
+			return nil, errors.New(`symmetric keys cannot be published`)
+		}
+	}
+
+	pubset, err := PublicSetOf(set)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to obtain public keys`)
+	}
+
+	buf, err := json.Marshal(pubset)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to marshal key set`)
+	}
+	return buf, nil
+}
+
+// version returns the ETag of the serialized set, and the time at which
+// the handler first served it
+func (h *setHandler) version(buf []byte) (string, time.Time) {
+	sum := sha256.Sum256(buf)
+	etag := `"` + base64.EncodeToString(sum[:]) + `"`
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.etag != etag {
+		h.etag = etag
+		h.lastModified = time.Now().UTC().Truncate(time.Second)
+	}
+	return h.etag, h.lastModified
+}
+
+// notModified evaluates the conditional headers of the request, as
+// described in RFC 7232. If-Modified-Since is only consulted if
+// If-None-Match is not present
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if v := req.Header.Get(`If-None-Match`); v != "" {
+		for _, candidate := range strings.Split(v, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == `*` || strings.TrimPrefix(candidate, `W/`) == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	if v := req.Header.Get(`If-Modified-Since`); v != "" {
+		t, err := http.ParseTime(v)
+		if err == nil && !lastModified.After(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+package jwk_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	key, err := jwk.Generate(jwa.EC, jwk.WithKeyID(`ec`))
+	if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+		return
+	}
+	set := jwk.NewSet()
+	set.Add(key)
+
+	h := jwk.NewHandler(jwk.SetProviderFunc(func() (jwk.Set, error) {
+		return set, nil
+	}), jwk.WithCacheMaxAge(10*time.Minute))
+
+	serve := func(method string, hdrs map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, `/.well-known/jwks.json`, nil)
+		for k, v := range hdrs {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	res := serve(http.MethodGet, nil)
+	if !assert.Equal(t, http.StatusOK, res.Code, `status should be 200`) {
+		return
+	}
+	if !assert.Equal(t, `application/jwk-set+json`, res.Header().Get(`Content-Type`), `Content-Type should match`) {
+		return
+	}
+	if !assert.Equal(t, `public, max-age=600`, res.Header().Get(`Cache-Control`), `Cache-Control should match`) {
+		return
+	}
+	etag := res.Header().Get(`ETag`)
+	lastModified := res.Header().Get(`Last-Modified`)
+	if !assert.NotEmpty(t, etag, `ETag should be set`) {
+		return
+	}
+	if !assert.NotEmpty(t, lastModified, `Last-Modified should be set`) {
+		return
+	}
+
+	served, err := jwk.ParseString(res.Body.String())
+	if !assert.NoError(t, err, `jwk.ParseString should succeed`) {
+		return
+	}
+	servedKey, ok := served.LookupKeyID(`ec`)
+	if !assert.True(t, ok, `key should be served`) {
+		return
+	}
+	if _, ok := servedKey.(jwk.ECDSAPublicKey); !assert.True(t, ok, `only the public key should be served`) {
+		return
+	}
+	if !assert.NotContains(t, res.Body.String(), `"d"`, `private material should be stripped`) {
+		return
+	}
+
+	testcases := []struct {
+		Name    string
+		Method  string
+		Headers map[string]string
+		Status  int
+		Body    bool
+	}{
+		{
+			Name:    `If-None-Match matches`,
+			Method:  http.MethodGet,
+			Headers: map[string]string{`If-None-Match`: `"other", ` + etag},
+			Status:  http.StatusNotModified,
+		},
+		{
+			Name:    `If-None-Match does not match`,
+			Method:  http.MethodGet,
+			Headers: map[string]string{`If-None-Match`: `"other"`, `If-Modified-Since`: lastModified},
+			Status:  http.StatusOK,
+			Body:    true,
+		},
+		{
+			Name:    `If-Modified-Since is not older`,
+			Method:  http.MethodGet,
+			Headers: map[string]string{`If-Modified-Since`: lastModified},
+			Status:  http.StatusNotModified,
+		},
+		{
+			Name:    `If-Modified-Since is older`,
+			Method:  http.MethodGet,
+			Headers: map[string]string{`If-Modified-Since`: time.Unix(0, 0).UTC().Format(http.TimeFormat)},
+			Status:  http.StatusOK,
+			Body:    true,
+		},
+		{
+			Name:   `HEAD`,
+			Method: http.MethodHead,
+			Status: http.StatusOK,
+		},
+		{
+			Name:   `POST`,
+			Method: http.MethodPost,
+			Status: http.StatusMethodNotAllowed,
+			Body:   true,
+		},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			res := serve(tc.Method, tc.Headers)
+			if !assert.Equal(t, tc.Status, res.Code, `status should match`) {
+				return
+			}
+			if !assert.Equal(t, tc.Body, res.Body.Len() > 0, `body should be present: %t`, tc.Body) {
+				return
+			}
+		})
+	}
+
+	t.Run("Set changes", func(t *testing.T) {
+		key2, err := jwk.Generate(jwa.OKP, jwk.WithKeyID(`okp`))
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+		set.Add(key2)
+
+		res := serve(http.MethodGet, map[string]string{`If-None-Match`: etag})
+		if !assert.Equal(t, http.StatusOK, res.Code, `status should be 200`) {
+			return
+		}
+		if !assert.NotEqual(t, etag, res.Header().Get(`ETag`), `ETag should change`) {
+			return
+		}
+	})
+	t.Run("Symmetric keys", func(t *testing.T) {
+		h := jwk.NewHandler(jwk.SetProviderFunc(func() (jwk.Set, error) {
+			key, err := jwk.Generate(jwa.OctetSeq)
+			if err != nil {
+				return nil, err
+			}
+			set := jwk.NewSet()
+			set.Add(key)
+			return set, nil
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/`, nil))
+		if !assert.Equal(t, http.StatusInternalServerError, rec.Code, `status should be 500`) {
+			return
+		}
+	})
+	t.Run("Provider error", func(t *testing.T) {
+		h := jwk.NewHandler(jwk.SetProviderFunc(func() (jwk.Set, error) {
+			return nil, errors.New(`unavailable`)
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, `/`, nil))
+		if !assert.Equal(t, http.StatusInternalServerError, rec.Code, `status should be 500`) {
+			return
+		}
+		if !assert.NotContains(t, rec.Body.String(), `unavailable`, `error should not be exposed`) {
+			return
+		}
+	})
+}
+
+func TestHandlerWithAutoRefresh(t *testing.T) {
+	kr := jwk.NewKeyRing(jwk.RotationPolicy{
+		Generate: func() (jwk.Key, error) {
+			return jwk.Generate(jwa.OKP)
+		},
+	})
+	if !assert.NoError(t, kr.Rotate(), `kr.Rotate should succeed`) {
+		return
+	}
+
+	var mu sync.Mutex
+	var statuses []int
+	h := jwk.NewHandler(kr)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		mu.Lock()
+		statuses = append(statuses, rec.Code)
+		mu.Unlock()
+
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		_, _ = w.Write(rec.Body.Bytes())
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.Configure(srv.URL)
+
+	if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	set, err := ar.Refresh(ctx, srv.URL)
+	if !assert.NoError(t, err, `ar.Refresh should succeed`) {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !assert.Equal(t, []int{http.StatusOK, http.StatusNotModified}, statuses, `second request should be answered with 304`) {
+		return
+	}
+
+	key, err := kr.SigningKey()
+	if !assert.NoError(t, err, `kr.SigningKey should succeed`) {
+		return
+	}
+	if _, ok := set.LookupKeyID(key.KeyID()); !assert.True(t, ok, `signing key should be published`) {
+		return
+	}
+}
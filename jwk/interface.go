@@ -5,6 +5,7 @@ import (
 	"crypto/x509"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/lestrrat-go/iter/arrayiter"
 	"github.com/lestrrat-go/iter/mapiter"
@@ -116,3 +117,47 @@ type AutoRefreshError struct {
 	Error error
 	URL   string
 }
+
+// AutoRefreshObserver is notified of the events that happen while
+// jwk.AutoRefresh keeps its sets up to date. Use
+// `(jwk.AutoRefresh).SetObserver()` to set it.
+//
+// The methods are called synchronously from the goroutine performing
+// the refresh, and therefore should return quickly.
+type AutoRefreshObserver interface {
+	// RefreshStarted is called before the set is fetched
+	RefreshStarted(url string)
+	// RefreshSucceeded is called after the set was successfully fetched
+	// or revalidated, with the changes to the cached set
+	RefreshSucceeded(url string, diff KeyDiff)
+	// RefreshFailed is called when the set could not be fetched
+	RefreshFailed(url string, err error)
+	// TargetExpired is called when the cached set is discarded because
+	// of the policy specified by `jwk.WithStalePolicy()`
+	TargetExpired(url string)
+}
+
+// AutoRefreshObserverFuncs is an AutoRefreshObserver represented by
+// a set of functions. Functions that are nil are not called.
+type AutoRefreshObserverFuncs struct {
+	OnRefreshStarted   func(string)
+	OnRefreshSucceeded func(string, KeyDiff)
+	OnRefreshFailed    func(string, error)
+	OnTargetExpired    func(string)
+}
+
+// KeyDiff describes how a cached jwk.Set changed during a refresh.
+// Keys are compared using their SHA-256 thumbprints. When the set was
+// not modified, both fields are empty.
+type KeyDiff struct {
+	Added   []Key
+	Removed []Key
+}
+
+// StalePolicy specifies what jwk.AutoRefresh does with a cached jwk.Set
+// that can no longer be refreshed. Use `jwk.KeepStaleSet()`,
+// `jwk.ExpireStaleSet()`, or `jwk.DiscardStaleSet()` to create one.
+type StalePolicy struct {
+	maxAge  time.Duration
+	discard bool
+}
@@ -17,11 +17,13 @@ import (
 	"math/big"
 	"net/http"
 
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/lestrrat-go/backoff/v2"
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
 	"github.com/pkg/errors"
 )
 
@@ -42,6 +44,9 @@ func bigIntToBytes(n *big.Int) ([]byte, error) {
 //   * "crypto/rsa".PrivateKey and "crypto/rsa".PublicKey creates an RSA based key
 //   * "crypto/ecdsa".PrivateKey and "crypto/ecdsa".PublicKey creates an EC based key
 //   * "crypto/ed25519".PrivateKey and "crypto/ed25519".PublicKey creates an OKP based key
+//   * "github.com/cloudflare/circl/sign/ed448".PrivateKey and "github.com/cloudflare/circl/sign/ed448".PublicKey creates an OKP based key
+//   * "github.com/lestrrat-go/jwx/x25519".PrivateKey and "github.com/lestrrat-go/jwx/x25519".PublicKey creates an OKP based key
+//   * "github.com/lestrrat-go/jwx/x448".PrivateKey and "github.com/lestrrat-go/jwx/x448".PublicKey creates an OKP based key
 //   * []byte creates a symmetric key
 func New(key interface{}) (Key, error) {
 	if key == nil {
@@ -111,6 +116,30 @@ func New(key interface{}) (Key, error) {
 			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
 		}
 		return k, nil
+	case ed448.PrivateKey:
+		k := NewOKPPrivateKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
+	case ed448.PublicKey:
+		k := NewOKPPublicKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
+	case x448.PrivateKey:
+		k := NewOKPPrivateKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
+	case x448.PublicKey:
+		k := NewOKPPublicKey()
+		if err := k.FromRaw(rawKey); err != nil {
+			return nil, errors.Wrapf(err, `failed to initialize %T from %T`, k, rawKey)
+		}
+		return k, nil
 	case []byte:
 		k := NewSymmetricKey()
 		if err := k.FromRaw(rawKey); err != nil {
@@ -205,6 +234,14 @@ func PublicRawKeyOf(v interface{}) (interface{}, error) {
 		return x.Public(), nil
 	case x25519.PublicKey:
 		return x, nil
+	case ed448.PrivateKey:
+		return x.Public(), nil
+	case ed448.PublicKey:
+		return x, nil
+	case x448.PrivateKey:
+		return x.Public(), nil
+	case x448.PublicKey:
+		return x, nil
 	case []byte:
 		return x, nil
 	default:
@@ -236,6 +273,8 @@ func Fetch(ctx context.Context, urlstring string, options ...FetchOption) (Set,
 
 func fetch(ctx context.Context, urlstring string, options ...FetchOption) (*http.Response, error) {
 	var httpcl HTTPClient = http.DefaultClient
+	var allowlist Allowlist
+	var conditional *conditionalRequest
 	bo := backoff.Null()
 	for _, option := range options {
 		//nolint:forcetypeassert
@@ -244,13 +283,32 @@ func fetch(ctx context.Context, urlstring string, options ...FetchOption) (*http
 			httpcl = option.Value().(HTTPClient)
 		case identFetchBackoff{}:
 			bo = option.Value().(backoff.Policy)
+		case identFetchAllowlist{}:
+			allowlist = option.Value().(Allowlist)
+		case identConditionalRequest{}:
+			conditional = option.Value().(*conditionalRequest)
 		}
 	}
 
+	if allowlist != nil {
+		if !allowlist.IsAllowed(urlstring) {
+			return nil, errors.Errorf(`url %q is not allowed by the allowlist`, urlstring)
+		}
+		httpcl = NewAllowlistClient(httpcl, allowlist)
+	}
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, urlstring, nil)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to new request to remote JWK")
 	}
+	if conditional != nil {
+		if conditional.etag != "" {
+			req.Header.Set(`If-None-Match`, conditional.etag)
+		}
+		if conditional.lastModified != "" {
+			req.Header.Set(`If-Modified-Since`, conditional.lastModified)
+		}
+	}
 
 	b := bo.Start(ctx)
 	var lastError error
@@ -261,7 +319,12 @@ func fetch(ctx context.Context, urlstring string, options ...FetchOption) (*http
 			continue
 		}
 
+		if res.StatusCode == http.StatusNotModified && conditional != nil {
+			return res, nil
+		}
+
 		if res.StatusCode != http.StatusOK {
+			res.Body.Close()
 			lastError = errors.Errorf("failed to fetch remote JWK (status = %d)", res.StatusCode)
 			continue
 		}
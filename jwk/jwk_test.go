@@ -20,10 +20,12 @@ import (
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 	"github.com/pkg/errors"
 
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -142,6 +144,10 @@ func expectedRawKeyType(key jwk.Key) interface{} {
 			return ed25519.PrivateKey(nil)
 		case jwa.X25519:
 			return x25519.PrivateKey(nil)
+		case jwa.Ed448:
+			return ed448.PrivateKey(nil)
+		case jwa.X448:
+			return x448.PrivateKey(nil)
 		default:
 			panic("unknown curve type for OKPPrivateKey:" + key.Crv())
 		}
@@ -151,6 +157,10 @@ func expectedRawKeyType(key jwk.Key) interface{} {
 			return ed25519.PublicKey(nil)
 		case jwa.X25519:
 			return x25519.PublicKey(nil)
+		case jwa.Ed448:
+			return ed448.PublicKey(nil)
+		case jwa.X448:
+			return x448.PublicKey(nil)
 		default:
 			panic("unknown curve type for OKPPublicKey:" + key.Crv())
 		}
@@ -382,6 +392,18 @@ func TestParse(t *testing.T) {
 							return
 						}
 						crawkey = rawkey
+					case jwa.Ed448:
+						var rawkey ed448.PrivateKey
+						if !assert.NoError(t, key.Raw(&rawkey), `key.Raw(&ed448.PrivateKey) should succeed`) {
+							return
+						}
+						crawkey = rawkey
+					case jwa.X448:
+						var rawkey x448.PrivateKey
+						if !assert.NoError(t, key.Raw(&rawkey), `key.Raw(&x448.PrivateKey) should succeed`) {
+							return
+						}
+						crawkey = rawkey
 					default:
 						t.Errorf(`invalid curve %s`, k.Crv())
 					}
@@ -402,6 +424,18 @@ func TestParse(t *testing.T) {
 							return
 						}
 						crawkey = rawkey
+					case jwa.Ed448:
+						var rawkey ed448.PublicKey
+						if !assert.NoError(t, key.Raw(&rawkey), `key.Raw(&ed448.PublicKey) should succeed`) {
+							return
+						}
+						crawkey = rawkey
+					case jwa.X448:
+						var rawkey x448.PublicKey
+						if !assert.NoError(t, key.Raw(&rawkey), `key.Raw(&x448.PublicKey) should succeed`) {
+							return
+						}
+						crawkey = rawkey
 					default:
 						t.Errorf(`invalid curve %s`, k.Crv())
 					}
@@ -515,6 +549,48 @@ func TestParse(t *testing.T) {
 		}`
 		verify(t, src, reflect.TypeOf((*jwk.OKPPrivateKey)(nil)).Elem())
 	})
+	t.Run("Ed448 Public Key", func(t *testing.T) {
+		t.Parallel()
+		// Key taken from RFC 8032
+		const src = `{
+		  "kty" : "OKP",
+		  "crv" : "Ed448",
+		  "x"   : "X9dEm1m0Yf0s54fsYWrUah2hNCSFpw4fig6nXYDpZ3jt8SR2m0bHBhvWeD3x5Q9s0foavq_oJWGA"
+		}`
+		verify(t, src, reflect.TypeOf((*jwk.OKPPublicKey)(nil)).Elem())
+	})
+	t.Run("Ed448 Private Key", func(t *testing.T) {
+		t.Parallel()
+		// Key taken from RFC 8032
+		const src = `{
+		  "kty" : "OKP",
+		  "crv" : "Ed448",
+		  "d"   : "bIKlYsuAjRDWMr6JyFE-v2ySnzTd-oyfY8mWDvbjSKNSjIo_zC8ETjmj_FuUSS-PAy51SaIAmPlb",
+		  "x"   : "X9dEm1m0Yf0s54fsYWrUah2hNCSFpw4fig6nXYDpZ3jt8SR2m0bHBhvWeD3x5Q9s0foavq_oJWGA"
+		}`
+		verify(t, src, reflect.TypeOf((*jwk.OKPPrivateKey)(nil)).Elem())
+	})
+	t.Run("X448 Public Key", func(t *testing.T) {
+		t.Parallel()
+		// Key taken from RFC 7748
+		const src = `{
+		  "kty" : "OKP",
+		  "crv" : "X448",
+		  "x"   : "mwj3zDG34-Z9ItWuoSEHSic70rg94Jxj-qc9LCLF2bvINmRyQdlT1AxbEtqIEg1TF3-A5TLEH6A"
+		}`
+		verify(t, src, reflect.TypeOf((*jwk.OKPPublicKey)(nil)).Elem())
+	})
+	t.Run("X448 Private Key", func(t *testing.T) {
+		t.Parallel()
+		// Key taken from RFC 7748
+		const src = `{
+		  "kty" : "OKP",
+		  "crv" : "X448",
+		  "d"   : "mo9JJdFRn1d1z0awS1gA1O6e6LrovFVl1JjCjdnJuvV0qUGXRIlzkQBjgqbxJ6sdmsLYwKWYcms",
+		  "x"   : "mwj3zDG34-Z9ItWuoSEHSic70rg94Jxj-qc9LCLF2bvINmRyQdlT1AxbEtqIEg1TF3-A5TLEH6A"
+		}`
+		verify(t, src, reflect.TypeOf((*jwk.OKPPrivateKey)(nil)).Elem())
+	})
 }
 
 func TestRoundtrip(t *testing.T) {
@@ -574,6 +650,28 @@ func TestRoundtrip(t *testing.T) {
 		return k, nil
 	}
 
+	generateEd448 := func(use, keyID string) (jwk.Key, error) {
+		k, err := jwxtest.GenerateEd448Jwk()
+		if err != nil {
+			return nil, err
+		}
+
+		k.Set(jwk.KeyUsageKey, use)
+		k.Set(jwk.KeyIDKey, keyID)
+		return k, nil
+	}
+
+	generateX448 := func(use, keyID string) (jwk.Key, error) {
+		k, err := jwxtest.GenerateX448Jwk()
+		if err != nil {
+			return nil, err
+		}
+
+		k.Set(jwk.KeyUsageKey, use)
+		k.Set(jwk.KeyIDKey, keyID)
+		return k, nil
+	}
+
 	tests := []struct {
 		generate func(string, string) (jwk.Key, error)
 		use      string
@@ -634,6 +732,16 @@ func TestRoundtrip(t *testing.T) {
 			keyID:    "enc6",
 			generate: generateX25519,
 		},
+		{
+			use:      "sig",
+			keyID:    "sig7",
+			generate: generateEd448,
+		},
+		{
+			use:      "enc",
+			keyID:    "enc7",
+			generate: generateX448,
+		},
 	}
 
 	ks1 := jwk.NewSet()
@@ -802,6 +910,16 @@ func TestPublicKeyOf(t *testing.T) {
 		return
 	}
 
+	ed448key, err := jwxtest.GenerateEd448Key()
+	if !assert.NoError(t, err, `generating raw Ed448 key should succeed`) {
+		return
+	}
+
+	x448key, err := jwxtest.GenerateX448Key()
+	if !assert.NoError(t, err, `generating raw X448 key should succeed`) {
+		return
+	}
+
 	keys := []struct {
 		Key           interface{}
 		PublicKeyType reflect.Type
@@ -858,6 +976,22 @@ func TestPublicKeyOf(t *testing.T) {
 			Key:           x25519key.Public(),
 			PublicKeyType: reflect.TypeOf(x25519key.Public()),
 		},
+		{
+			Key:           ed448key,
+			PublicKeyType: reflect.TypeOf(ed448key.Public()),
+		},
+		{
+			Key:           ed448key.Public(),
+			PublicKeyType: reflect.TypeOf(ed448key.Public()),
+		},
+		{
+			Key:           x448key,
+			PublicKeyType: reflect.TypeOf(x448key.Public()),
+		},
+		{
+			Key:           x448key.Public(),
+			PublicKeyType: reflect.TypeOf(x448key.Public()),
+		},
 	}
 
 	for _, key := range keys {
@@ -1638,3 +1772,163 @@ func TestGH412(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Name    string
+		KeyType jwa.KeyType
+		Options []jwk.GenerateOption
+		Check   func(*testing.T, jwk.Key)
+		Error   bool
+	}{
+		{
+			Name:    "RSA",
+			KeyType: jwa.RSA,
+			Check: func(t *testing.T, key jwk.Key) {
+				var raw rsa.PrivateKey
+				if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+					return
+				}
+				assert.Equal(t, 2048, raw.N.BitLen(), `key size should be 2048 bits`)
+			},
+		},
+		{
+			Name:    "RSA with key size",
+			KeyType: jwa.RSA,
+			Options: []jwk.GenerateOption{jwk.WithKeySize(3072)},
+			Check: func(t *testing.T, key jwk.Key) {
+				var raw rsa.PrivateKey
+				if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+					return
+				}
+				assert.Equal(t, 3072, raw.N.BitLen(), `key size should be 3072 bits`)
+			},
+		},
+		{
+			Name:    "EC",
+			KeyType: jwa.EC,
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Equal(t, jwa.P256, key.(jwk.ECDSAPrivateKey).Crv(), `curve should be P-256`)
+			},
+		},
+		{
+			Name:    "EC with curve",
+			KeyType: jwa.EC,
+			Options: []jwk.GenerateOption{jwk.WithCurve(jwa.P521)},
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Equal(t, jwa.P521, key.(jwk.ECDSAPrivateKey).Crv(), `curve should be P-521`)
+			},
+		},
+		{
+			Name:    "EC with OKP curve",
+			KeyType: jwa.EC,
+			Options: []jwk.GenerateOption{jwk.WithCurve(jwa.Ed25519)},
+			Error:   true,
+		},
+		{
+			Name:    "OKP",
+			KeyType: jwa.OKP,
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Equal(t, jwa.Ed25519, key.(jwk.OKPPrivateKey).Crv(), `curve should be Ed25519`)
+			},
+		},
+		{
+			Name:    "OKP with curve",
+			KeyType: jwa.OKP,
+			Options: []jwk.GenerateOption{jwk.WithCurve(jwa.X25519)},
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Equal(t, jwa.X25519, key.(jwk.OKPPrivateKey).Crv(), `curve should be X25519`)
+			},
+		},
+		{
+			Name:    "OKP with EC curve",
+			KeyType: jwa.OKP,
+			Options: []jwk.GenerateOption{jwk.WithCurve(jwa.P256)},
+			Error:   true,
+		},
+		{
+			Name:    "oct",
+			KeyType: jwa.OctetSeq,
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Len(t, key.(jwk.SymmetricKey).Octets(), 32, `key size should be 256 bits`)
+			},
+		},
+		{
+			Name:    "oct with random source",
+			KeyType: jwa.OctetSeq,
+			Options: []jwk.GenerateOption{
+				jwk.WithKeySize(128),
+				jwk.WithRandReader(strings.NewReader(`0123456789abcdef`)),
+			},
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Equal(t, []byte(`0123456789abcdef`), key.(jwk.SymmetricKey).Octets(), `key should be read from the random source`)
+			},
+		},
+		{
+			Name:    "oct with short random source",
+			KeyType: jwa.OctetSeq,
+			Options: []jwk.GenerateOption{jwk.WithRandReader(strings.NewReader(`short`))},
+			Error:   true,
+		},
+		{
+			Name:    "oct with invalid key size",
+			KeyType: jwa.OctetSeq,
+			Options: []jwk.GenerateOption{jwk.WithKeySize(100)},
+			Error:   true,
+		},
+		{
+			Name:    "Fields",
+			KeyType: jwa.EC,
+			Options: []jwk.GenerateOption{
+				jwk.WithKeyID(`my-key`),
+				jwk.WithKeyUsage(jwk.ForSignature),
+				jwk.WithKeyOps(jwk.KeyOpSign, jwk.KeyOpVerify),
+				jwk.WithKeyAlgorithm(jwa.ES256),
+			},
+			Check: func(t *testing.T, key jwk.Key) {
+				assert.Equal(t, `my-key`, key.KeyID(), `kid should match`)
+				assert.Equal(t, `sig`, key.KeyUsage(), `use should match`)
+				assert.Equal(t, jwk.KeyOperationList{jwk.KeyOpSign, jwk.KeyOpVerify}, key.KeyOps(), `key_ops should match`)
+				assert.Equal(t, `ES256`, key.Algorithm(), `alg should match`)
+			},
+		},
+		{
+			Name:    "Key ID from thumbprint",
+			KeyType: jwa.OKP,
+			Options: []jwk.GenerateOption{jwk.WithThumbprintHash(crypto.SHA512)},
+			Check: func(t *testing.T, key jwk.Key) {
+				tp, err := key.Thumbprint(crypto.SHA512)
+				if !assert.NoError(t, err, `key.Thumbprint should succeed`) {
+					return
+				}
+				assert.Equal(t, base64.EncodeToString(tp), key.KeyID(), `kid should be the thumbprint`)
+			},
+		},
+		{
+			Name:    "Unknown key type",
+			KeyType: jwa.InvalidKeyType,
+			Error:   true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			key, err := jwk.Generate(tc.KeyType, tc.Options...)
+			if tc.Error {
+				assert.Error(t, err, `jwk.Generate should fail`)
+				return
+			}
+			if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.KeyType, key.KeyType(), `key type should match`) {
+				return
+			}
+			tc.Check(t, key)
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package jwk
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/pkg/errors"
+)
+
+// KeyState is the state of a key in a jwk.KeyRing
+type KeyState int
+
+const (
+	// KeyStatePending is the state of a key that is published, but is
+	// not used for signing yet, so that verifiers can learn about it
+	// before the first token signed by it arrives
+	KeyStatePending KeyState = iota
+	// KeyStateActive is the state of the key that is used for signing
+	KeyStateActive
+	// KeyStateRetired is the state of a key that is no longer used for
+	// signing, but is still published so that tokens signed by it can
+	// be verified until they expire
+	KeyStateRetired
+)
+
+func (s KeyState) String() string {
+	switch s {
+	case KeyStatePending:
+		return "pending"
+	case KeyStateActive:
+		return "active"
+	case KeyStateRetired:
+		return "retired"
+	default:
+		return "unknown"
+	}
+}
+
+// Clock is used by jwk.KeyRing to obtain the current time
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is a Clock represented by a function
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// RotationPolicy describes how `(jwk.KeyRing).Rotate()` rotates keys.
+type RotationPolicy struct {
+	// PendingPeriod is how long a new key is published before it is
+	// used for signing. It should be longer than the time verifiers
+	// cache the published set.
+	PendingPeriod time.Duration
+	// ActivePeriod is how long a key is used for signing before it is
+	// replaced. If zero, keys are not rotated automatically.
+	ActivePeriod time.Duration
+	// RetirePeriod is how long a retired key stays published. It should
+	// be longer than the lifetime of the tokens signed by it.
+	RetirePeriod time.Duration
+	// Generate creates new keys. It is required if Rotate() needs to
+	// create a key, for example using `jwk.Generate()`
+	Generate func() (Key, error)
+}
+
+// KeyRingEntry describes a key in a jwk.KeyRing, along with its state
+// and the times at which it changed states. Times are zero for the
+// states that the key has not reached yet.
+type KeyRingEntry struct {
+	Key         Key
+	State       KeyState
+	CreatedAt   time.Time
+	ActivatedAt time.Time
+	RetiredAt   time.Time
+}
+
+// KeyRing keeps track of the signing keys of an issuer as they go
+// through rotation. At any time, it has at most one active key, which
+// is used for signing, as well as pending keys that will become active
+// in the future, and retired keys that were active in the past. All of
+// them are published using `(jwk.KeyRing).PublicSet()`.
+//
+// Keys are identified by their "kid" field. Keys that do not have one
+// are assigned one using `jwk.AssignKeyID()` when they are added.
+//
+// A KeyRing is safe for concurrent use.
+type KeyRing struct {
+	mu      sync.RWMutex
+	clock   Clock
+	policy  RotationPolicy
+	entries []*KeyRingEntry
+}
+
+// NewKeyRing creates a new empty jwk.KeyRing that rotates keys according
+// to `policy`. Use `jwk.WithClock()` to specify the clock used to
+// determine the current time.
+func NewKeyRing(policy RotationPolicy, options ...KeyRingOption) *KeyRing {
+	var clock Clock = ClockFunc(time.Now)
+	for _, option := range options {
+		//nolint:forcetypeassert
+		switch option.Ident() {
+		case identClock{}:
+			clock = option.Value().(Clock)
+		}
+	}
+
+	return &KeyRing{
+		clock:  clock,
+		policy: policy,
+	}
+}
+
+// Add adds a key to the key ring in the pending state.
+func (kr *KeyRing) Add(key Key) error {
+	if err := AssignKeyID(key); err != nil {
+		return errors.Wrap(err, `failed to assign key ID`)
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	return kr.add(key, kr.clock.Now())
+}
+
+func (kr *KeyRing) add(key Key, now time.Time) error {
+	if kr.lookup(key.KeyID()) != nil {
+		return errors.Errorf(`key with key ID %q already exists`, key.KeyID())
+	}
+
+	kr.entries = append(kr.entries, &KeyRingEntry{
+		Key:       key,
+		State:     KeyStatePending,
+		CreatedAt: now,
+	})
+	return nil
+}
+
+func (kr *KeyRing) lookup(kid string) *KeyRingEntry {
+	for _, e := range kr.entries {
+		if e.Key.KeyID() == kid {
+			return e
+		}
+	}
+	return nil
+}
+
+func (kr *KeyRing) active() *KeyRingEntry {
+	for _, e := range kr.entries {
+		if e.State == KeyStateActive {
+			return e
+		}
+	}
+	return nil
+}
+
+// Activate makes the pending key with the key ID `kid` the active key,
+// regardless of the rotation policy. The previously active key, if any,
+// is retired.
+func (kr *KeyRing) Activate(kid string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	e := kr.lookup(kid)
+	if e == nil {
+		return errors.Errorf(`key with key ID %q not found`, kid)
+	}
+	if e.State != KeyStatePending {
+		return errors.Errorf(`key with key ID %q is %s, not pending`, kid, e.State)
+	}
+	kr.activate(e, kr.clock.Now())
+	return nil
+}
+
+func (kr *KeyRing) activate(e *KeyRingEntry, now time.Time) {
+	if cur := kr.active(); cur != nil {
+		cur.State = KeyStateRetired
+		cur.RetiredAt = now
+	}
+	e.State = KeyStateActive
+	e.ActivatedAt = now
+}
+
+// Retire retires the key with the key ID `kid`, regardless of the
+// rotation policy. If it is the active key, the key ring has no active
+// key until another key is activated.
+func (kr *KeyRing) Retire(kid string) error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	e := kr.lookup(kid)
+	if e == nil {
+		return errors.Errorf(`key with key ID %q not found`, kid)
+	}
+	if e.State == KeyStateRetired {
+		return nil
+	}
+	e.State = KeyStateRetired
+	e.RetiredAt = kr.clock.Now()
+	return nil
+}
+
+// Rotate applies the rotation policy at the current time. It should be
+// called periodically, at intervals that are sufficiently shorter than
+// the periods in the policy. Rotate does the following:
+//
+//   * Retired keys that have been retired for RetirePeriod are removed
+//   * If there is no active key, the oldest pending key is activated
+//     immediately. If there are no pending keys, a new key is generated
+//     and activated
+//   * If the active key will have been active for ActivePeriod within
+//     PendingPeriod, and there are no pending keys, a new pending key is
+//     generated
+//   * If the active key has been active for ActivePeriod, the oldest
+//     pending key that has been pending for PendingPeriod is activated,
+//     and the active key is retired. The active key is kept until such
+//     a key exists
+func (kr *KeyRing) Rotate() error {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	now := kr.clock.Now()
+	policy := kr.policy
+
+	entries := kr.entries[:0]
+	for _, e := range kr.entries {
+		if e.State == KeyStateRetired && !now.Before(e.RetiredAt.Add(policy.RetirePeriod)) {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	for i := len(entries); i < len(kr.entries); i++ {
+		kr.entries[i] = nil
+	}
+	kr.entries = entries
+
+	cur := kr.active()
+	if cur == nil {
+		next := kr.oldestPending(now, -1)
+		if next == nil {
+			e, err := kr.generate(now)
+			if err != nil {
+				return err
+			}
+			next = e
+		}
+		kr.activate(next, now)
+		return nil
+	}
+
+	if policy.ActivePeriod <= 0 {
+		return nil
+	}
+
+	expires := cur.ActivatedAt.Add(policy.ActivePeriod)
+	if kr.oldestPending(now, -1) == nil && !now.Before(expires.Add(-1*policy.PendingPeriod)) {
+		if _, err := kr.generate(now); err != nil {
+			return err
+		}
+	}
+
+	if now.Before(expires) {
+		return nil
+	}
+	if next := kr.oldestPending(now, policy.PendingPeriod); next != nil {
+		kr.activate(next, now)
+	}
+	return nil
+}
+
+// oldestPending returns the oldest pending key that has been pending for
+// at least `d`. If `d` is negative, any pending key is returned
+func (kr *KeyRing) oldestPending(now time.Time, d time.Duration) *KeyRingEntry {
+	for _, e := range kr.entries {
+		if e.State != KeyStatePending {
+			continue
+		}
+		if d < 0 || !now.Before(e.CreatedAt.Add(d)) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (kr *KeyRing) generate(now time.Time) (*KeyRingEntry, error) {
+	if kr.policy.Generate == nil {
+		return nil, errors.New(`rotation policy does not specify a key generator`)
+	}
+
+	key, err := kr.policy.Generate()
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to generate key`)
+	}
+	if err := AssignKeyID(key); err != nil {
+		return nil, errors.Wrap(err, `failed to assign key ID`)
+	}
+	if err := kr.add(key, now); err != nil {
+		return nil, err
+	}
+	return kr.entries[len(kr.entries)-1], nil
+}
+
+// SigningKey returns the active key. The key can be passed to `jws.Sign()`
+// or `jwt.Sign()`, which set the "kid" header field from it.
+func (kr *KeyRing) SigningKey() (Key, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	e := kr.active()
+	if e == nil {
+		return nil, errors.New(`key ring has no active key`)
+	}
+	return e.Key, nil
+}
+
+// Entries returns a snapshot of the keys in the key ring, in the order
+// they were added.
+func (kr *KeyRing) Entries() []KeyRingEntry {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	list := make([]KeyRingEntry, len(kr.entries))
+	for i, e := range kr.entries {
+		list[i] = *e
+	}
+	return list
+}
+
+// PublicSet returns a jwk.Set containing the public keys of all keys
+// in the key ring, which should be published to verifiers. An error is
+// returned if the key ring contains symmetric keys, as they cannot be
+// published.
+func (kr *KeyRing) PublicSet() (Set, error) {
+	kr.mu.RLock()
+	set := NewSet()
+	for _, e := range kr.entries {
+		if e.Key.KeyType() == jwa.OctetSeq {
+			kr.mu.RUnlock()
+			return nil, errors.Errorf(`key with key ID %q is a symmetric key, and cannot be published`, e.Key.KeyID())
+		}
+		set.Add(e.Key)
+	}
+	kr.mu.RUnlock()
+
+	return PublicSetOf(set)
+}
@@ -0,0 +1,188 @@
+package jwk_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/stretchr/testify/assert"
+)
+
+func keyRingStates(kr *jwk.KeyRing) []string {
+	var states []string
+	for _, e := range kr.Entries() {
+		states = append(states, fmt.Sprintf(`%s:%s`, e.Key.KeyID(), e.State))
+	}
+	return states
+}
+
+func TestKeyRing(t *testing.T) {
+	now := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	clock := jwk.ClockFunc(func() time.Time { return now })
+
+	var generated int
+	policy := jwk.RotationPolicy{
+		PendingPeriod: time.Hour,
+		ActivePeriod:  24 * time.Hour,
+		RetirePeriod:  2 * time.Hour,
+		Generate: func() (jwk.Key, error) {
+			generated++
+			return jwk.Generate(jwa.OKP, jwk.WithKeyID(fmt.Sprintf(`key-%d`, generated)), jwk.WithKeyAlgorithm(jwa.EdDSA))
+		},
+	}
+
+	t.Run("Rotation", func(t *testing.T) {
+		kr := jwk.NewKeyRing(policy, jwk.WithClock(clock))
+		if _, err := kr.SigningKey(); !assert.Error(t, err, `kr.SigningKey should fail on empty key ring`) {
+			return
+		}
+
+		steps := []struct {
+			Name     string
+			Advance  time.Duration
+			Expected []string
+		}{
+			{
+				Name:     `Initial key is activated immediately`,
+				Expected: []string{`key-1:active`},
+			},
+			{
+				Name:     `Nothing happens while the key is fresh`,
+				Advance:  22 * time.Hour,
+				Expected: []string{`key-1:active`},
+			},
+			{
+				Name:     `Next key is published ahead of time`,
+				Advance:  time.Hour,
+				Expected: []string{`key-1:active`, `key-2:pending`},
+			},
+			{
+				Name:     `Next key is activated`,
+				Advance:  time.Hour,
+				Expected: []string{`key-1:retired`, `key-2:active`},
+			},
+			{
+				Name:     `Retired key is still published`,
+				Advance:  time.Hour,
+				Expected: []string{`key-1:retired`, `key-2:active`},
+			},
+			{
+				Name:     `Retired key is removed`,
+				Advance:  time.Hour,
+				Expected: []string{`key-2:active`},
+			},
+		}
+
+		for _, step := range steps {
+			now = now.Add(step.Advance)
+			if !assert.NoError(t, kr.Rotate(), `kr.Rotate should succeed (%s)`, step.Name) {
+				return
+			}
+			if !assert.Equal(t, step.Expected, keyRingStates(kr), step.Name) {
+				return
+			}
+		}
+
+		key, err := kr.SigningKey()
+		if !assert.NoError(t, err, `kr.SigningKey should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `key-2`, key.KeyID(), `signing key should be the active key`) {
+			return
+		}
+
+		signed, err := jws.Sign([]byte(`payload`), jwa.EdDSA, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		set, err := kr.PublicSet()
+		if !assert.NoError(t, err, `kr.PublicSet should succeed`) {
+			return
+		}
+		if _, err := jws.VerifySet(signed, set); !assert.NoError(t, err, `jws.VerifySet should succeed using the published set`) {
+			return
+		}
+	})
+	t.Run("Late pending key", func(t *testing.T) {
+		kr := jwk.NewKeyRing(jwk.RotationPolicy{PendingPeriod: time.Hour, ActivePeriod: time.Hour}, jwk.WithClock(clock))
+		for _, kid := range []string{`a`, `b`} {
+			key, err := jwk.Generate(jwa.OKP, jwk.WithKeyID(kid))
+			if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+				return
+			}
+			if !assert.NoError(t, kr.Add(key), `kr.Add should succeed`) {
+				return
+			}
+			if kid == `a` {
+				if !assert.NoError(t, kr.Rotate(), `kr.Rotate should succeed`) {
+					return
+				}
+				now = now.Add(time.Hour)
+			}
+		}
+
+		// The active key has expired, but "b" has not been published
+		// long enough to be activated
+		if !assert.NoError(t, kr.Rotate(), `kr.Rotate should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{`a:active`, `b:pending`}, keyRingStates(kr), `active key should be kept`) {
+			return
+		}
+
+		now = now.Add(time.Hour)
+		if !assert.NoError(t, kr.Rotate(), `kr.Rotate should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []string{`a:retired`, `b:active`}, keyRingStates(kr), `pending key should be activated`) {
+			return
+		}
+	})
+	t.Run("Manual operations", func(t *testing.T) {
+		kr := jwk.NewKeyRing(jwk.RotationPolicy{}, jwk.WithClock(clock))
+		key, err := jwk.Generate(jwa.EC)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+		if !assert.NoError(t, kr.Add(key), `kr.Add should succeed`) {
+			return
+		}
+		if !assert.Error(t, kr.Add(key), `kr.Add should fail for duplicate key ID`) {
+			return
+		}
+		if !assert.NoError(t, kr.Activate(key.KeyID()), `kr.Activate should succeed`) {
+			return
+		}
+		if !assert.Error(t, kr.Activate(key.KeyID()), `kr.Activate should fail for active key`) {
+			return
+		}
+		if !assert.Error(t, kr.Activate(`unknown`), `kr.Activate should fail for unknown key`) {
+			return
+		}
+		if !assert.NoError(t, kr.Retire(key.KeyID()), `kr.Retire should succeed`) {
+			return
+		}
+		if _, err := kr.SigningKey(); !assert.Error(t, err, `kr.SigningKey should fail without active key`) {
+			return
+		}
+		if !assert.Error(t, kr.Rotate(), `kr.Rotate should fail without a key generator`) {
+			return
+		}
+	})
+	t.Run("Symmetric keys are not published", func(t *testing.T) {
+		kr := jwk.NewKeyRing(jwk.RotationPolicy{}, jwk.WithClock(clock))
+		key, err := jwk.Generate(jwa.OctetSeq)
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return
+		}
+		if !assert.NoError(t, kr.Add(key), `kr.Add should succeed`) {
+			return
+		}
+		if _, err := kr.PublicSet(); !assert.Error(t, err, `kr.PublicSet should fail`) {
+			return
+		}
+	})
+}
@@ -6,10 +6,12 @@ import (
 	"crypto/ed25519"
 	"fmt"
 
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/lestrrat-go/blackmagic"
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/x25519"
+	"github.com/lestrrat-go/jwx/x448"
 	"github.com/pkg/errors"
 )
 
@@ -27,6 +29,14 @@ func (k *okpPublicKey) FromRaw(rawKeyIf interface{}) error {
 		k.x = rawKey
 		crv = jwa.X25519
 		k.crv = &crv
+	case ed448.PublicKey:
+		k.x = rawKey
+		crv = jwa.Ed448
+		k.crv = &crv
+	case x448.PublicKey:
+		k.x = rawKey
+		crv = jwa.X448
+		k.crv = &crv
 	default:
 		return errors.Errorf(`unknown key type %T`, rawKeyIf)
 	}
@@ -50,6 +60,16 @@ func (k *okpPrivateKey) FromRaw(rawKeyIf interface{}) error {
 		k.x = rawKey.Public().(x25519.PublicKey) //nolint:forcetypeassert
 		crv = jwa.X25519
 		k.crv = &crv
+	case ed448.PrivateKey:
+		k.d = rawKey.Seed()
+		k.x = rawKey.Public().(ed448.PublicKey) //nolint:forcetypeassert
+		crv = jwa.Ed448
+		k.crv = &crv
+	case x448.PrivateKey:
+		k.d = rawKey.Seed()
+		k.x = rawKey.Public().(x448.PublicKey) //nolint:forcetypeassert
+		crv = jwa.X448
+		k.crv = &crv
 	default:
 		return errors.Errorf(`unknown key type %T`, rawKeyIf)
 	}
@@ -63,6 +83,10 @@ func buildOKPPublicKey(alg jwa.EllipticCurveAlgorithm, xbuf []byte) (interface{}
 		return ed25519.PublicKey(xbuf), nil
 	case jwa.X25519:
 		return x25519.PublicKey(xbuf), nil
+	case jwa.Ed448:
+		return ed448.PublicKey(xbuf), nil
+	case jwa.X448:
+		return x448.PublicKey(xbuf), nil
 	default:
 		return nil, errors.Errorf(`invalid curve algorithm %s`, alg)
 	}
@@ -98,6 +122,24 @@ func buildOKPPrivateKey(alg jwa.EllipticCurveAlgorithm, xbuf []byte, dbuf []byte
 			return nil, errors.Errorf(`invalid x value given d value`)
 		}
 		return ret, nil
+	case jwa.Ed448:
+		if len(dbuf) != ed448.SeedSize {
+			return nil, errors.Errorf(`unexpected ed448 seed size: %d`, len(dbuf))
+		}
+		ret := ed448.NewKeyFromSeed(dbuf)
+		if !bytes.Equal(xbuf, ret.Public().(ed448.PublicKey)) {
+			return nil, errors.Errorf(`invalid x value given d value`)
+		}
+		return ret, nil
+	case jwa.X448:
+		ret, err := x448.NewKeyFromSeed(dbuf)
+		if err != nil {
+			return nil, errors.Wrap(err, `unable to construct x448 private key from seed`)
+		}
+		if !bytes.Equal(xbuf, ret.Public().(x448.PublicKey)) {
+			return nil, errors.Errorf(`invalid x value given d value`)
+		}
+		return ret, nil
 	default:
 		return nil, errors.Errorf(`invalid curve algorithm %s`, alg)
 	}
@@ -2,23 +2,41 @@ package jwk
 
 import (
 	"crypto"
+	"io"
 	"time"
 
 	"github.com/lestrrat-go/backoff/v2"
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/option"
 )
 
 type Option = option.Interface
 
 type identHTTPClient struct{}
+type identFetchAllowlist struct{}
 type identThumbprintHash struct{}
 type identRefreshInterval struct{}
 type identMinRefreshInterval struct{}
+type identLookupRefreshInterval struct{}
+type identStorage struct{}
+type identMaxStaleness struct{}
+type identStalePolicy struct{}
+type identKeySize struct{}
+type identCurve struct{}
+type identRandReader struct{}
+type identKeyID struct{}
+type identKeyUsage struct{}
+type identKeyOps struct{}
+type identKeyAlgorithm struct{}
+type identClock struct{}
+type identCacheMaxAge struct{}
 type identFetchBackoff struct{}
+type identConditionalRequest struct{}
 type identPEM struct{}
 type identTypedField struct{}
 type identLocalRegistry struct{}
+type identStrictKeyUsage struct{}
 
 // AutoRefreshOption is a type of Option that can be passed to the
 // AutoRefresh object.
@@ -61,12 +79,81 @@ type parseOption struct {
 func (*parseOption) parseOption()    {}
 func (*parseOption) readFileOption() {}
 
+// GenerateOption is a type of Option that can be passed to `jwk.Generate()`
+type GenerateOption interface {
+	Option
+	generateOption()
+}
+
+type generateOption struct {
+	Option
+}
+
+func (*generateOption) generateOption() {}
+
+// KeyRingOption is a type of Option that can be passed to `jwk.NewKeyRing()`
+type KeyRingOption interface {
+	Option
+	keyRingOption()
+}
+
+type keyRingOption struct {
+	Option
+}
+
+func (*keyRingOption) keyRingOption() {}
+
+// HandlerOption is a type of Option that can be passed to `jwk.NewHandler()`
+type HandlerOption interface {
+	Option
+	handlerOption()
+}
+
+type handlerOption struct {
+	Option
+}
+
+func (*handlerOption) handlerOption() {}
+
+// GlobalOption is a type of Option that can be passed to `jwk.Settings()`
+type GlobalOption interface {
+	Option
+	globalOption()
+}
+
+type globalOption struct {
+	Option
+}
+
+func (*globalOption) globalOption() {}
+
 // WithHTTPClient allows users to specify the "net/http".Client object that
 // is used when fetching jwk.Set objects.
 func WithHTTPClient(cl HTTPClient) FetchOption {
 	return &fetchOption{option.New(identHTTPClient{}, cl)}
 }
 
+// WithFetchAllowlist specifies the Allowlist that is consulted before
+// fetching a remote resource. If the URL is not allowed, no HTTP request
+// is made and an error is returned. The same applies to the targets of
+// redirects (see `jwk.NewAllowlistClient()`).
+func WithFetchAllowlist(l Allowlist) FetchOption {
+	return &fetchOption{option.New(identFetchAllowlist{}, l)}
+}
+
+// conditionalRequest holds the values of the ETag and Last-Modified
+// headers of a previous response, which are sent as If-None-Match and
+// If-Modified-Since headers. When specified, fetch() also returns
+// responses with the status "304 Not Modified"
+type conditionalRequest struct {
+	etag         string
+	lastModified string
+}
+
+func withConditionalRequest(etag, lastModified string) FetchOption {
+	return &fetchOption{option.New(identConditionalRequest{}, &conditionalRequest{etag: etag, lastModified: lastModified})}
+}
+
 // WithFetchBackoff specifies the backoff policy to use when
 // refreshing a JWKS from a remote server fails.
 //
@@ -76,8 +163,11 @@ func WithFetchBackoff(v backoff.Policy) FetchOption {
 	return &fetchOption{option.New(identFetchBackoff{}, v)}
 }
 
-func WithThumbprintHash(h crypto.Hash) Option {
-	return option.New(identThumbprintHash{}, h)
+// WithThumbprintHash specifies the hash function used to compute the
+// thumbprint that `jwk.AssignKeyID()` and `jwk.Generate()` use as the
+// key ID. If unspecified, crypto.SHA256 is used.
+func WithThumbprintHash(h crypto.Hash) GenerateOption {
+	return &generateOption{option.New(identThumbprintHash{}, h)}
 }
 
 // WithRefreshInterval specifies the static interval between refreshes
@@ -117,6 +207,123 @@ func WithMinRefreshInterval(d time.Duration) AutoRefreshOption {
 	}
 }
 
+// WithLookupRefreshInterval specifies the minimum interval between the
+// refreshes that `(jwk.AutoRefresh).LookupKeyID()` triggers when it
+// cannot find a key. This prevents requests with random key IDs from
+// causing a flood of fetches.
+//
+// If unspecified, the interval is 1 minute
+func WithLookupRefreshInterval(d time.Duration) AutoRefreshOption {
+	return &autoRefreshOption{
+		option.New(identLookupRefreshInterval{}, d),
+	}
+}
+
+// WithStorage specifies the AutoRefreshStorage used to persist the
+// jwk.Set fetched from the URL. When the URL is configured for the
+// first time, a stored set that is not older than the duration given
+// by `jwk.WithMaxStaleness()` is loaded into the cache, so that it can
+// be used without fetching it first. After each successful refresh,
+// the set is stored again.
+func WithStorage(s AutoRefreshStorage) AutoRefreshOption {
+	return &autoRefreshOption{
+		option.New(identStorage{}, s),
+	}
+}
+
+// WithMaxStaleness specifies how old a set loaded from the storage
+// specified by `jwk.WithStorage()` may be. The age is measured from
+// the time the set was last successfully fetched.
+//
+// If unspecified, the maximum staleness is 24 hours
+func WithMaxStaleness(d time.Duration) AutoRefreshOption {
+	return &autoRefreshOption{
+		option.New(identMaxStaleness{}, d),
+	}
+}
+
+// WithStalePolicy specifies what to do with the cached jwk.Set when it
+// cannot be refreshed: keep using it (`jwk.KeepStaleSet()`), stop using
+// it after a maximum age (`jwk.ExpireStaleSet()`), or discard it as soon
+// as a refresh fails (`jwk.DiscardStaleSet()`).
+//
+// If unspecified, the cached set is kept
+func WithStalePolicy(p StalePolicy) AutoRefreshOption {
+	return &autoRefreshOption{
+		option.New(identStalePolicy{}, p),
+	}
+}
+
+// WithKeySize specifies the size of the key in bits for `jwk.Generate()`.
+// It is used for RSA keys (default 2048) and symmetric keys (default 256).
+func WithKeySize(bits int) GenerateOption {
+	return &generateOption{option.New(identKeySize{}, bits)}
+}
+
+// WithCurve specifies the curve for `jwk.Generate()`. It is used for EC
+// keys (default P-256) and OKP keys (default Ed25519).
+func WithCurve(crv jwa.EllipticCurveAlgorithm) GenerateOption {
+	return &generateOption{option.New(identCurve{}, crv)}
+}
+
+// WithRandReader specifies the source of randomness for `jwk.Generate()`.
+// If unspecified, crypto/rand.Reader is used.
+//
+// Note that depending on the version of Go, the standard library may
+// ignore this source when generating RSA and EC keys.
+func WithRandReader(r io.Reader) GenerateOption {
+	return &generateOption{option.New(identRandReader{}, r)}
+}
+
+// WithKeyID specifies the "kid" of the key created by `jwk.Generate()`.
+// If unspecified, the thumbprint of the key is used (see
+// `jwk.AssignKeyID()`).
+func WithKeyID(kid string) GenerateOption {
+	return &generateOption{option.New(identKeyID{}, kid)}
+}
+
+// WithKeyUsage specifies the "use" of the key created by `jwk.Generate()`
+func WithKeyUsage(use KeyUsageType) GenerateOption {
+	return &generateOption{option.New(identKeyUsage{}, use)}
+}
+
+// WithKeyOps specifies the "key_ops" of the key created by `jwk.Generate()`
+func WithKeyOps(ops ...KeyOperation) GenerateOption {
+	return &generateOption{option.New(identKeyOps{}, KeyOperationList(ops))}
+}
+
+// WithKeyAlgorithm specifies the "alg" of the key created by
+// `jwk.Generate()`. The value can be a jwa.SignatureAlgorithm,
+// a jwa.KeyEncryptionAlgorithm, or a string.
+func WithKeyAlgorithm(alg interface{}) GenerateOption {
+	return &generateOption{option.New(identKeyAlgorithm{}, alg)}
+}
+
+// WithClock specifies the Clock that a jwk.KeyRing uses to determine the
+// current time. This is useful for testing rotations.
+func WithClock(c Clock) KeyRingOption {
+	return &keyRingOption{option.New(identClock{}, c)}
+}
+
+// WithCacheMaxAge specifies the value of the max-age directive in the
+// "Cache-Control" header of the responses served by `jwk.NewHandler()`.
+// If the value is zero or less, "no-cache" is used instead.
+//
+// If unspecified, the max-age is 1 hour
+func WithCacheMaxAge(d time.Duration) HandlerOption {
+	return &handlerOption{option.New(identCacheMaxAge{}, d)}
+}
+
+// WithStrictKeyUsage specifies if `jws` and `jwe` should refuse to use
+// a jwk.Key for an operation that its "use", "key_ops", or "alg" fields
+// do not permit. See `jwk.ValidateKeyUsage()` for details.
+//
+// The default value is `false`. It can be overridden for each call using
+// `jws.WithStrictKeyUsage()` or `jwe.WithStrictKeyUsage()`.
+func WithStrictKeyUsage(v bool) GlobalOption {
+	return &globalOption{option.New(identStrictKeyUsage{}, v)}
+}
+
 // WithPEM specifies that the input to `Parse()` is a PEM encoded key.
 func WithPEM(v bool) ParseOption {
 	return &parseOption{
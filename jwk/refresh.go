@@ -2,6 +2,7 @@ package jwk
 
 import (
 	"context"
+	"crypto"
 	"net/http"
 	"reflect"
 	"sync"
@@ -33,7 +34,9 @@ type AutoRefresh struct {
 	muErrSink    sync.Mutex
 	muCache      sync.RWMutex
 	muFetching   sync.Mutex
+	muObserver   sync.RWMutex
 	muRegistry   sync.RWMutex
+	observer     AutoRefreshObserver
 	registry     map[string]*target
 	resetTimerCh chan *resetTimerReq
 }
@@ -42,6 +45,9 @@ type target struct {
 	// The backoff policy to use when fetching the JWKS fails
 	backoff backoff.Policy
 
+	// The allowlist to consult before fetching the JWKS, if any
+	allowlist Allowlist
+
 	// The HTTP client to use. The user may opt to use a client which is
 	// aware of HTTP caching, or one that goes through a proxy
 	httpcl HTTPClient
@@ -73,6 +79,27 @@ type target struct {
 	refreshInterval    *time.Duration
 	minRefreshInterval time.Duration
 
+	// The minimum interval between refreshes triggered by LookupKeyID(),
+	// and the time of the last one
+	lookupRefreshInterval time.Duration
+	muLookup              sync.Mutex
+	lastLookupRefresh     time.Time
+
+	// The values of the ETag and Last-Modified headers from the last
+	// successful response, which are used to make conditional requests,
+	// and the time of the last successful refresh
+	muState      sync.Mutex
+	etag         string
+	lastModified string
+	fetchedAt    time.Time
+
+	// The storage to persist the set in, if any
+	storage      AutoRefreshStorage
+	maxStaleness time.Duration
+
+	// What to do with the cached set when it cannot be refreshed
+	stalePolicy StalePolicy
+
 	url string
 
 	// The timer for refreshing the keyset. should not be set by anyone
@@ -151,7 +178,12 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 	var hasRefreshInterval bool
 	var refreshInterval time.Duration
 	minRefreshInterval := time.Hour
+	lookupRefreshInterval := time.Minute
+	maxStaleness := 24 * time.Hour
 	bo := backoff.Null()
+	var allowlist Allowlist
+	var storage AutoRefreshStorage
+	var stalePolicy StalePolicy
 	for _, option := range options {
 		//nolint:forcetypeassert
 		switch option.Ident() {
@@ -162,8 +194,27 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 			hasRefreshInterval = true
 		case identMinRefreshInterval{}:
 			minRefreshInterval = option.Value().(time.Duration)
+		case identLookupRefreshInterval{}:
+			lookupRefreshInterval = option.Value().(time.Duration)
 		case identHTTPClient{}:
 			httpcl = option.Value().(HTTPClient)
+		case identFetchAllowlist{}:
+			allowlist = option.Value().(Allowlist)
+		case identStorage{}:
+			storage = option.Value().(AutoRefreshStorage)
+		case identMaxStaleness{}:
+			maxStaleness = option.Value().(time.Duration)
+		case identStalePolicy{}:
+			stalePolicy = option.Value().(StalePolicy)
+		}
+	}
+
+	// Load the stored set before touching the registry, so that we
+	// don't block other goroutines while reading the storage
+	var stored *StoredSet
+	if storage != nil {
+		if _, ok := af.getRegistered(url); !ok {
+			stored = af.loadStored(url, storage, maxStaleness)
 		}
 	}
 
@@ -171,6 +222,16 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 	af.muRegistry.Lock()
 	t, ok := af.registry[url]
 	if ok {
+		t.allowlist = allowlist
+		t.muState.Lock()
+		t.storage = storage
+		t.maxStaleness = maxStaleness
+		t.stalePolicy = stalePolicy
+		t.muState.Unlock()
+		t.muLookup.Lock()
+		t.lookupRefreshInterval = lookupRefreshInterval
+		t.muLookup.Unlock()
+
 		if t.httpcl != httpcl {
 			t.httpcl = httpcl
 			doReconfigure = true
@@ -197,20 +258,45 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 		}
 	} else {
 		t = &target{
-			backoff:            bo,
-			httpcl:             httpcl,
-			minRefreshInterval: minRefreshInterval,
-			url:                url,
-			sem:                make(chan struct{}, 1),
-			// This is a placeholder timer so we can call Reset() on it later
-			// Make it sufficiently in the future so that we don't have bogus
-			// events firing
-			timer: time.NewTimer(24 * time.Hour),
+			allowlist:             allowlist,
+			backoff:               bo,
+			httpcl:                httpcl,
+			minRefreshInterval:    minRefreshInterval,
+			lookupRefreshInterval: lookupRefreshInterval,
+			storage:               storage,
+			maxStaleness:          maxStaleness,
+			stalePolicy:           stalePolicy,
+			url:                   url,
+			sem:                   make(chan struct{}, 1),
 		}
 		if hasRefreshInterval {
 			t.refreshInterval = &refreshInterval
 		}
 
+		if stored != nil {
+			// Use the stored set until it is refreshed at the time
+			// it was scheduled to
+			af.muCache.Lock()
+			af.cache[url] = stored.Set
+			af.muCache.Unlock()
+			t.etag = stored.ETag
+			t.lastModified = stored.LastModified
+			t.fetchedAt = stored.FetchedAt
+			t.lastRefresh = stored.FetchedAt.Local()
+			t.nextRefresh = stored.NextRefresh.Local()
+
+			d := time.Until(stored.NextRefresh)
+			if d < 0 {
+				d = 0
+			}
+			t.timer = time.NewTimer(d)
+		} else {
+			// This is a placeholder timer so we can call Reset() on it later
+			// Make it sufficiently in the future so that we don't have bogus
+			// events firing
+			t.timer = time.NewTimer(24 * time.Hour)
+		}
+
 		// Record this in the registry
 		af.registry[url] = t
 		doReconfigure = true
@@ -223,6 +309,56 @@ func (af *AutoRefresh) Configure(url string, options ...AutoRefreshOption) {
 	}
 }
 
+// Remove unregisters the url, so that it is no longer refreshed, and
+// discards its cached jwk.Set. The url must be registered again using
+// `Configure()` before it can be used. Removing a url that is not
+// registered does nothing.
+func (af *AutoRefresh) Remove(url string) {
+	af.muRegistry.Lock()
+	t, ok := af.registry[url]
+	if ok {
+		delete(af.registry, url)
+		t.timer.Stop()
+	}
+	af.muRegistry.Unlock()
+	if !ok {
+		return
+	}
+
+	af.expire(url)
+
+	// Tell the backend to stop watching the timer of the target
+	af.configureCh <- struct{}{}
+}
+
+// loadStored loads the set stored for url, and returns it if it is
+// not too stale. Errors are reported to the error sink
+func (af *AutoRefresh) loadStored(url string, storage AutoRefreshStorage, maxStaleness time.Duration) *StoredSet {
+	stored, err := storage.Load(url)
+	if err != nil {
+		af.sendError(url, errors.Wrap(err, `failed to load stored set`))
+		return nil
+	}
+
+	if stored == nil || stored.Set == nil || time.Since(stored.FetchedAt) > maxStaleness {
+		return nil
+	}
+	return stored
+}
+
+// sendError sends err to the error sink, discarding it if the sink
+// is not set or is full
+func (af *AutoRefresh) sendError(url string, err error) {
+	af.muErrSink.Lock()
+	errSink := af.errSink
+	af.muErrSink.Unlock()
+
+	select {
+	case errSink <- AutoRefreshError{Error: err, URL: url}:
+	default:
+	}
+}
+
 func (af *AutoRefresh) releaseFetching(url string) {
 	// first delete the entry from the map, then close the channel or
 	// otherwise we may end up getting multiple groutines doing the fetch
@@ -250,7 +386,9 @@ func (af *AutoRefresh) getRegistered(url string) (*target, bool) {
 // If it has previously been fetched, then a cached value is returned.
 //
 // If this the first time `url` was requested, an HTTP request will be
-// sent, synchronously.
+// sent, synchronously. The same happens if the cached value has been
+// discarded or has expired according to the policy specified by
+// `jwk.WithStalePolicy()`.
 //
 // When accessed via multiple goroutines concurrently, and the cache
 // has not been populated yet, only the first goroutine is
@@ -260,13 +398,17 @@ func (af *AutoRefresh) getRegistered(url string) (*target, bool) {
 // DO NOT modify the jwk.Set object returned by this method, as the
 // objects are shared among all consumers and the backend goroutine
 func (af *AutoRefresh) Fetch(ctx context.Context, url string) (Set, error) {
-	if _, ok := af.getRegistered(url); !ok {
+	t, ok := af.getRegistered(url)
+	if !ok {
 		return nil, errors.Errorf(`url %s must be configured using "Configure()" first`, url)
 	}
 
 	ks, found := af.getCached(url)
 	if found {
-		return ks, nil
+		if !t.isExpired(time.Now()) {
+			return ks, nil
+		}
+		af.expire(url)
 	}
 
 	return af.refresh(ctx, url)
@@ -285,6 +427,95 @@ func (af *AutoRefresh) Refresh(ctx context.Context, url string) (Set, error) {
 	return af.refresh(ctx, url)
 }
 
+// LookupKeyID returns the key with the key ID `kid` from the jwk.Set
+// of the given url, in the same way as `Fetch()` followed by
+// `(jwk.Set).LookupKeyID()`.
+//
+// If the key is not found in the cached set, for example because the
+// keys have been rotated, the set is refreshed synchronously and the
+// lookup is retried. Only one such refresh is performed at a time for
+// each url, and refreshes are at least the interval specified by
+// `jwk.WithLookupRefreshInterval()` apart. Lookups that happen in
+// between only consult the cached set.
+func (af *AutoRefresh) LookupKeyID(ctx context.Context, url, kid string) (Key, error) {
+	t, ok := af.getRegistered(url)
+	if !ok {
+		return nil, errors.Errorf(`url %s must be configured using "Configure()" first`, url)
+	}
+
+	ks, err := af.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, ok := ks.LookupKeyID(kid); ok {
+		return key, nil
+	}
+
+	ks, err = af.lookupRefresh(ctx, t)
+	if err != nil {
+		return nil, err
+	}
+
+	if ks != nil {
+		if key, ok := ks.LookupKeyID(kid); ok {
+			return key, nil
+		}
+	}
+	return nil, errors.Errorf(`failed to find key with key ID %q in %s`, kid, url)
+}
+
+// lookupRefresh refreshes the jwk.Set for a key lookup. If a refresh is
+// already in progress, it waits for the refresh to complete. Otherwise,
+// if the last refresh for a key lookup was too recent, the cached set is
+// returned without performing a refresh.
+//
+// The throttling check and the registration of the in-flight refresh
+// happen while holding muLookup, so that concurrent lookups either start
+// the refresh or wait for it, but never see neither
+func (af *AutoRefresh) lookupRefresh(ctx context.Context, t *target) (Set, error) {
+	now := time.Now()
+	t.muLookup.Lock()
+	af.muFetching.Lock()
+	fetchingCh, fetching := af.fetching[t.url]
+	if !fetching {
+		if !t.lastLookupRefresh.IsZero() && now.Sub(t.lastLookupRefresh) < t.lookupRefreshInterval {
+			af.muFetching.Unlock()
+			t.muLookup.Unlock()
+			// A refresh may have completed since the caller consulted
+			// the cache, so return the current set
+			ks, _ := af.getCached(t.url)
+			return ks, nil
+		}
+		t.lastLookupRefresh = now
+		fetchingCh = make(chan struct{})
+		af.fetching[t.url] = fetchingCh
+	}
+	af.muFetching.Unlock()
+	t.muLookup.Unlock()
+
+	if fetching {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-fetchingCh:
+		}
+		ks, _ := af.getCached(t.url)
+		return ks, nil
+	}
+
+	defer af.releaseFetching(t.url)
+	if err := af.doRefreshRequest(ctx, t.url, false); err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch resource pointed by %s`, t.url)
+	}
+
+	ks, ok := af.getCached(t.url)
+	if !ok {
+		return nil, errors.New("cache was not populated after explicit refresh")
+	}
+	return ks, nil
+}
+
 func (af *AutoRefresh) refresh(ctx context.Context, url string) (Set, error) {
 	// To avoid a thundering herd, only one goroutine per url may enter into this
 	// initial fetch phase.
@@ -437,45 +668,92 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 		return errors.Errorf(`url "%s" is not registered`, url)
 	}
 
+	observer := af.getObserver()
+	observer.RefreshStarted(url)
+
 	// In case the refresh fails due to errors in fetching/parsing the JWKS,
 	// we want to retry. Create a backoff object,
 
 	options := []FetchOption{WithHTTPClient(t.httpcl)}
+	if t.allowlist != nil {
+		options = append(options, WithFetchAllowlist(t.allowlist))
+	}
 	if enableBackoff {
 		options = append(options, WithFetchBackoff(t.backoff))
 	}
 
+	// If we have a cached set, ask the server to only send the set
+	// if it has been modified since we fetched it
+	if _, ok := af.getCached(url); ok {
+		t.muState.Lock()
+		etag, lastModified := t.etag, t.lastModified
+		t.muState.Unlock()
+		if etag != "" || lastModified != "" {
+			options = append(options, withConditionalRequest(etag, lastModified))
+		}
+	}
+
 	res, err := fetch(ctx, url, options...)
 	if err == nil {
-		if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		var diff KeyDiff
+		switch res.StatusCode {
+		case http.StatusNotModified:
+			// The cached set is still good. Only update the cache headers
+			// and the refresh timing below
+		case http.StatusOK:
+			keyset, parseErr := ParseReader(res.Body)
+			if parseErr != nil {
+				err = parseErr
+				break
+			}
+			// Got a new key set. replace the keyset in the target, unless
+			// the url was removed in the meantime
+			af.muRegistry.RLock()
+			if af.registry[url] != t {
+				af.muRegistry.RUnlock()
+				return errors.Errorf(`url "%s" was removed`, url)
+			}
+			af.muCache.Lock()
+			old := af.cache[url]
+			af.cache[url] = keyset
+			af.muCache.Unlock()
+			af.muRegistry.RUnlock()
+			diff = diffSets(old, keyset)
+		default:
 			// now, can there be a remote resource that responds with a status code
 			// other than 200 and still be valid...? naaaaaaahhhhhh....
 			err = errors.Errorf(`bad response status code (%d)`, res.StatusCode)
-		} else {
-			defer res.Body.Close()
-			keyset, parseErr := ParseReader(res.Body)
-			if parseErr == nil {
-				// Got a new key set. replace the keyset in the target
-				af.muCache.Lock()
-				af.cache[url] = keyset
-				af.muCache.Unlock()
-				nextInterval := calculateRefreshDuration(res, t.refreshInterval, t.minRefreshInterval)
-				rtr := &resetTimerReq{
-					t: t,
-					d: nextInterval,
-				}
-				select {
-				case <-ctx.Done():
-					return ctx.Err()
-				case af.resetTimerCh <- rtr:
-				}
+		}
 
-				now := time.Now()
-				t.lastRefresh = now.Local()
-				t.nextRefresh = now.Add(nextInterval).Local()
-				return nil
+		if err == nil {
+			now := time.Now()
+			t.muState.Lock()
+			t.fetchedAt = now
+			if res.StatusCode == http.StatusOK || res.Header.Get(`ETag`) != "" {
+				t.etag = res.Header.Get(`ETag`)
+			}
+			if res.StatusCode == http.StatusOK || res.Header.Get(`Last-Modified`) != "" {
+				t.lastModified = res.Header.Get(`Last-Modified`)
 			}
-			err = parseErr
+			t.muState.Unlock()
+
+			nextInterval := calculateRefreshDuration(res, t.refreshInterval, t.minRefreshInterval)
+			rtr := &resetTimerReq{
+				t: t,
+				d: nextInterval,
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case af.resetTimerCh <- rtr:
+			}
+
+			t.lastRefresh = now.Local()
+			t.nextRefresh = now.Add(nextInterval).Local()
+			af.store(t, now, now.Add(nextInterval))
+			observer.RefreshSucceeded(url, diff)
+			return nil
 		}
 	}
 
@@ -484,16 +762,19 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 	// but take the extra mileage to not block regular processing by
 	// discarding the error if we fail to send it through the channel
 	if err != nil {
-		select {
-		case af.errSink <- AutoRefreshError{Error: err, URL: url}:
-		default:
-		}
+		af.sendError(url, err)
+		observer.RefreshFailed(url, err)
 	}
 
 	// We either failed to perform the HTTP GET, or we failed to parse the
-	// JWK set. Even in case of errors, we don't delete the old key.
-	// We persist the old key set, even if it may be stale so the user has something to work with
-	// TODO: maybe this behavior should be customizable?
+	// JWK set. Unless the stale policy says otherwise, we don't delete the
+	// old key set, even if it may be stale so the user has something to work with
+	t.muState.Lock()
+	discard := t.stalePolicy.discard
+	t.muState.Unlock()
+	if discard || t.isExpired(time.Now()) {
+		af.expire(url)
+	}
 
 	// If we failed to get a single time, then queue another fetch in the future.
 	rtr := &resetTimerReq{
@@ -509,6 +790,78 @@ func (af *AutoRefresh) doRefreshRequest(ctx context.Context, url string, enableB
 	return err
 }
 
+// isExpired returns true if the stale policy of the target says that
+// its cached set should no longer be used at time `now`
+func (t *target) isExpired(now time.Time) bool {
+	t.muState.Lock()
+	defer t.muState.Unlock()
+	if t.stalePolicy.maxAge <= 0 || t.fetchedAt.IsZero() {
+		return false
+	}
+	return now.Sub(t.fetchedAt) > t.stalePolicy.maxAge
+}
+
+// expire removes the cached set for url, and notifies the observer
+// if there was one
+func (af *AutoRefresh) expire(url string) {
+	af.muCache.Lock()
+	_, ok := af.cache[url]
+	delete(af.cache, url)
+	af.muCache.Unlock()
+
+	if ok {
+		af.getObserver().TargetExpired(url)
+	}
+}
+
+// store persists the cached set of the target, if a storage is configured
+func (af *AutoRefresh) store(t *target, fetchedAt, nextRefresh time.Time) {
+	t.muState.Lock()
+	storage := t.storage
+	stored := &StoredSet{
+		FetchedAt:    fetchedAt,
+		NextRefresh:  nextRefresh,
+		ETag:         t.etag,
+		LastModified: t.lastModified,
+	}
+	t.muState.Unlock()
+	if storage == nil {
+		return
+	}
+
+	ks, ok := af.getCached(t.url)
+	if !ok {
+		return
+	}
+	stored.Set = ks
+
+	if err := storage.Store(t.url, stored); err != nil {
+		af.sendError(t.url, errors.Wrap(err, `failed to store set`))
+	}
+}
+
+// SetObserver sets an AutoRefreshObserver to be notified of refreshes
+// and expirations. Unlike the error sink, the observer is called
+// synchronously, so events are never dropped, but the observer must
+// not block for long.
+//
+// To disable, set a nil observer.
+func (af *AutoRefresh) SetObserver(o AutoRefreshObserver) {
+	af.muObserver.Lock()
+	af.observer = o
+	af.muObserver.Unlock()
+}
+
+func (af *AutoRefresh) getObserver() AutoRefreshObserver {
+	af.muObserver.RLock()
+	o := af.observer
+	af.muObserver.RUnlock()
+	if o == nil {
+		return AutoRefreshObserverFuncs{}
+	}
+	return o
+}
+
 // ErrorSink sets a channel to receive JWK fetch errors, if any.
 // Only the errors that occurred *after* the channel was set  will be sent.
 //
@@ -585,3 +938,99 @@ func (af *AutoRefresh) Snapshot() <-chan TargetSnapshot {
 	close(ch)
 	return ch
 }
+
+// KeepStaleSet returns a StalePolicy that keeps using the cached set
+// for as long as it cannot be refreshed. This is the default.
+func KeepStaleSet() StalePolicy {
+	return StalePolicy{}
+}
+
+// ExpireStaleSet returns a StalePolicy that stops using the cached set
+// once `maxAge` has passed since it was last successfully fetched.
+// After that, `(jwk.AutoRefresh).Fetch()` fetches the set synchronously,
+// and returns an error if that fails.
+func ExpireStaleSet(maxAge time.Duration) StalePolicy {
+	return StalePolicy{maxAge: maxAge}
+}
+
+// DiscardStaleSet returns a StalePolicy that discards the cached set
+// as soon as a refresh fails, so that `(jwk.AutoRefresh).Fetch()`
+// returns an error until the set can be fetched again.
+func DiscardStaleSet() StalePolicy {
+	return StalePolicy{discard: true}
+}
+
+func (o AutoRefreshObserverFuncs) RefreshStarted(url string) {
+	if o.OnRefreshStarted != nil {
+		o.OnRefreshStarted(url)
+	}
+}
+
+func (o AutoRefreshObserverFuncs) RefreshSucceeded(url string, diff KeyDiff) {
+	if o.OnRefreshSucceeded != nil {
+		o.OnRefreshSucceeded(url, diff)
+	}
+}
+
+func (o AutoRefreshObserverFuncs) RefreshFailed(url string, err error) {
+	if o.OnRefreshFailed != nil {
+		o.OnRefreshFailed(url, err)
+	}
+}
+
+func (o AutoRefreshObserverFuncs) TargetExpired(url string) {
+	if o.OnTargetExpired != nil {
+		o.OnTargetExpired(url)
+	}
+}
+
+// diffSets computes the keys that were added and removed between
+// old (which may be nil) and cur
+func diffSets(old, cur Set) KeyDiff {
+	var diff KeyDiff
+	oldKeys := thumbprintMap(old)
+	curKeys := thumbprintMap(cur)
+	for i := 0; i < cur.Len(); i++ {
+		key, _ := cur.Get(i)
+		if tp, ok := keyThumbprint(key); ok {
+			if _, ok := oldKeys[tp]; !ok {
+				diff.Added = append(diff.Added, key)
+			}
+		}
+	}
+
+	if old == nil {
+		return diff
+	}
+	for i := 0; i < old.Len(); i++ {
+		key, _ := old.Get(i)
+		if tp, ok := keyThumbprint(key); ok {
+			if _, ok := curKeys[tp]; !ok {
+				diff.Removed = append(diff.Removed, key)
+			}
+		}
+	}
+	return diff
+}
+
+func thumbprintMap(set Set) map[string]struct{} {
+	m := make(map[string]struct{})
+	if set == nil {
+		return m
+	}
+	for i := 0; i < set.Len(); i++ {
+		key, _ := set.Get(i)
+		if tp, ok := keyThumbprint(key); ok {
+			m[tp] = struct{}{}
+		}
+	}
+	return m
+}
+
+func keyThumbprint(key Key) (string, bool) {
+	tp, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", false
+	}
+	return string(tp), true
+}
@@ -7,11 +7,13 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/lestrrat-go/backoff/v2"
 	"github.com/lestrrat-go/iter/arrayiter"
+	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/stretchr/testify/assert"
@@ -282,6 +284,49 @@ func TestRefreshSnapshot(t *testing.T) {
 	}
 }
 
+func TestAutoRefreshRemove(t *testing.T) {
+	var requests int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"oct","k":"YWJyYWNhZGFicmE"}]}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.Configure(srv.URL, jwk.WithRefreshInterval(100*time.Millisecond))
+	if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+
+	ar.Remove(srv.URL)
+	ar.Remove(srv.URL) // no-op
+
+	if _, err := ar.Fetch(ctx, srv.URL); !assert.Error(t, err, `ar.Fetch should fail after ar.Remove`) {
+		return
+	}
+	for range ar.Snapshot() {
+		t.Errorf(`snapshot should be empty`)
+	}
+
+	// The url should no longer be refreshed
+	before := atomic.LoadInt64(&requests)
+	time.Sleep(300 * time.Millisecond)
+	if !assert.Equal(t, before, atomic.LoadInt64(&requests), `url should not be refreshed after ar.Remove`) {
+		return
+	}
+
+	// ... until it is configured again
+	ar.Configure(srv.URL)
+	if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+}
+
 func TestErrorSink(t *testing.T) {
 	t.Parallel()
 
@@ -341,3 +386,709 @@ func TestErrorSink(t *testing.T) {
 		})
 	}
 }
+
+func TestFetchAllowlist(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"oct","k":"YWJyYWNhZGFicmE"}]}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	allowed := srv.URL + "/allowed"
+	allowlist := jwk.NewMapAllowlist().Add(allowed)
+
+	t.Run("jwk.Fetch", func(t *testing.T) {
+		requests = 0
+		_, err := jwk.Fetch(ctx, allowed, jwk.WithFetchAllowlist(allowlist))
+		if !assert.NoError(t, err, `jwk.Fetch should succeed`) {
+			return
+		}
+
+		_, err = jwk.Fetch(ctx, srv.URL+"/denied", jwk.WithFetchAllowlist(allowlist))
+		if !assert.Error(t, err, `jwk.Fetch should fail`) {
+			return
+		}
+		if !assert.Equal(t, 1, requests, `denied URL should not be requested`) {
+			return
+		}
+	})
+	t.Run("AutoRefresh", func(t *testing.T) {
+		requests = 0
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(allowed, jwk.WithFetchAllowlist(allowlist))
+		ar.Configure(srv.URL+"/denied", jwk.WithFetchAllowlist(jwk.AllowlistFunc(func(string) bool { return false })))
+
+		_, err := ar.Fetch(ctx, allowed)
+		if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+		_, err = ar.Fetch(ctx, srv.URL+"/denied")
+		if !assert.Error(t, err, `ar.Fetch should fail`) {
+			return
+		}
+		if !assert.Equal(t, 1, requests, `denied URL should not be requested`) {
+			return
+		}
+	})
+}
+
+func TestFetchAllowlistRedirect(t *testing.T) {
+	var requests int64
+	denied := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"oct","k":"YWJyYWNhZGFicmE"}]}`))
+	}))
+	defer denied.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, denied.URL+"/jwks.json", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	allowed := srv.URL + "/jwks.json"
+	allowlist := jwk.NewMapAllowlist().Add(allowed)
+
+	t.Run("jwk.Fetch", func(t *testing.T) {
+		_, err := jwk.Fetch(ctx, allowed, jwk.WithFetchAllowlist(allowlist))
+		if !assert.Error(t, err, `jwk.Fetch should fail`) {
+			return
+		}
+		if !assert.Equal(t, int64(0), atomic.LoadInt64(&requests), `redirect target should not be requested`) {
+			return
+		}
+	})
+	t.Run("AutoRefresh", func(t *testing.T) {
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(allowed, jwk.WithFetchAllowlist(allowlist))
+		_, err := ar.Fetch(ctx, allowed)
+		if !assert.Error(t, err, `ar.Fetch should fail`) {
+			return
+		}
+		if !assert.Equal(t, int64(0), atomic.LoadInt64(&requests), `redirect target should not be requested`) {
+			return
+		}
+	})
+	t.Run("Custom HTTPClient", func(t *testing.T) {
+		// A client that follows redirects without consulting CheckRedirect
+		cl := httpClientFunc(func(req *http.Request) (*http.Response, error) {
+			return http.DefaultClient.Do(req)
+		})
+		_, err := jwk.Fetch(ctx, allowed, jwk.WithFetchAllowlist(allowlist), jwk.WithHTTPClient(cl))
+		if !assert.Error(t, err, `jwk.Fetch should fail`) {
+			return
+		}
+	})
+	t.Run("Allowed redirect", func(t *testing.T) {
+		allowlist := jwk.NewMapAllowlist().Add(allowed).Add(denied.URL + "/jwks.json")
+		_, err := jwk.Fetch(ctx, allowed, jwk.WithFetchAllowlist(allowlist))
+		if !assert.NoError(t, err, `jwk.Fetch should succeed`) {
+			return
+		}
+	})
+}
+
+type httpClientFunc func(*http.Request) (*http.Response, error)
+
+func (f httpClientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestLookupKeyID(t *testing.T) {
+	var requests int64
+	var generation int64 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"keys":[{"kty":"oct","kid":"key%d","k":"YWJyYWNhZGFicmE"}]}`, atomic.LoadInt64(&generation))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Refresh on unknown key ID", func(t *testing.T) {
+		atomic.StoreInt64(&requests, 0)
+		atomic.StoreInt64(&generation, 1)
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL, jwk.WithLookupRefreshInterval(time.Hour))
+
+		key, err := ar.LookupKeyID(ctx, srv.URL, `key1`)
+		if !assert.NoError(t, err, `ar.LookupKeyID should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `key1`, key.KeyID(), `key IDs should match`) {
+			return
+		}
+
+		// Rotate the keys
+		atomic.StoreInt64(&generation, 2)
+		key, err = ar.LookupKeyID(ctx, srv.URL, `key2`)
+		if !assert.NoError(t, err, `ar.LookupKeyID should succeed after keys were rotated`) {
+			return
+		}
+		if !assert.Equal(t, `key2`, key.KeyID(), `key IDs should match`) {
+			return
+		}
+		if !assert.Equal(t, int64(2), atomic.LoadInt64(&requests), `set should be refreshed once`) {
+			return
+		}
+
+		// Further refreshes are throttled
+		atomic.StoreInt64(&generation, 3)
+		_, err = ar.LookupKeyID(ctx, srv.URL, `key3`)
+		if !assert.Error(t, err, `ar.LookupKeyID should fail`) {
+			return
+		}
+		if !assert.Equal(t, int64(2), atomic.LoadInt64(&requests), `set should not be refreshed`) {
+			return
+		}
+	})
+	t.Run("Concurrent lookups", func(t *testing.T) {
+		atomic.StoreInt64(&requests, 0)
+		atomic.StoreInt64(&generation, 1)
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL)
+		if _, err := ar.Refresh(ctx, srv.URL); !assert.NoError(t, err, `ar.Refresh should succeed`) {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _ = ar.LookupKeyID(ctx, srv.URL, fmt.Sprintf(`random-%d`, i))
+			}(i)
+		}
+		wg.Wait()
+
+		if !assert.Equal(t, int64(2), atomic.LoadInt64(&requests), `set should be refreshed once`) {
+			return
+		}
+	})
+	t.Run("Concurrent lookups for a rotated key", func(t *testing.T) {
+		// Lookups that find the new key missing from the cached set must
+		// either perform the refresh or wait for it, even if they are
+		// throttled. The race is narrow, so repeat this a few times
+		for round := 0; round < 20; round++ {
+			atomic.StoreInt64(&requests, 0)
+			atomic.StoreInt64(&generation, 1)
+
+			ar := jwk.NewAutoRefresh(ctx)
+			ar.Configure(srv.URL, jwk.WithLookupRefreshInterval(time.Hour))
+			if _, err := ar.Refresh(ctx, srv.URL); !assert.NoError(t, err, `ar.Refresh should succeed`) {
+				return
+			}
+
+			// Rotate the keys, and have a burst of lookups for the new key
+			atomic.StoreInt64(&generation, 2)
+
+			const count = 100
+			start := make(chan struct{})
+			errs := make(chan error, count)
+			var wg sync.WaitGroup
+			for i := 0; i < count; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					_, err := ar.LookupKeyID(ctx, srv.URL, `key2`)
+					errs <- err
+				}()
+			}
+			close(start)
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				if !assert.NoError(t, err, `ar.LookupKeyID should succeed (round %d)`, round) {
+					return
+				}
+			}
+			if !assert.Equal(t, int64(2), atomic.LoadInt64(&requests), `set should be refreshed once (round %d)`, round) {
+				return
+			}
+		}
+	})
+	t.Run("Unregistered URL", func(t *testing.T) {
+		ar := jwk.NewAutoRefresh(ctx)
+		_, err := ar.LookupKeyID(ctx, srv.URL, `key1`)
+		if !assert.Error(t, err, `ar.LookupKeyID should fail`) {
+			return
+		}
+	})
+}
+
+func TestConditionalRefresh(t *testing.T) {
+	const lastModified = `Wed, 21 Oct 2015 07:28:00 GMT`
+	var mu sync.Mutex
+	var etag = `"v1"`
+	var full, notModified int
+	var gotIfNoneMatch, gotIfModifiedSince string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		gotIfNoneMatch = r.Header.Get(`If-None-Match`)
+		gotIfModifiedSince = r.Header.Get(`If-Modified-Since`)
+		w.Header().Set(`Cache-Control`, `max-age=3600`)
+		if gotIfNoneMatch == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		full++
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.Header().Set(`ETag`, etag)
+		w.Header().Set(`Last-Modified`, lastModified)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"keys":[{"kty":"oct","kid":%s,"k":"YWJyYWNhZGFicmE"}]}`, etag)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.Configure(srv.URL)
+
+	set1, err := ar.Fetch(ctx, srv.URL)
+	if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	mu.Lock()
+	if !assert.Equal(t, ``, gotIfNoneMatch, `first request should not be conditional`) {
+		mu.Unlock()
+		return
+	}
+	mu.Unlock()
+
+	t.Run("Not modified", func(t *testing.T) {
+		set2, err := ar.Refresh(ctx, srv.URL)
+		if !assert.NoError(t, err, `ar.Refresh should succeed`) {
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !assert.Equal(t, `"v1"`, gotIfNoneMatch, `If-None-Match should be sent`) {
+			return
+		}
+		if !assert.Equal(t, lastModified, gotIfModifiedSince, `If-Modified-Since should be sent`) {
+			return
+		}
+		if !assert.Equal(t, 1, notModified, `server should respond with 304`) {
+			return
+		}
+		if !assert.True(t, set1 == set2, `cached set should be kept`) {
+			return
+		}
+	})
+	t.Run("Modified", func(t *testing.T) {
+		mu.Lock()
+		etag = `"v2"`
+		mu.Unlock()
+
+		set3, err := ar.Refresh(ctx, srv.URL)
+		if !assert.NoError(t, err, `ar.Refresh should succeed`) {
+			return
+		}
+		mu.Lock()
+		fullCount := full
+		mu.Unlock()
+		if !assert.Equal(t, 2, fullCount, `server should respond with the full set`) {
+			return
+		}
+		if _, ok := set3.LookupKeyID(`v2`); !assert.True(t, ok, `set should be replaced`) {
+			return
+		}
+
+		// The new ETag is used from now on
+		if _, err := ar.Refresh(ctx, srv.URL); !assert.NoError(t, err, `ar.Refresh should succeed`) {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if !assert.Equal(t, `"v2"`, gotIfNoneMatch, `If-None-Match should be updated`) {
+			return
+		}
+		if !assert.Equal(t, 2, notModified, `server should respond with 304`) {
+			return
+		}
+	})
+}
+
+func TestAutoRefreshStorage(t *testing.T) {
+	var requests int64
+	var down int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&requests, 1)
+		if atomic.LoadInt64(&down) == 1 {
+			http.Error(w, `unavailable`, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.Header().Set(`ETag`, `"v1"`)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"oct","kid":"key1","k":"YWJyYWNhZGFicmE"}]}`))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := jwk.NewFileStorage(t.TempDir())
+
+	t.Run("Store fetched set", func(t *testing.T) {
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL, jwk.WithStorage(storage))
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+
+		stored, err := storage.Load(srv.URL)
+		if !assert.NoError(t, err, `storage.Load should succeed`) {
+			return
+		}
+		if !assert.NotNil(t, stored, `set should be stored`) {
+			return
+		}
+		if !assert.Equal(t, `"v1"`, stored.ETag, `ETag should be stored`) {
+			return
+		}
+		if !assert.WithinDuration(t, time.Now(), stored.FetchedAt, time.Minute, `fetch time should be stored`) {
+			return
+		}
+		if _, ok := stored.Set.LookupKeyID(`key1`); !assert.True(t, ok, `stored set should contain the key`) {
+			return
+		}
+	})
+	t.Run("Load stored set", func(t *testing.T) {
+		atomic.StoreInt64(&down, 1)
+		defer atomic.StoreInt64(&down, 0)
+		atomic.StoreInt64(&requests, 0)
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL, jwk.WithStorage(storage))
+		set, err := ar.Fetch(ctx, srv.URL)
+		if !assert.NoError(t, err, `ar.Fetch should succeed while the server is down`) {
+			return
+		}
+		if _, ok := set.LookupKeyID(`key1`); !assert.True(t, ok, `set should contain the key`) {
+			return
+		}
+		if !assert.Equal(t, int64(0), atomic.LoadInt64(&requests), `server should not be contacted`) {
+			return
+		}
+	})
+	t.Run("Stored set is too stale", func(t *testing.T) {
+		atomic.StoreInt64(&down, 1)
+		defer atomic.StoreInt64(&down, 0)
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL, jwk.WithStorage(storage), jwk.WithMaxStaleness(time.Nanosecond))
+		_, err := ar.Fetch(ctx, srv.URL)
+		if !assert.Error(t, err, `ar.Fetch should fail`) {
+			return
+		}
+	})
+	t.Run("Nothing stored", func(t *testing.T) {
+		stored, err := storage.Load(srv.URL + `/unknown`)
+		if !assert.NoError(t, err, `storage.Load should succeed`) {
+			return
+		}
+		if !assert.Nil(t, stored, `storage.Load should return nil`) {
+			return
+		}
+	})
+}
+
+// rotatingServer serves a set with a single symmetric key, which
+// can be rotated or made to fail
+type rotatingServer struct {
+	*httptest.Server
+	mu   sync.Mutex
+	kid  string
+	fail bool
+}
+
+func newRotatingServer() *rotatingServer {
+	s := &rotatingServer{kid: `abracadabra`}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set(`Content-Type`, `application/json`)
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"keys":[{"kty":"oct","kid":%q,"k":%q}]}`, s.kid, base64.EncodeToString([]byte(s.kid)))
+	}))
+	return s
+}
+
+func (s *rotatingServer) rotate(kid string) {
+	s.mu.Lock()
+	s.kid = kid
+	s.mu.Unlock()
+}
+
+func (s *rotatingServer) setFail(v bool) {
+	s.mu.Lock()
+	s.fail = v
+	s.mu.Unlock()
+}
+
+type observerEvent struct {
+	Name    string
+	Added   []string
+	Removed []string
+}
+
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []observerEvent
+}
+
+func (o *recordingObserver) add(ev observerEvent) {
+	o.mu.Lock()
+	o.events = append(o.events, ev)
+	o.mu.Unlock()
+}
+
+// take returns the events recorded so far, and clears them
+func (o *recordingObserver) take() []observerEvent {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	events := o.events
+	o.events = nil
+	return events
+}
+
+func (o *recordingObserver) RefreshStarted(string) {
+	o.add(observerEvent{Name: `started`})
+}
+
+func (o *recordingObserver) RefreshSucceeded(_ string, diff jwk.KeyDiff) {
+	ev := observerEvent{Name: `succeeded`}
+	for _, key := range diff.Added {
+		ev.Added = append(ev.Added, key.KeyID())
+	}
+	for _, key := range diff.Removed {
+		ev.Removed = append(ev.Removed, key.KeyID())
+	}
+	o.add(ev)
+}
+
+func (o *recordingObserver) RefreshFailed(string, error) {
+	o.add(observerEvent{Name: `failed`})
+}
+
+func (o *recordingObserver) TargetExpired(string) {
+	o.add(observerEvent{Name: `expired`})
+}
+
+func TestAutoRefreshObserver(t *testing.T) {
+	srv := newRotatingServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var obs recordingObserver
+	ar := jwk.NewAutoRefresh(ctx)
+	ar.SetObserver(&obs)
+	ar.Configure(srv.URL)
+
+	testcases := []struct {
+		Name     string
+		Setup    func()
+		Error    bool
+		Expected []observerEvent
+	}{
+		{
+			Name: `Initial fetch`,
+			Expected: []observerEvent{
+				{Name: `started`},
+				{Name: `succeeded`, Added: []string{`abracadabra`}},
+			},
+		},
+		{
+			Name: `Unchanged set`,
+			Expected: []observerEvent{
+				{Name: `started`},
+				{Name: `succeeded`},
+			},
+		},
+		{
+			Name:  `Rotated key`,
+			Setup: func() { srv.rotate(`sesame`) },
+			Expected: []observerEvent{
+				{Name: `started`},
+				{Name: `succeeded`, Added: []string{`sesame`}, Removed: []string{`abracadabra`}},
+			},
+		},
+		{
+			Name:  `Failed refresh`,
+			Setup: func() { srv.setFail(true) },
+			Error: true,
+			Expected: []observerEvent{
+				{Name: `started`},
+				{Name: `failed`},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.Setup != nil {
+				tc.Setup()
+			}
+			_, err := ar.Refresh(ctx, srv.URL)
+			if tc.Error {
+				if !assert.Error(t, err, `ar.Refresh should fail`) {
+					return
+				}
+			} else {
+				if !assert.NoError(t, err, `ar.Refresh should succeed`) {
+					return
+				}
+			}
+			if !assert.Equal(t, tc.Expected, obs.take(), `events should match`) {
+				return
+			}
+		})
+	}
+
+	// The default policy keeps the stale set
+	set, err := ar.Fetch(ctx, srv.URL)
+	if !assert.NoError(t, err, `ar.Fetch should succeed`) {
+		return
+	}
+	if _, ok := set.LookupKeyID(`sesame`); !assert.True(t, ok, `stale set should be kept`) {
+		return
+	}
+}
+
+func TestStalePolicy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	t.Run("Keep", func(t *testing.T) {
+		srv := newRotatingServer()
+		defer srv.Close()
+
+		var obs recordingObserver
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.SetObserver(&obs)
+		ar.Configure(srv.URL, jwk.WithStalePolicy(jwk.KeepStaleSet()))
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+
+		srv.setFail(true)
+		if _, err := ar.Refresh(ctx, srv.URL); !assert.Error(t, err, `ar.Refresh should fail`) {
+			return
+		}
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should return the stale set`) {
+			return
+		}
+		for _, ev := range obs.take() {
+			if !assert.NotEqual(t, `expired`, ev.Name, `target should not expire`) {
+				return
+			}
+		}
+	})
+	t.Run("Discard", func(t *testing.T) {
+		srv := newRotatingServer()
+		defer srv.Close()
+
+		var obs recordingObserver
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.SetObserver(&obs)
+		ar.Configure(srv.URL, jwk.WithStalePolicy(jwk.DiscardStaleSet()))
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+		obs.take()
+
+		srv.setFail(true)
+		if _, err := ar.Refresh(ctx, srv.URL); !assert.Error(t, err, `ar.Refresh should fail`) {
+			return
+		}
+		expected := []observerEvent{
+			{Name: `started`},
+			{Name: `failed`},
+			{Name: `expired`},
+		}
+		if !assert.Equal(t, expected, obs.take(), `target should expire`) {
+			return
+		}
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.Error(t, err, `ar.Fetch should fail`) {
+			return
+		}
+
+		srv.setFail(false)
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed after recovery`) {
+			return
+		}
+	})
+	t.Run("Expire", func(t *testing.T) {
+		srv := newRotatingServer()
+		defer srv.Close()
+
+		var obs recordingObserver
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.SetObserver(&obs)
+		ar.Configure(srv.URL, jwk.WithStalePolicy(jwk.ExpireStaleSet(500*time.Millisecond)))
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed`) {
+			return
+		}
+		obs.take()
+
+		// Failures before the set expires keep the set
+		srv.setFail(true)
+		if _, err := ar.Refresh(ctx, srv.URL); !assert.Error(t, err, `ar.Refresh should fail`) {
+			return
+		}
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should return the cached set`) {
+			return
+		}
+		obs.take()
+
+		time.Sleep(time.Second)
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.Error(t, err, `ar.Fetch should fail`) {
+			return
+		}
+		expected := []observerEvent{
+			{Name: `expired`},
+			{Name: `started`},
+			{Name: `failed`},
+		}
+		if !assert.Equal(t, expected, obs.take(), `target should expire`) {
+			return
+		}
+
+		srv.setFail(false)
+		if _, err := ar.Fetch(ctx, srv.URL); !assert.NoError(t, err, `ar.Fetch should succeed after recovery`) {
+			return
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package jwk
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/pkg/errors"
+)
+
+// StoredSet is a jwk.Set persisted by an AutoRefreshStorage, along with
+// the information that jwk.AutoRefresh needs to resume refreshing it.
+type StoredSet struct {
+	// Set is the last jwk.Set that was successfully fetched
+	Set Set
+	// FetchedAt is the time the set was last fetched or revalidated
+	FetchedAt time.Time
+	// NextRefresh is the time the set was scheduled to be refreshed next
+	NextRefresh time.Time
+	// ETag and LastModified are the values of the corresponding
+	// headers of the response, used to make conditional requests
+	ETag         string
+	LastModified string
+}
+
+// AutoRefreshStorage is used by jwk.AutoRefresh to persist the sets it
+// fetches, so that they are available immediately after a restart.
+// Use it with `jwk.WithStorage()`.
+//
+// Load should return a nil StoredSet and a nil error if nothing is
+// stored for the URL.
+type AutoRefreshStorage interface {
+	Load(url string) (*StoredSet, error)
+	Store(url string, s *StoredSet) error
+}
+
+// FileStorage is an AutoRefreshStorage that stores each set as a JSON
+// file in a directory. The name of each file is derived from the SHA-256
+// hash of its URL.
+type FileStorage struct {
+	dir string
+}
+
+// NewFileStorage creates a new FileStorage that stores files in `dir`.
+// The directory must exist.
+func NewFileStorage(dir string) *FileStorage {
+	return &FileStorage{dir: dir}
+}
+
+type storedSetFile struct {
+	URL          string          `json:"url"`
+	FetchedAt    time.Time       `json:"fetched_at"`
+	NextRefresh  time.Time       `json:"next_refresh"`
+	ETag         string          `json:"etag,omitempty"`
+	LastModified string          `json:"last_modified,omitempty"`
+	Set          json.RawMessage `json:"set"`
+}
+
+func (s *FileStorage) filename(url string) string {
+	h := sha256.Sum256([]byte(url))
+	return filepath.Join(s.dir, hex.EncodeToString(h[:])+`.json`)
+}
+
+func (s *FileStorage) Load(url string) (*StoredSet, error) {
+	buf, err := ioutil.ReadFile(s.filename(url))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, `failed to read stored set for %s`, url)
+	}
+
+	var f storedSetFile
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, errors.Wrapf(err, `failed to parse stored set for %s`, url)
+	}
+
+	// Guard against hash collisions and misplaced files
+	if f.URL != url {
+		return nil, errors.Errorf(`stored set is for %s, not %s`, f.URL, url)
+	}
+
+	set, err := Parse(f.Set)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to parse stored set for %s`, url)
+	}
+
+	return &StoredSet{
+		Set:          set,
+		FetchedAt:    f.FetchedAt,
+		NextRefresh:  f.NextRefresh,
+		ETag:         f.ETag,
+		LastModified: f.LastModified,
+	}, nil
+}
+
+func (s *FileStorage) Store(url string, stored *StoredSet) error {
+	setbuf, err := json.Marshal(stored.Set)
+	if err != nil {
+		return errors.Wrapf(err, `failed to marshal set for %s`, url)
+	}
+
+	buf, err := json.Marshal(storedSetFile{
+		URL:          url,
+		FetchedAt:    stored.FetchedAt,
+		NextRefresh:  stored.NextRefresh,
+		ETag:         stored.ETag,
+		LastModified: stored.LastModified,
+		Set:          setbuf,
+	})
+	if err != nil {
+		return errors.Wrapf(err, `failed to marshal stored set for %s`, url)
+	}
+
+	// Write to a temporary file first, so that readers never see
+	// a partially written file
+	tmp, err := ioutil.TempFile(s.dir, `.jwk-storage-`)
+	if err != nil {
+		return errors.Wrap(err, `failed to create temporary file`)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return errors.Wrapf(err, `failed to write stored set for %s`, url)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrapf(err, `failed to write stored set for %s`, url)
+	}
+
+	if err := os.Rename(tmp.Name(), s.filename(url)); err != nil {
+		return errors.Wrapf(err, `failed to write stored set for %s`, url)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package jwk
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var strictKeyUsage uint32
+
+// Settings controls global settings that are specific to JWKs.
+func Settings(options ...GlobalOption) {
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identStrictKeyUsage{}:
+			var v uint32
+			if option.Value().(bool) {
+				v = 1
+			}
+			atomic.StoreUint32(&strictKeyUsage, v)
+		}
+	}
+}
+
+// StrictKeyUsage returns true if `jwk.WithStrictKeyUsage(true)` has been
+// passed to `jwk.Settings()`.
+func StrictKeyUsage() bool {
+	return atomic.LoadUint32(&strictKeyUsage) == 1
+}
+
+// ValidateKeyUsage returns an error describing why `key` may not be used
+// for the purpose `use` with the algorithm `alg`. `ops` lists the values
+// of "key_ops" that permit the operation, any one of which is sufficient.
+//
+// A key may be used if each of the following fields is either absent, or:
+//
+//   * "use" is `use`
+//   * "key_ops" contains one of `ops`
+//   * "alg" is `alg`
+//
+// `jws` and `jwe` call this function for the keys they are given when
+// strict key usage checks are enabled (see `jwk.WithStrictKeyUsage()`).
+func ValidateKeyUsage(key Key, use KeyUsageType, alg string, ops ...KeyOperation) error {
+	if len(ops) == 0 {
+		return errors.New(`no key operations specified`)
+	}
+
+	if usage := key.KeyUsage(); usage != "" && usage != use.String() {
+		return errors.Errorf(`%s may not be used to %s: "use" is %q`, describeKey(key), ops[0], usage)
+	}
+
+	if keyops := key.KeyOps(); len(keyops) > 0 {
+		var permitted bool
+		for _, op := range ops {
+			if keyops.contains(op) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			list := make([]string, len(keyops))
+			for i, op := range keyops {
+				list[i] = `"` + string(op) + `"`
+			}
+			return errors.Errorf(`%s may not be used to %s: "key_ops" is [%s]`, describeKey(key), ops[0], strings.Join(list, `, `))
+		}
+	}
+
+	if keyalg := key.Algorithm(); keyalg != "" && keyalg != alg {
+		return errors.Errorf(`%s may not be used to %s using %q: "alg" is %q`, describeKey(key), ops[0], alg, keyalg)
+	}
+	return nil
+}
+
+func describeKey(key Key) string {
+	if kid := key.KeyID(); kid != "" {
+		return `key with key ID "` + kid + `"`
+	}
+	return `key`
+}
@@ -1,9 +1,19 @@
 package jwk_test
 
 import (
+	"crypto/sha1" //nolint:gosec
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/stretchr/testify/assert"
@@ -125,3 +135,164 @@ func Test_X5CHeader(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyCertificateChain(t *testing.T) {
+	const dnsName = "example.com"
+
+	rawkey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	root, chain, err := jwxtest.GenerateCertificateChain(&rawkey.PublicKey, dnsName)
+	if !assert.NoError(t, err, `jwxtest.GenerateCertificateChain should succeed`) {
+		return
+	}
+
+	encoded := make([]string, len(chain))
+	for i, cert := range chain {
+		encoded[i] = base64.StdEncoding.EncodeToString(cert.Raw)
+	}
+
+	makeKey := func(t *testing.T, raw interface{}) jwk.Key {
+		t.Helper()
+		key, err := jwk.New(raw)
+		if !assert.NoError(t, err, `jwk.New should succeed`) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, key.Set(jwk.X509CertChainKey, encoded), `key.Set should succeed`) {
+			t.FailNow()
+		}
+		return key
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	opts := x509.VerifyOptions{
+		DNSName:   dnsName,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	t.Run("Valid chain", func(t *testing.T) {
+		for _, raw := range []interface{}{rawkey, &rawkey.PublicKey} {
+			leaf, err := jwk.VerifyCertificateChain(makeKey(t, raw), roots, opts)
+			if !assert.NoError(t, err, `jwk.VerifyCertificateChain should succeed`) {
+				return
+			}
+			if !assert.Equal(t, chain[0], leaf, `leaf certificate should be returned`) {
+				return
+			}
+		}
+	})
+	t.Run("Unknown root", func(t *testing.T) {
+		other, _, err := jwxtest.GenerateCertificateChain(&rawkey.PublicKey, dnsName)
+		if !assert.NoError(t, err, `jwxtest.GenerateCertificateChain should succeed`) {
+			return
+		}
+		pool := x509.NewCertPool()
+		pool.AddCert(other)
+
+		_, err = jwk.VerifyCertificateChain(makeKey(t, rawkey), pool, opts)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+	t.Run("DNS name mismatch", func(t *testing.T) {
+		o := opts
+		o.DNSName = "example.org"
+		_, err := jwk.VerifyCertificateChain(makeKey(t, rawkey), roots, o)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+	t.Run("Expired", func(t *testing.T) {
+		o := opts
+		o.CurrentTime = time.Now().Add(48 * time.Hour)
+		_, err := jwk.VerifyCertificateChain(makeKey(t, rawkey), roots, o)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+	t.Run("Key does not match leaf", func(t *testing.T) {
+		other, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+			return
+		}
+		_, err = jwk.VerifyCertificateChain(makeKey(t, other), roots, opts)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+	t.Run("Thumbprints", func(t *testing.T) {
+		sum1 := sha1.Sum(chain[0].Raw)
+		sum256 := sha256.Sum256(chain[0].Raw)
+
+		key := makeKey(t, rawkey)
+		_ = key.Set(jwk.X509CertThumbprintKey, base64.RawURLEncoding.EncodeToString(sum1[:]))
+		_ = key.Set(jwk.X509CertThumbprintS256Key, base64.RawURLEncoding.EncodeToString(sum256[:]))
+		_, err := jwk.VerifyCertificateChain(key, roots, opts)
+		if !assert.NoError(t, err, `jwk.VerifyCertificateChain should succeed`) {
+			return
+		}
+
+		// thumbprint of the intermediate instead of the leaf
+		sum256 = sha256.Sum256(chain[1].Raw)
+		_ = key.Set(jwk.X509CertThumbprintS256Key, base64.RawURLEncoding.EncodeToString(sum256[:]))
+		_, err = jwk.VerifyCertificateChain(key, roots, opts)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+	t.Run("Roots are required", func(t *testing.T) {
+		_, err := jwk.VerifyCertificateChain(makeKey(t, rawkey), nil, x509.VerifyOptions{Roots: roots, DNSName: dnsName})
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+	t.Run("System roots are not used", func(t *testing.T) {
+		// Make the system root pool trust the root certificate, as if it
+		// were a publicly trusted CA. This only works on platforms that
+		// honor SSL_CERT_FILE, and if the pool has not been loaded yet
+		dir, err := ioutil.TempDir("", "jwx-x5c")
+		if !assert.NoError(t, err, `ioutil.TempDir should succeed`) {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		certfile := filepath.Join(dir, "roots.pem")
+		if !assert.NoError(t, ioutil.WriteFile(certfile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: root.Raw}), 0600), `ioutil.WriteFile should succeed`) {
+			return
+		}
+		for k, v := range map[string]string{"SSL_CERT_FILE": certfile, "SSL_CERT_DIR": dir} {
+			prev, ok := os.LookupEnv(k)
+			os.Setenv(k, v)
+			if ok {
+				defer os.Setenv(k, prev)
+			} else {
+				defer os.Unsetenv(k)
+			}
+		}
+
+		intermediates := x509.NewCertPool()
+		intermediates.AddCert(chain[1])
+		if _, err := chain[0].Verify(x509.VerifyOptions{DNSName: dnsName, Intermediates: intermediates}); err != nil {
+			t.Logf(`the chain is not trusted by the system roots, so this test is not conclusive: %s`, err)
+		}
+
+		_, err = jwk.VerifyCertificateChain(makeKey(t, rawkey), nil, opts)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+		_, err = jwk.VerifyCertificateChain(makeKey(t, rawkey), x509.NewCertPool(), opts)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+	t.Run("No x5c", func(t *testing.T) {
+		key, _ := jwk.New(rawkey)
+		_, err := jwk.VerifyCertificateChain(key, roots, opts)
+		if !assert.Error(t, err, `jwk.VerifyCertificateChain should fail`) {
+			return
+		}
+	})
+}
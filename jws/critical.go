@@ -0,0 +1,123 @@
+package jws
+
+import (
+	"sync"
+
+	"github.com/lestrrat-go/jwx/internal/critical"
+	"github.com/pkg/errors"
+)
+
+// CriticalHandler processes a header extension that is listed in the
+// "crit" header field (RFC7515 Section 4.1.11).
+//
+// HandleCritical is called with the name of the extension and the
+// protected headers of the signature, after the signature has been
+// successfully verified. If it returns an error, the signature is
+// treated as invalid.
+type CriticalHandler interface {
+	HandleCritical(string, Headers) error
+}
+
+// CriticalHandlerFunc is a CriticalHandler represented by a function
+type CriticalHandlerFunc func(string, Headers) error
+
+func (f CriticalHandlerFunc) HandleCritical(name string, hdrs Headers) error {
+	return f(name, hdrs)
+}
+
+var muCriticalHandlers sync.RWMutex
+var criticalHandlers = make(map[string]CriticalHandler)
+
+// builtinCriticalExtensions lists the extensions that this library
+// understands without the user having to register a handler
+var builtinCriticalExtensions = map[string]struct{}{
+	"b64": {}, // RFC7797
+}
+
+// registeredHeaderNames lists the header names defined in RFC7515,
+// which must not be listed in "crit"
+var registeredHeaderNames = map[string]struct{}{
+	AlgorithmKey:              {},
+	ContentTypeKey:            {},
+	CriticalKey:               {},
+	JWKKey:                    {},
+	JWKSetURLKey:              {},
+	KeyIDKey:                  {},
+	TypeKey:                   {},
+	X509CertChainKey:          {},
+	X509CertThumbprintKey:     {},
+	X509CertThumbprintS256Key: {},
+	X509URLKey:                {},
+}
+
+// RegisterCriticalHandler registers a handler for the header extension
+// `name`, so that messages which list `name` in their "crit" header field
+// can be verified. This option has a global effect. To register a handler
+// for a single call to `jws.Verify()`, use `jws.WithCriticalHandler()`.
+//
+// Passing a nil handler removes the handler for `name`.
+//
+// Messages which list extensions that neither have a handler nor are
+// built into this library (currently only "b64") fail verification.
+func RegisterCriticalHandler(name string, h CriticalHandler) {
+	muCriticalHandlers.Lock()
+	defer muCriticalHandlers.Unlock()
+	if h == nil {
+		delete(criticalHandlers, name)
+		return
+	}
+	criticalHandlers[name] = h
+}
+
+func lookupCriticalHandler(name string, local map[string]CriticalHandler) (CriticalHandler, bool) {
+	if h, ok := local[name]; ok && h != nil {
+		return h, true
+	}
+
+	muCriticalHandlers.RLock()
+	defer muCriticalHandlers.RUnlock()
+	h, ok := criticalHandlers[name]
+	return h, ok
+}
+
+// checkCritical makes sure that the "crit" header field is well-formed,
+// and that all of the extensions listed in it are understood.
+func checkCritical(protected, public Headers, local map[string]CriticalHandler) error {
+	if public != nil {
+		if _, ok := public.Get(CriticalKey); ok {
+			return errors.New(`"crit" must be in the protected header`)
+		}
+	}
+
+	if protected == nil {
+		return nil
+	}
+
+	if _, ok := protected.Get(CriticalKey); !ok {
+		return nil
+	}
+
+	return critical.Check(protected, protected.Critical(), registeredHeaderNames, func(name string) bool {
+		if _, ok := builtinCriticalExtensions[name]; ok {
+			return true
+		}
+		_, ok := lookupCriticalHandler(name, local)
+		return ok
+	})
+}
+
+// handleCritical calls the handlers for the extensions listed in the
+// "crit" header field. checkCritical must have been called beforehand.
+func handleCritical(protected Headers, local map[string]CriticalHandler) error {
+	if protected == nil {
+		return nil
+	}
+
+	return critical.Handle(protected.Critical(), builtinCriticalExtensions, func(name string) error {
+		h, ok := lookupCriticalHandler(name, local)
+		if !ok {
+			return errors.New(`unsupported critical extension`)
+		}
+		return h.HandleCritical(name, protected)
+	})
+}
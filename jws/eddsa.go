@@ -5,6 +5,7 @@ import (
 	"crypto/ed25519"
 	"crypto/rand"
 
+	"github.com/cloudflare/circl/sign/ed448"
 	"github.com/lestrrat-go/jwx/internal/keyconv"
 	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/pkg/errors"
@@ -25,17 +26,22 @@ func (s eddsaSigner) Sign(payload []byte, key interface{}) ([]byte, error) {
 		return nil, errors.New(`missing private key while signing payload`)
 	}
 
-	// The ed25519.PrivateKey object implements crypto.Signer, so we should
-	// simply accept a crypto.Signer here.
+	// The ed25519.PrivateKey and ed448.PrivateKey objects implement
+	// crypto.Signer, so we should simply accept a crypto.Signer here.
 	signer, ok := key.(crypto.Signer)
 	if !ok {
 		// This fallback exists for cases when jwk.Key was passed, or
 		// users gave us a pointer instead of non-pointer, etc.
 		var privkey ed25519.PrivateKey
-		if err := keyconv.Ed25519PrivateKey(&privkey, key); err != nil {
-			return nil, errors.Wrapf(err, `failed to retrieve ed25519.PrivateKey out of %T`, key)
+		if err := keyconv.Ed25519PrivateKey(&privkey, key); err == nil {
+			signer = privkey
+		} else {
+			var ed448key ed448.PrivateKey
+			if err := keyconv.Ed448PrivateKey(&ed448key, key); err != nil {
+				return nil, errors.Errorf(`failed to retrieve ed25519.PrivateKey or ed448.PrivateKey out of %T`, key)
+			}
+			signer = ed448key
 		}
-		signer = privkey
 	}
 	return signer.Sign(rand.Reader, payload, crypto.Hash(0))
 }
@@ -52,10 +58,18 @@ func (v eddsaVerifier) Verify(payload, signature []byte, key interface{}) (err e
 	}
 
 	var pubkey ed25519.PublicKey
-	if err := keyconv.Ed25519PublicKey(&pubkey, key); err != nil {
-		return errors.Wrapf(err, `failed to retrieve ed25519.PublicKey out of %T`, key)
+	if err := keyconv.Ed25519PublicKey(&pubkey, key); err == nil {
+		if !ed25519.Verify(pubkey, payload, signature) {
+			return errors.New(`failed to match EdDSA signature`)
+		}
+		return nil
+	}
+
+	var ed448key ed448.PublicKey
+	if err := keyconv.Ed448PublicKey(&ed448key, key); err != nil {
+		return errors.Errorf(`failed to retrieve ed25519.PublicKey or ed448.PublicKey out of %T`, key)
 	}
-	if !ed25519.Verify(pubkey, payload, signature) {
+	if !ed448.Verify(ed448key, payload, signature, "") {
 		return errors.New(`failed to match EdDSA signature`)
 	}
 	return nil
@@ -24,6 +24,7 @@ package jws
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"strings"
@@ -95,17 +96,49 @@ var muSigner = &sync.Mutex{}
 // If the headers contain "b64" field, then the boolean value for the field
 // is respected when creating the compact serialization form. That is,
 // if you specify a header with `{"b64": false}`, then the payload is
-// not base64 encoded.
+// not base64 encoded, and "b64" is added to the "crit" header field as
+// required by RFC7797.
+//
+// To create a JWS message with a detached payload (RFC7515 Appendix F),
+// pass a nil `payload` and specify the content to be signed using the
+// `jws.WithDetachedContent()` option. The result is in the form of
+// "header..signature", and the same content must be provided via
+// `jws.WithDetachedContent()` when verifying it.
+//
+// If strict key usage checks are enabled using `jwk.WithStrictKeyUsage()`
+// or `jws.WithStrictKeyUsage()`, a jwk.Key may only be used if its "use",
+// "key_ops", and "alg" fields permit signing using `alg`.
 func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...SignOption) ([]byte, error) {
 	var hdrs Headers
+	var detachedPayload []byte
+	var detached bool
+	strict := jwk.StrictKeyUsage()
 	for _, o := range options {
 		//nolint:forcetypeassert
 		switch o.Ident() {
 		case identHeaders{}:
 			hdrs = o.Value().(Headers)
+		case identDetachedContent{}:
+			detachedPayload = o.Value().([]byte)
+			detached = true
+		case identStrictKeyUsage{}:
+			strict = o.Value().(bool)
+		}
+	}
+
+	if strict {
+		if err := validateSigningKey(key, alg); err != nil {
+			return nil, err
 		}
 	}
 
+	if detached {
+		if len(payload) > 0 {
+			return nil, errors.New(`can't specify both payload and detached payload`)
+		}
+		payload = detachedPayload
+	}
+
 	muSigner.Lock()
 	signer, ok := signers[alg]
 	if !ok {
@@ -120,7 +153,7 @@ func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options .
 	muSigner.Unlock()
 
 	sig := &Signature{protected: hdrs}
-	_, signature, err := sig.Sign(payload, signer, key)
+	_, signature, err := sig.sign(payload, signer, key, detached)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed sign payload`)
 	}
@@ -134,12 +167,28 @@ func Sign(payload []byte, alg jwa.SignatureAlgorithm, key interface{}, options .
 //
 // Use `jws.WithSigner(...)` to specify values how to generate
 // each signature in the `"signatures": [ ... ]` field.
+//
+// If the protected headers contain `{"b64": false}`, the payload is
+// not base64 encoded (RFC7797). All signers must agree on the value of "b64".
+//
+// To create a JWS message with a detached payload, pass a nil `payload`
+// and specify the content to be signed using `jws.WithDetachedContent()`.
+// The "payload" member is then omitted from the result.
 func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 	var signers []*payloadSigner
+	var detachedPayload []byte
+	var detached bool
+	strict := jwk.StrictKeyUsage()
 	for _, o := range options {
+		//nolint:forcetypeassert
 		switch o.Ident() {
 		case identPayloadSigner{}:
 			signers = append(signers, o.Value().(*payloadSigner))
+		case identDetachedContent{}:
+			detachedPayload = o.Value().([]byte)
+			detached = true
+		case identStrictKeyUsage{}:
+			strict = o.Value().(bool)
 		}
 	}
 
@@ -147,31 +196,66 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 		return nil, errors.New(`no signers provided`)
 	}
 
-	var result Message
+	if strict {
+		for i, signer := range signers {
+			if err := validateSigningKey(signer.key, signer.Algorithm()); err != nil {
+				return nil, errors.Wrapf(err, `invalid key for signer #%d`, i)
+			}
+		}
+	}
 
-	result.payload = payload
+	if detached {
+		if len(payload) > 0 {
+			return nil, errors.New(`can't specify both payload and detached payload`)
+		}
+		payload = detachedPayload
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var result Message
+	if !detached {
+		result.payload = payload
+	}
 
 	result.signatures = make([]*Signature, 0, len(signers))
 	for i, signer := range signers {
-		protected := signer.ProtectedHeader()
-		if protected == nil {
-			protected = NewHeaders()
+		// Only the protected headers are part of the signing input.
+		// Work on a copy, as we need to add "alg" and friends
+		protected := NewHeaders()
+		if h := signer.ProtectedHeader(); h != nil {
+			if err := h.Copy(ctx, protected); err != nil {
+				return nil, errors.Wrapf(err, `failed to copy protected headers for signer #%d`, i)
+			}
 		}
 
-		if err := protected.Set(AlgorithmKey, signer.Algorithm()); err != nil {
-			return nil, errors.Wrap(err, `failed to set header`)
+		if err := setSigningHeaders(protected, signer.Algorithm(), signer.key); err != nil {
+			return nil, errors.Wrapf(err, `failed to set headers for signer #%d`, i)
 		}
 
-		sig := &Signature{
-			headers:   signer.PublicHeader(),
-			protected: protected,
+		b64 := getB64Value(protected)
+		if i == 0 {
+			result.b64 = b64
+		} else if result.b64 != b64 {
+			return nil, errors.New(`b64 value must be the same for all signatures`)
 		}
-		_, _, err := sig.Sign(payload, signer.signer, signer.key)
+
+		hdrbuf, err := json.Marshal(protected)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to marshal headers for signer #%d`, i)
+		}
+
+		signature, err := signer.Sign(signingInput(base64.EncodeToString(hdrbuf), payload, b64))
 		if err != nil {
 			return nil, errors.Wrapf(err, `failed to generate signature for signer #%d (alg=%s)`, i, signer.Algorithm())
 		}
 
-		result.signatures = append(result.signatures, sig)
+		result.signatures = append(result.signatures, &Signature{
+			headers:   signer.PublicHeader(),
+			protected: protected,
+			signature: signature,
+		})
 	}
 
 	return json.Marshal(result)
@@ -186,17 +270,76 @@ func SignMulti(payload []byte, options ...Option) ([]byte, error) {
 // `Verifier` in `verify` subpackage, and call `Verify` method on it.
 // If you need to access signatures and JOSE headers in a JWS message,
 // use `Parse` function to get `Message` object.
+//
+// If the message contains a "crit" header field, all of the extensions
+// listed in it must be understood. Use `jws.RegisterCriticalHandler()`
+// or `jws.WithCriticalHandler()` to handle your own extensions.
+//
+// Instead of a single `alg` and `key` pair, a KeyProvider can be specified
+// using `jws.WithKeyProvider()`. In this case `alg` must be empty and `key`
+// must be nil.
+//
+// To verify the message using the certificate chain in the "x5c" header
+// field, use `jws.WithVerifyCertificateChain()`.
+//
+// If strict key usage checks are enabled using `jwk.WithStrictKeyUsage()`
+// or `jws.WithStrictKeyUsage()`, a jwk.Key may only be used if its "use",
+// "key_ops", and "alg" fields permit verifying using `alg`.
 func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...VerifyOption) ([]byte, error) {
-	var dst *Message
-	var detachedPayload []byte
+	vctx := verifyCtx{
+		ctx:    context.Background(),
+		alg:    alg,
+		key:    key,
+		strict: jwk.StrictKeyUsage(),
+	}
+	var x5cProvider *x5cKeyProvider
+	var detachedPayloadSet bool
 	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
 		case identMessage{}:
-			dst = option.Value().(*Message)
+			vctx.dst = option.Value().(*Message)
 		case identDetachedPayload{}:
-			detachedPayload = option.Value().([]byte)
+			if vctx.detachedContent {
+				return nil, errors.New(`jws.WithDetachedPayload() and jws.WithDetachedContent() cannot be used together`)
+			}
+			vctx.detachedPayload = option.Value().([]byte)
+			detachedPayloadSet = true
+		case identDetachedContent{}:
+			if detachedPayloadSet {
+				return nil, errors.New(`jws.WithDetachedPayload() and jws.WithDetachedContent() cannot be used together`)
+			}
+			vctx.detachedPayload = option.Value().([]byte)
+			vctx.detachedContent = true
+		case identCriticalHandler{}:
+			ch := option.Value().(*criticalHandlerPair)
+			if vctx.criticalHandlers == nil {
+				vctx.criticalHandlers = make(map[string]CriticalHandler)
+			}
+			vctx.criticalHandlers[ch.name] = ch.handler
+		case identKeyProvider{}:
+			vctx.keyProvider = option.Value().(KeyProvider)
+		case identContext{}:
+			vctx.ctx = option.Value().(context.Context)
+		case identVerifyCertificateChain{}:
+			x5cOpts := option.Value().(*certificateChainOptions)
+			x5cProvider = &x5cKeyProvider{roots: x5cOpts.roots, opts: x5cOpts.opts}
+		case identStrictKeyUsage{}:
+			vctx.strict = option.Value().(bool)
+		}
+	}
+
+	if x5cProvider != nil {
+		if vctx.keyProvider != nil {
+			return nil, errors.New(`jws.WithVerifyCertificateChain() cannot be used together with jws.WithKeyProvider()`)
+		}
+		if key != nil {
+			return nil, errors.New(`key must not be specified when using jws.WithVerifyCertificateChain()`)
 		}
+		x5cProvider.alg = alg
+		vctx.keyProvider = x5cProvider
+	} else if vctx.keyProvider != nil && (alg != "" || key != nil) {
+		return nil, errors.New(`alg and key must not be specified when using jws.WithKeyProvider()`)
 	}
 
 	buf = bytes.TrimSpace(buf)
@@ -205,9 +348,75 @@ func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...
 	}
 
 	if buf[0] == '{' {
-		return verifyJSON(buf, alg, key, dst, detachedPayload)
+		return verifyJSON(buf, &vctx)
+	}
+	return verifyCompact(buf, &vctx)
+}
+
+type verifyCtx struct {
+	ctx              context.Context
+	alg              jwa.SignatureAlgorithm
+	key              interface{}
+	keyProvider      KeyProvider
+	dst              *Message
+	detachedPayload  []byte
+	detachedContent  bool
+	criticalHandlers map[string]CriticalHandler
+	strict           bool
+}
+
+// candidates returns the keys that should be tried to verify `sig`.
+// When strict key usage checks are enabled, keys that may not be used
+// to verify are excluded
+func (vctx *verifyCtx) candidates(sig *Signature, m *Message) ([]KeyCandidate, error) {
+	if vctx.keyProvider == nil {
+		if vctx.strict {
+			if err := validateVerificationKey(vctx.key, vctx.alg); err != nil {
+				return nil, err
+			}
+		}
+		return []KeyCandidate{{Algorithm: vctx.alg, Key: vctx.key}}, nil
+	}
+
+	keys, err := vctx.keyProvider.FetchKeys(vctx.ctx, sig, m)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to fetch keys from key provider`)
+	}
+	if !vctx.strict {
+		return keys, nil
+	}
+
+	var lastErr error
+	var permitted []KeyCandidate
+	for _, key := range keys {
+		if err := validateVerificationKey(key.Key, key.Algorithm); err != nil {
+			lastErr = err
+			continue
+		}
+		permitted = append(permitted, key)
+	}
+	if len(permitted) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return permitted, nil
+}
+
+// validateSigningKey checks that `key`, if it is a jwk.Key, may be used
+// to sign using `alg`
+func validateSigningKey(key interface{}, alg jwa.SignatureAlgorithm) error {
+	if jwkKey, ok := key.(jwk.Key); ok {
+		return jwk.ValidateKeyUsage(jwkKey, jwk.ForSignature, alg.String(), jwk.KeyOpSign)
+	}
+	return nil
+}
+
+// validateVerificationKey checks that `key`, if it is a jwk.Key, may be
+// used to verify using `alg`
+func validateVerificationKey(key interface{}, alg jwa.SignatureAlgorithm) error {
+	if jwkKey, ok := key.(jwk.Key); ok {
+		return jwk.ValidateKeyUsage(jwkKey, jwk.ForSignature, alg.String(), jwk.KeyOpVerify)
 	}
-	return verifyCompact(buf, alg, key, dst, detachedPayload)
+	return nil
 }
 
 // VerifySet uses keys store in a jwk.Set to verify the payload in `buf`.
@@ -219,20 +428,8 @@ func Verify(buf []byte, alg jwa.SignatureAlgorithm, key interface{}, options ...
 // Furthermore if the JWS signature asks for a spefici "kid", the
 // `jwk.Key` must have the same "kid" as the signature.
 func VerifySet(buf []byte, set jwk.Set) ([]byte, error) {
-	n := set.Len()
-	for i := 0; i < n; i++ {
-		key, ok := set.Get(i)
-		if !ok {
-			continue
-		}
-		if key.Algorithm() == "" { // algorithm is not
-			continue
-		}
-
-		if usage := key.KeyUsage(); usage != "" && usage != jwk.ForSignature.String() {
-			continue
-		}
-
+	keys := jwk.FindKeys(set, jwk.MatchKeyUsage(jwk.ForSignature), hasAlgorithm)
+	for _, key := range keys {
 		buf, err := Verify(buf, jwa.SignatureAlgorithm(key.Algorithm()), key)
 		if err != nil {
 			continue
@@ -244,23 +441,24 @@ func VerifySet(buf []byte, set jwk.Set) ([]byte, error) {
 	return nil, errors.New(`failed to verify message with any of the keys in the jwk.Set object`)
 }
 
-func verifyJSON(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, dst *Message, detachedPayload []byte) ([]byte, error) {
-	verifier, err := NewVerifier(alg)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create verifier")
-	}
+// hasAlgorithm matches keys that specify "alg", as VerifySet uses the
+// algorithm of the key to verify the message
+var hasAlgorithm = jwk.KeyFilterFunc(func(key jwk.Key) bool {
+	return key.Algorithm() != ""
+})
 
+func verifyJSON(signed []byte, vctx *verifyCtx) ([]byte, error) {
 	var m Message
 	if err := json.Unmarshal(signed, &m); err != nil {
 		return nil, errors.Wrap(err, `failed to unmarshal JSON message`)
 	}
 
-	if len(m.payload) != 0 && detachedPayload != nil {
+	if len(m.payload) != 0 && vctx.detachedPayload != nil {
 		return nil, errors.New(`can't specify detached payload for JWS with payload`)
 	}
 
-	if detachedPayload != nil {
-		m.payload = detachedPayload
+	if vctx.detachedPayload != nil {
+		m.payload = vctx.detachedPayload
 	}
 
 	// Pre-compute the base64 encoded version of payload
@@ -274,14 +472,17 @@ func verifyJSON(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, dst
 	buf := pool.GetBytesBuffer()
 	defer pool.ReleaseBytesBuffer(buf)
 
+	var lastErr error
 	for i, sig := range m.signatures {
-		buf.Reset()
-		if hdr := sig.headers; hdr != nil && hdr.KeyID() != "" {
-			if jwkKey, ok := key.(jwk.Key); ok {
-				if jwkKey.KeyID() != hdr.KeyID() {
-					continue
-				}
-			}
+		if err := checkCritical(sig.protected, sig.headers, vctx.criticalHandlers); err != nil {
+			lastErr = errors.Wrapf(err, `invalid "crit" for signature #%d`, i+1)
+			continue
+		}
+
+		keys, err := vctx.candidates(sig, &m)
+		if err != nil {
+			lastErr = errors.Wrapf(err, `signature #%d`, i+1)
+			continue
 		}
 
 		protected, err := json.Marshal(sig.protected)
@@ -289,17 +490,45 @@ func verifyJSON(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, dst
 			return nil, errors.Wrapf(err, `failed to marshal "protected" for signature #%d`, i+1)
 		}
 
+		buf.Reset()
 		buf.WriteString(base64.EncodeToString(protected))
 		buf.WriteByte('.')
 		buf.WriteString(payload)
 
-		if err := verifier.Verify(buf.Bytes(), sig.signature, key); err == nil {
-			if dst != nil {
-				*dst = m
+		for _, key := range keys {
+			if hdr := sig.headers; hdr != nil && hdr.KeyID() != "" {
+				if jwkKey, ok := key.Key.(jwk.Key); ok {
+					if jwkKey.KeyID() != hdr.KeyID() {
+						continue
+					}
+				}
+			}
+
+			verifier, err := NewVerifier(key.Algorithm)
+			if err != nil {
+				lastErr = errors.Wrapf(err, `failed to create verifier for signature #%d`, i+1)
+				continue
+			}
+
+			if err := verifier.Verify(buf.Bytes(), sig.signature, key.Key); err != nil {
+				lastErr = errors.Wrapf(err, `failed to verify signature #%d`, i+1)
+				continue
+			}
+
+			if err := handleCritical(sig.protected, vctx.criticalHandlers); err != nil {
+				return nil, errors.Wrapf(err, `signature #%d verified, but failed to process "crit"`, i+1)
+			}
+
+			if vctx.dst != nil {
+				*vctx.dst = m
 			}
 			return m.payload, nil
 		}
 	}
+
+	if lastErr != nil {
+		return nil, errors.Wrap(lastErr, `could not verify with any of the signatures`)
+	}
 	return nil, errors.New(`could not verify with any of the signatures`)
 }
 
@@ -319,32 +548,12 @@ func getB64Value(hdr Headers) bool {
 	return b64
 }
 
-func verifyCompact(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, dst *Message, detachedPayload []byte) ([]byte, error) {
+func verifyCompact(signed []byte, vctx *verifyCtx) ([]byte, error) {
 	protected, payload, signature, err := SplitCompact(signed)
 	if err != nil {
 		return nil, errors.Wrap(err, `failed extract from compact serialization format`)
 	}
 
-	verifier, err := NewVerifier(alg)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create verifier")
-	}
-
-	verifyBuf := pool.GetBytesBuffer()
-	defer pool.ReleaseBytesBuffer(verifyBuf)
-
-	verifyBuf.Write(protected)
-	verifyBuf.WriteByte('.')
-	if len(payload) == 0 && detachedPayload != nil {
-		payload = detachedPayload
-	}
-	verifyBuf.Write(payload)
-
-	decodedSignature, err := base64.Decode(signature)
-	if err != nil {
-		return nil, errors.Wrap(err, `failed to decode signature`)
-	}
-
 	hdr := NewHeaders()
 	decodedProtected, err := base64.Decode(protected)
 	if err != nil {
@@ -355,16 +564,30 @@ func verifyCompact(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, d
 		return nil, errors.Wrap(err, `failed to decode headers`)
 	}
 
-	if hdr.KeyID() != "" {
-		if jwkKey, ok := key.(jwk.Key); ok {
-			if jwkKey.KeyID() != hdr.KeyID() {
-				return nil, errors.New(`"kid" fields do not match`)
-			}
+	if err := checkCritical(hdr, nil, vctx.criticalHandlers); err != nil {
+		return nil, errors.Wrap(err, `invalid "crit" header`)
+	}
+
+	if len(payload) == 0 && vctx.detachedPayload != nil {
+		// jws.WithDetachedPayload() specifies the payload segment as is,
+		// while jws.WithDetachedContent() specifies the unencoded content
+		if vctx.detachedContent && getB64Value(hdr) {
+			payload = base64.Encode(vctx.detachedPayload)
+		} else {
+			payload = vctx.detachedPayload
 		}
 	}
 
-	if err := verifier.Verify(verifyBuf.Bytes(), decodedSignature, key); err != nil {
-		return nil, errors.Wrap(err, `failed to verify message`)
+	verifyBuf := pool.GetBytesBuffer()
+	defer pool.ReleaseBytesBuffer(verifyBuf)
+
+	verifyBuf.Write(protected)
+	verifyBuf.WriteByte('.')
+	verifyBuf.Write(payload)
+
+	decodedSignature, err := base64.Decode(signature)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to decode signature`)
 	}
 
 	var decodedPayload []byte
@@ -375,23 +598,60 @@ func verifyCompact(signed []byte, alg jwa.SignatureAlgorithm, key interface{}, d
 	if decodedPayload == nil {
 		v, err := base64.Decode(payload)
 		if err != nil {
-			return nil, errors.Wrap(err, `message verified, failed to decode payload`)
+			return nil, errors.Wrap(err, `failed to decode payload`)
 		}
 		decodedPayload = v
 	}
 
-	if dst != nil {
-		// Construct a new Message object
-		m := NewMessage()
-		m.SetPayload(decodedPayload)
-		sig := NewSignature()
-		sig.SetProtectedHeaders(hdr)
-		sig.SetSignature(decodedSignature)
-		m.AppendSignature(sig)
+	// Construct a new Message object
+	m := NewMessage()
+	m.SetPayload(decodedPayload)
+	sig := NewSignature()
+	sig.SetProtectedHeaders(hdr)
+	sig.SetSignature(decodedSignature)
+	m.AppendSignature(sig)
+
+	keys, err := vctx.candidates(sig, m)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New(`failed to verify message: no keys to verify with`)
+	}
+
+	var lastErr error
+	for _, key := range keys {
+		if hdr.KeyID() != "" {
+			if jwkKey, ok := key.Key.(jwk.Key); ok {
+				if jwkKey.KeyID() != hdr.KeyID() {
+					lastErr = errors.New(`"kid" fields do not match`)
+					continue
+				}
+			}
+		}
 
-		*dst = *m
+		verifier, err := NewVerifier(key.Algorithm)
+		if err != nil {
+			lastErr = errors.Wrap(err, `failed to create verifier`)
+			continue
+		}
+
+		if err := verifier.Verify(verifyBuf.Bytes(), decodedSignature, key.Key); err != nil {
+			lastErr = errors.Wrap(err, `failed to verify message`)
+			continue
+		}
+
+		if err := handleCritical(hdr, vctx.criticalHandlers); err != nil {
+			return nil, errors.Wrap(err, `message verified, failed to process "crit"`)
+		}
+
+		if vctx.dst != nil {
+			*vctx.dst = *m
+		}
+		return decodedPayload, nil
 	}
-	return decodedPayload, nil
+	return nil, lastErr
 }
 
 // This is an "optimized" ioutil.ReadAll(). It will attempt to read
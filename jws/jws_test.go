@@ -3,16 +3,27 @@ package jws_test
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"crypto"
 	"crypto/ecdsa"
 	"crypto/ed25519"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -345,6 +356,27 @@ func TestRoundtrip(t *testing.T) {
 			})
 		}
 	})
+	t.Run("EdDSA (Ed448)", func(t *testing.T) {
+		t.Parallel()
+		key, err := jwxtest.GenerateEd448Key()
+		if !assert.NoError(t, err, "ed448 key generated") {
+			return
+		}
+		pubkey := key.Public()
+		jwkKey, _ := jwk.New(pubkey)
+		keys := map[string]interface{}{
+			"Verify(ed448.Public())":  pubkey,
+			"Verify(*ed448.Public())": &pubkey,
+			"Verify(jwk.Key)":         jwkKey,
+		}
+		for _, alg := range []jwa.SignatureAlgorithm{jwa.EdDSA} {
+			alg := alg
+			t.Run(alg.String(), func(t *testing.T) {
+				t.Parallel()
+				testRoundtrip(t, payload, alg, key, keys)
+			})
+		}
+	})
 }
 
 func TestSignMulti2(t *testing.T) {
@@ -1276,6 +1308,65 @@ func TestRFC7797(t *testing.T) {
 		}
 	})
 
+	t.Run("Roundtrip (JSON)", func(t *testing.T) {
+		hdrs := jws.NewHeaders()
+		hdrs.Set("b64", false)
+
+		signer, err := jws.NewSigner(jwa.HS256)
+		if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+			return
+		}
+
+		for _, n := range []int{1, 2} {
+			options := make([]jws.Option, n)
+			for i := 0; i < n; i++ {
+				options[i] = jws.WithSigner(signer, key, nil, hdrs)
+			}
+			signed, err := jws.SignMulti(detached, options...)
+			if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+				return
+			}
+
+			// The payload should not be base64 encoded
+			var raw map[string]interface{}
+			if !assert.NoError(t, json.Unmarshal(signed, &raw), `json.Unmarshal should succeed`) {
+				return
+			}
+			if !assert.Equal(t, string(detached), raw["payload"], `payload should not be encoded`) {
+				return
+			}
+
+			m, err := jws.Parse(signed)
+			if !assert.NoError(t, err, `jws.Parse should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []string{"b64"}, m.Signatures()[0].ProtectedHeaders().Critical(), `"crit" should contain "b64"`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, jwa.HS256, key)
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, detached, verified, `payload should match`) {
+				return
+			}
+
+			// Re-serializing the parsed message should produce the same result
+			reserialized, err := json.Marshal(m)
+			if !assert.NoError(t, err, `json.Marshal should succeed`) {
+				return
+			}
+			verified, err = jws.Verify(reserialized, jwa.HS256, key)
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, detached, verified, `payload should match`) {
+				return
+			}
+		}
+	})
+
 	t.Run("Verify", func(t *testing.T) {
 		testcases := []struct {
 			Name          string
@@ -1347,3 +1438,894 @@ func TestRFC7797(t *testing.T) {
 		}
 	})
 }
+
+func TestDetachedPayload(t *testing.T) {
+	key, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = key.Set(jwk.KeyIDKey, "mykey")
+
+	pubkey, err := jwk.PublicKeyOf(key)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+
+	payload := []byte(`a large HTTP body. with periods.`)
+
+	for _, b64 := range []bool{true, false} {
+		b64 := b64
+		hdrs := jws.NewHeaders()
+		if !b64 {
+			_ = hdrs.Set("b64", false)
+		}
+
+		t.Run(fmt.Sprintf("Compact (b64=%t)", b64), func(t *testing.T) {
+			signed, err := jws.Sign(nil, jwa.RS256, key, jws.WithHeaders(hdrs), jws.WithDetachedContent(payload))
+			if !assert.NoError(t, err, `jws.Sign should succeed`) {
+				return
+			}
+
+			protected, encodedPayload, _, err := jws.SplitCompact(signed)
+			if !assert.NoError(t, err, `jws.SplitCompact should succeed`) {
+				return
+			}
+			if !assert.Empty(t, encodedPayload, `payload should be detached`) {
+				return
+			}
+			if !assert.True(t, bytes.HasPrefix(signed, append(protected, '.', '.')), `result should be in the form of "header..signature"`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, jwa.RS256, pubkey, jws.WithDetachedContent(payload))
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+
+			_, err = jws.Verify(signed, jwa.RS256, pubkey, jws.WithDetachedContent([]byte(`tampered`)))
+			if !assert.Error(t, err, `jws.Verify should fail`) {
+				return
+			}
+
+			// jws.WithDetachedPayload() takes the payload segment as is
+			segment := payload
+			if b64 {
+				segment = base64.Encode(payload)
+			}
+			verified, err = jws.Verify(signed, jwa.RS256, pubkey, jws.WithDetachedPayload(segment))
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+		})
+		t.Run(fmt.Sprintf("JSON (b64=%t)", b64), func(t *testing.T) {
+			signer, err := jws.NewSigner(jwa.RS256)
+			if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+				return
+			}
+
+			public := jws.NewHeaders()
+			_ = public.Set("x-public", "value")
+
+			signed, err := jws.SignMulti(nil,
+				jws.WithSigner(signer, key, public, hdrs),
+				jws.WithSigner(signer, key, nil, hdrs),
+				jws.WithDetachedContent(payload),
+			)
+			if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+				return
+			}
+
+			var raw map[string]interface{}
+			if !assert.NoError(t, json.Unmarshal(signed, &raw), `json.Unmarshal should succeed`) {
+				return
+			}
+			if _, ok := raw["payload"]; !assert.False(t, ok, `"payload" should be omitted`) {
+				return
+			}
+
+			verified, err := jws.Verify(signed, jwa.RS256, pubkey, jws.WithDetachedContent(payload))
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, payload, verified, `payload should match`) {
+				return
+			}
+
+			_, err = jws.Verify(signed, jwa.RS256, pubkey, jws.WithDetachedContent([]byte(`tampered`)))
+			if !assert.Error(t, err, `jws.Verify should fail`) {
+				return
+			}
+		})
+	}
+	t.Run("Both payload and detached payload", func(t *testing.T) {
+		_, err := jws.Sign(payload, jwa.RS256, key, jws.WithDetachedContent(payload))
+		if !assert.Error(t, err, `jws.Sign should fail`) {
+			return
+		}
+	})
+	t.Run("Both jws.WithDetachedPayload and jws.WithDetachedContent", func(t *testing.T) {
+		signed, err := jws.Sign(nil, jwa.RS256, key, jws.WithDetachedContent(payload))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		_, err = jws.Verify(signed, jwa.RS256, pubkey, jws.WithDetachedPayload(base64.Encode(payload)), jws.WithDetachedContent(payload))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Empty payload (JSON)", func(t *testing.T) {
+		// Detached payloads are represented by omitting "payload", so an
+		// empty base64 encoded payload is invalid
+		const signature = `dGVzdA`
+		b64protected := base64.EncodeToString([]byte(`{"alg":"RS256"}`))
+		_, err := jws.Parse([]byte(`{"payload":"","protected":"` + b64protected + `","signature":"` + signature + `"}`))
+		if !assert.Error(t, err, `jws.Parse should fail`) {
+			return
+		}
+
+		rawprotected := base64.EncodeToString([]byte(`{"alg":"RS256","b64":false,"crit":["b64"]}`))
+		m, err := jws.Parse([]byte(`{"payload":"","protected":"` + rawprotected + `","signature":"` + signature + `"}`))
+		if !assert.NoError(t, err, `jws.Parse should succeed when b64 = false`) {
+			return
+		}
+		if !assert.Empty(t, m.Payload(), `payload should be empty`) {
+			return
+		}
+
+		m, err = jws.Parse([]byte(`{"protected":"` + b64protected + `","signature":"` + signature + `"}`))
+		if !assert.NoError(t, err, `jws.Parse should succeed for a detached payload`) {
+			return
+		}
+		if !assert.Nil(t, m.Payload(), `payload should be nil`) {
+			return
+		}
+	})
+}
+
+func TestCritical(t *testing.T) {
+	key := []byte("abracadabra")
+
+	sign := func(t *testing.T, crit []string, extra map[string]interface{}) []byte {
+		t.Helper()
+		hdrs := jws.NewHeaders()
+		if crit != nil {
+			_ = hdrs.Set(jws.CriticalKey, crit)
+		}
+		for k, v := range extra {
+			_ = hdrs.Set(k, v)
+		}
+		signed, err := jws.Sign([]byte("Lorem ipsum"), jwa.HS256, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("Unknown extension", func(t *testing.T) {
+		signed := sign(t, []string{"x-ext"}, map[string]interface{}{"x-ext": "foo"})
+		_, err := jws.Verify(signed, jwa.HS256, key)
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Extension not in protected header", func(t *testing.T) {
+		signed := sign(t, []string{"x-ext"}, nil)
+		_, err := jws.Verify(signed, jwa.HS256, key, jws.WithCriticalHandler("x-ext", jws.CriticalHandlerFunc(func(string, jws.Headers) error {
+			return nil
+		})))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Registered header in crit", func(t *testing.T) {
+		signed := sign(t, []string{jws.KeyIDKey}, map[string]interface{}{jws.KeyIDKey: "foo"})
+		_, err := jws.Verify(signed, jwa.HS256, key)
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Empty crit", func(t *testing.T) {
+		signed := sign(t, []string{}, nil)
+		_, err := jws.Verify(signed, jwa.HS256, key)
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Per-call handler", func(t *testing.T) {
+		signed := sign(t, []string{"x-ext"}, map[string]interface{}{"x-ext": "foo"})
+
+		var called bool
+		h := jws.CriticalHandlerFunc(func(name string, hdrs jws.Headers) error {
+			called = true
+			v, _ := hdrs.Get(name)
+			if v != "foo" {
+				return errors.Errorf(`unexpected value %v`, v)
+			}
+			return nil
+		})
+
+		payload, err := jws.Verify(signed, jwa.HS256, key, jws.WithCriticalHandler("x-ext", h))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.True(t, called, `handler should be called`) {
+			return
+		}
+		if !assert.Equal(t, []byte("Lorem ipsum"), payload, `payload should match`) {
+			return
+		}
+
+		// handler errors make the verification fail
+		_, err = jws.Verify(signed, jwa.HS256, key, jws.WithCriticalHandler("x-ext", jws.CriticalHandlerFunc(func(string, jws.Headers) error {
+			return errors.New(`rejected`)
+		})))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Global handler", func(t *testing.T) {
+		signed := sign(t, []string{"x-global"}, map[string]interface{}{"x-global": true})
+
+		var called bool
+		jws.RegisterCriticalHandler("x-global", jws.CriticalHandlerFunc(func(string, jws.Headers) error {
+			called = true
+			return nil
+		}))
+		defer jws.RegisterCriticalHandler("x-global", nil)
+
+		_, err := jws.Verify(signed, jwa.HS256, key)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.True(t, called, `handler should be called`) {
+			return
+		}
+
+		jws.RegisterCriticalHandler("x-global", nil)
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if !assert.Error(t, err, `jws.Verify should fail after unregistering the handler`) {
+			return
+		}
+	})
+	t.Run("JSON serialization", func(t *testing.T) {
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.CriticalKey, []string{"x-ext"})
+		_ = hdrs.Set("x-ext", "foo")
+
+		signer, err := jws.NewSigner(jwa.HS256)
+		if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+			return
+		}
+		signed, err := jws.SignMulti([]byte("Lorem ipsum"), jws.WithSigner(signer, key, nil, hdrs))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, key)
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.HS256, key, jws.WithCriticalHandler("x-ext", jws.CriticalHandlerFunc(func(string, jws.Headers) error {
+			return nil
+		})))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+	})
+	t.Run("b64 is understood", func(t *testing.T) {
+		signed := sign(t, nil, map[string]interface{}{"b64": false})
+		_, err := jws.Verify(signed, jwa.HS256, key)
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+	})
+}
+
+func TestKeyProvider(t *testing.T) {
+	const payload = "Lorem ipsum"
+
+	rsakey, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+	hmackey := []byte("abracadabra")
+
+	keys := map[string]jws.KeyCandidate{
+		"rsa":  {Algorithm: jwa.RS256, Key: &rsakey.PublicKey},
+		"hmac": {Algorithm: jwa.HS256, Key: hmackey},
+	}
+
+	var seen []string
+	provider := jws.KeyProviderFunc(func(_ context.Context, sig *jws.Signature, m *jws.Message) ([]jws.KeyCandidate, error) {
+		kid := sig.ProtectedHeaders().KeyID()
+		seen = append(seen, kid)
+		if kid == "hmac" {
+			// pretend that we no longer trust this key
+			return nil, nil
+		}
+		key, ok := keys[kid]
+		if !ok {
+			return nil, errors.Errorf(`unknown key %q`, kid)
+		}
+		return []jws.KeyCandidate{key}, nil
+	})
+
+	makeSigner := func(t *testing.T, alg jwa.SignatureAlgorithm, key interface{}, kid string) jws.Option {
+		t.Helper()
+		signer, err := jws.NewSigner(alg)
+		if !assert.NoError(t, err, `jws.NewSigner should succeed`) {
+			t.FailNow()
+		}
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, kid)
+		return jws.WithSigner(signer, key, nil, hdrs)
+	}
+
+	t.Run("Compact", func(t *testing.T) {
+		seen = nil
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, "rsa")
+		signed, err := jws.Sign([]byte(payload), jwa.RS256, rsakey, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte(payload), verified, `payload should match`) {
+			return
+		}
+		if !assert.Equal(t, []string{"rsa"}, seen, `provider should be called once`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, jwa.RS256, &rsakey.PublicKey, jws.WithKeyProvider(provider))
+		if !assert.Error(t, err, `jws.Verify with both a key and a key provider should fail`) {
+			return
+		}
+	})
+	t.Run("JSON", func(t *testing.T) {
+		seen = nil
+		signed, err := jws.SignMulti([]byte(payload),
+			makeSigner(t, jwa.HS256, hmackey, "hmac"),
+			makeSigner(t, jwa.RS256, rsakey, "rsa"),
+		)
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+
+		verified, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte(payload), verified, `payload should match`) {
+			return
+		}
+		if !assert.Equal(t, []string{"hmac", "rsa"}, seen, `provider should be called for each signature`) {
+			return
+		}
+	})
+	t.Run("Unknown key", func(t *testing.T) {
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.KeyIDKey, "unknown")
+		signed, err := jws.Sign([]byte(payload), jwa.RS256, rsakey, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		_, err = jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Candidates with unsupported algorithms are skipped", func(t *testing.T) {
+		provider := jws.KeyProviderFunc(func(context.Context, *jws.Signature, *jws.Message) ([]jws.KeyCandidate, error) {
+			return []jws.KeyCandidate{
+				{Algorithm: jwa.SignatureAlgorithm("unsupported"), Key: &rsakey.PublicKey},
+				keys["rsa"],
+			}, nil
+		})
+
+		signed, err := jws.Sign([]byte(payload), jwa.RS256, rsakey)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		verified, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.NoError(t, err, `jws.Verify should succeed (compact)`) {
+			return
+		}
+		if !assert.Equal(t, []byte(payload), verified, `payload should match`) {
+			return
+		}
+
+		signed, err = jws.SignMulti([]byte(payload), makeSigner(t, jwa.RS256, rsakey, "rsa"))
+		if !assert.NoError(t, err, `jws.SignMulti should succeed`) {
+			return
+		}
+		verified, err = jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.NoError(t, err, `jws.Verify should succeed (JSON)`) {
+			return
+		}
+		if !assert.Equal(t, []byte(payload), verified, `payload should match`) {
+			return
+		}
+	})
+}
+
+func TestURLKeyProvider(t *testing.T) {
+	const payload = "Lorem ipsum"
+
+	rsakey, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = rsakey.Set(jwk.KeyIDKey, "partner")
+
+	eckey, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "partner"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &eckey.PublicKey, eckey)
+	if !assert.NoError(t, err, `x509.CreateCertificate should succeed`) {
+		return
+	}
+
+	pubkey, err := jwk.PublicKeyOf(rsakey)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+	set := jwk.NewSet()
+	set.Add(pubkey)
+	jwks, err := json.Marshal(set)
+	if !assert.NoError(t, err, `json.Marshal should succeed`) {
+		return
+	}
+
+	var muRequests sync.Mutex
+	requests := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		muRequests.Lock()
+		requests[r.URL.Path]++
+		muRequests.Unlock()
+
+		switch r.URL.Path {
+		case "/jwks.json", "/other.json":
+			w.Header().Set(`Content-Type`, `application/json`)
+			_, _ = w.Write(jwks)
+		case "/cert.pem":
+			_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	allowlist := jwk.NewMapAllowlist().
+		Add(srv.URL + "/jwks.json").
+		Add(srv.URL + "/cert.pem")
+	provider := jws.NewURLKeyProvider(ctx, allowlist, jws.WithHTTPClient(srv.Client()))
+
+	signWith := func(t *testing.T, alg jwa.SignatureAlgorithm, key interface{}, name, value string) []byte {
+		t.Helper()
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(name, value)
+		signed, err := jws.Sign([]byte(payload), alg, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("jku", func(t *testing.T) {
+		signed := signWith(t, jwa.RS256, rsakey, jws.JWKSetURLKey, srv.URL+"/jwks.json")
+		for i := 0; i < 3; i++ {
+			verified, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []byte(payload), verified, `payload should match`) {
+				return
+			}
+		}
+
+		muRequests.Lock()
+		defer muRequests.Unlock()
+		if !assert.Equal(t, 1, requests["/jwks.json"], `JWKS should be cached`) {
+			return
+		}
+	})
+	t.Run("x5u", func(t *testing.T) {
+		signed := signWith(t, jwa.ES256, eckey, jws.X509URLKey, srv.URL+"/cert.pem")
+		for i := 0; i < 3; i++ {
+			verified, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+			if !assert.NoError(t, err, `jws.Verify should succeed`) {
+				return
+			}
+			if !assert.Equal(t, []byte(payload), verified, `payload should match`) {
+				return
+			}
+		}
+
+		muRequests.Lock()
+		defer muRequests.Unlock()
+		if !assert.Equal(t, 1, requests["/cert.pem"], `certificate should be cached`) {
+			return
+		}
+	})
+	t.Run("URL not in allowlist", func(t *testing.T) {
+		signed := signWith(t, jwa.RS256, rsakey, jws.JWKSetURLKey, srv.URL+"/other.json")
+		_, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+
+		muRequests.Lock()
+		defer muRequests.Unlock()
+		if !assert.Equal(t, 0, requests["/other.json"], `URL should not be fetched`) {
+			return
+		}
+	})
+	t.Run("Redirect to URL not in allowlist", func(t *testing.T) {
+		var redirected int64
+		target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt64(&redirected, 1)
+			srv.Config.Handler.ServeHTTP(w, r)
+		}))
+		defer target.Close()
+
+		redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, target.URL+r.URL.Path, http.StatusFound)
+		}))
+		defer redirector.Close()
+
+		allowlist := jwk.NewMapAllowlist().
+			Add(redirector.URL + "/jwks.json").
+			Add(redirector.URL + "/cert.pem")
+		provider := jws.NewURLKeyProvider(ctx, allowlist)
+
+		for _, signed := range [][]byte{
+			signWith(t, jwa.RS256, rsakey, jws.JWKSetURLKey, redirector.URL+"/jwks.json"),
+			signWith(t, jwa.ES256, eckey, jws.X509URLKey, redirector.URL+"/cert.pem"),
+		} {
+			_, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+			if !assert.Error(t, err, `jws.Verify should fail`) {
+				return
+			}
+		}
+		if !assert.Equal(t, int64(0), atomic.LoadInt64(&redirected), `redirect target should not be requested`) {
+			return
+		}
+	})
+	t.Run("Number of URLs is limited", func(t *testing.T) {
+		// The allowlist allows an unlimited number of URLs
+		allowlist := jwk.AllowlistFunc(func(u string) bool {
+			return strings.HasPrefix(u, srv.URL+"/")
+		})
+		ar := jwk.NewAutoRefresh(ctx)
+		provider := jws.NewURLKeyProvider(ctx, allowlist,
+			jws.WithHTTPClient(srv.Client()),
+			jws.WithAutoRefresh(ar),
+			jws.WithMaxCachedURLs(2),
+		)
+
+		for i := 0; i < 5; i++ {
+			for _, signed := range [][]byte{
+				signWith(t, jwa.RS256, rsakey, jws.JWKSetURLKey, fmt.Sprintf("%s/jwks.json?%d", srv.URL, i)),
+				signWith(t, jwa.ES256, eckey, jws.X509URLKey, fmt.Sprintf("%s/cert.pem?%d", srv.URL, i)),
+			} {
+				_, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+				if !assert.NoError(t, err, `jws.Verify should succeed`) {
+					return
+				}
+			}
+		}
+
+		var urls []string
+		for snapshot := range ar.Snapshot() {
+			urls = append(urls, snapshot.URL)
+		}
+		sort.Strings(urls)
+		if !assert.Equal(t, []string{srv.URL + "/jwks.json?3", srv.URL + "/jwks.json?4"}, urls, `only the most recently used URLs should be registered`) {
+			return
+		}
+	})
+	t.Run("Symmetric algorithms are rejected", func(t *testing.T) {
+		signed := signWith(t, jwa.HS256, []byte("abracadabra"), jws.JWKSetURLKey, srv.URL+"/jwks.json")
+		_, err := jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Wrong key", func(t *testing.T) {
+		other, err := jwxtest.GenerateRsaJwk()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+			return
+		}
+		_ = other.Set(jwk.KeyIDKey, "partner")
+
+		signed := signWith(t, jwa.RS256, other, jws.JWKSetURLKey, srv.URL+"/jwks.json")
+		_, err = jws.Verify(signed, "", nil, jws.WithKeyProvider(provider))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+}
+
+func TestVerifyCertificateChain(t *testing.T) {
+	const payload = "Lorem ipsum"
+	const dnsName = "example.com"
+
+	key, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+	if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+		return
+	}
+
+	root, chain, err := jwxtest.GenerateCertificateChain(&key.PublicKey, dnsName)
+	if !assert.NoError(t, err, `jwxtest.GenerateCertificateChain should succeed`) {
+		return
+	}
+
+	encoded := make([]string, len(chain))
+	for i, cert := range chain {
+		encoded[i] = base64.EncodeToStringStd(cert.Raw)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	opts := x509.VerifyOptions{
+		DNSName: dnsName,
+	}
+
+	sign := func(t *testing.T, signingKey interface{}, extra map[string]interface{}) []byte {
+		t.Helper()
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.X509CertChainKey, encoded)
+		for k, v := range extra {
+			_ = hdrs.Set(k, v)
+		}
+		signed, err := jws.Sign([]byte(payload), jwa.ES256, signingKey, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("Valid chain", func(t *testing.T) {
+		signed := sign(t, key, nil)
+		verified, err := jws.Verify(signed, jwa.ES256, nil, jws.WithVerifyCertificateChain(roots, opts))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+		if !assert.Equal(t, []byte(payload), verified, `payload should match`) {
+			return
+		}
+	})
+	t.Run("Thumbprint", func(t *testing.T) {
+		sum := sha256.Sum256(chain[0].Raw)
+		signed := sign(t, key, map[string]interface{}{jws.X509CertThumbprintS256Key: base64.EncodeToString(sum[:])})
+		_, err := jws.Verify(signed, "", nil, jws.WithVerifyCertificateChain(roots, opts))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		sum = sha256.Sum256(chain[1].Raw)
+		signed = sign(t, key, map[string]interface{}{jws.X509CertThumbprintS256Key: base64.EncodeToString(sum[:])})
+		_, err = jws.Verify(signed, "", nil, jws.WithVerifyCertificateChain(roots, opts))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Untrusted root", func(t *testing.T) {
+		signed := sign(t, key, nil)
+		_, err := jws.Verify(signed, jwa.ES256, nil, jws.WithVerifyCertificateChain(x509.NewCertPool(), opts))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Roots are required", func(t *testing.T) {
+		signed := sign(t, key, nil)
+		o := opts
+		o.Roots = roots
+		_, err := jws.Verify(signed, jwa.ES256, nil, jws.WithVerifyCertificateChain(nil, o))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Signed by a different key", func(t *testing.T) {
+		other, err := jwxtest.GenerateEcdsaKey(jwa.P256)
+		if !assert.NoError(t, err, `jwxtest.GenerateEcdsaKey should succeed`) {
+			return
+		}
+		signed := sign(t, other, nil)
+		_, err = jws.Verify(signed, jwa.ES256, nil, jws.WithVerifyCertificateChain(roots, opts))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Algorithm mismatch", func(t *testing.T) {
+		signed := sign(t, key, nil)
+		_, err := jws.Verify(signed, jwa.ES384, nil, jws.WithVerifyCertificateChain(roots, opts))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Extended key usages", func(t *testing.T) {
+		root, chain, err := jwxtest.GenerateCertificateChain(&key.PublicKey, dnsName, x509.ExtKeyUsageCodeSigning)
+		if !assert.NoError(t, err, `jwxtest.GenerateCertificateChain should succeed`) {
+			return
+		}
+
+		encoded := make([]string, len(chain))
+		for i, cert := range chain {
+			encoded[i] = base64.EncodeToStringStd(cert.Raw)
+		}
+		hdrs := jws.NewHeaders()
+		_ = hdrs.Set(jws.X509CertChainKey, encoded)
+		signed, err := jws.Sign([]byte(payload), jwa.ES256, key, jws.WithHeaders(hdrs))
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+
+		roots := x509.NewCertPool()
+		roots.AddCert(root)
+
+		// Any extended key usage is accepted by default
+		_, err = jws.Verify(signed, jwa.ES256, nil, jws.WithVerifyCertificateChain(roots, opts))
+		if !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+
+		o := opts
+		o.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		_, err = jws.Verify(signed, jwa.ES256, nil, jws.WithVerifyCertificateChain(roots, o))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+	t.Run("Key must not be specified", func(t *testing.T) {
+		signed := sign(t, key, nil)
+		_, err := jws.Verify(signed, jwa.ES256, &key.PublicKey, jws.WithVerifyCertificateChain(roots, opts))
+		if !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+	})
+}
+
+func TestStrictKeyUsage(t *testing.T) {
+	newKey := func(t *testing.T, fields map[string]interface{}) jwk.Key {
+		key, err := jwk.Generate(jwa.OKP, jwk.WithKeyID(`my-key`))
+		if !assert.NoError(t, err, `jwk.Generate should succeed`) {
+			return nil
+		}
+		for k, v := range fields {
+			if !assert.NoError(t, key.Set(k, v), `key.Set should succeed`) {
+				return nil
+			}
+		}
+		return key
+	}
+
+	t.Run("Sign", func(t *testing.T) {
+		testcases := []struct {
+			Name   string
+			Fields map[string]interface{}
+			Error  string
+		}{
+			{
+				Name: `No restrictions`,
+			},
+			{
+				Name:   `Permitted`,
+				Fields: map[string]interface{}{jwk.KeyUsageKey: jwk.ForSignature, jwk.KeyOpsKey: jwk.KeyOperationList{jwk.KeyOpSign}, jwk.AlgorithmKey: jwa.EdDSA},
+			},
+			{
+				Name:   `key_ops`,
+				Fields: map[string]interface{}{jwk.KeyOpsKey: jwk.KeyOperationList{jwk.KeyOpVerify}},
+				Error:  `key with key ID "my-key" may not be used to sign: "key_ops" is ["verify"]`,
+			},
+			{
+				Name:   `use`,
+				Fields: map[string]interface{}{jwk.KeyUsageKey: jwk.ForEncryption},
+				Error:  `key with key ID "my-key" may not be used to sign: "use" is "enc"`,
+			},
+			{
+				Name:   `alg`,
+				Fields: map[string]interface{}{jwk.AlgorithmKey: jwa.ES256},
+				Error:  `key with key ID "my-key" may not be used to sign using "EdDSA": "alg" is "ES256"`,
+			},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				key := newKey(t, tc.Fields)
+				if key == nil {
+					return
+				}
+
+				_, err := jws.Sign([]byte(`payload`), jwa.EdDSA, key, jws.WithStrictKeyUsage(true))
+				if tc.Error == "" {
+					assert.NoError(t, err, `jws.Sign should succeed`)
+					return
+				}
+				if !assert.Error(t, err, `jws.Sign should fail`) {
+					return
+				}
+				if !assert.Equal(t, tc.Error, err.Error(), `error message should match`) {
+					return
+				}
+				if _, err := jws.Sign([]byte(`payload`), jwa.EdDSA, key); !assert.NoError(t, err, `jws.Sign should succeed when not strict`) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Verify", func(t *testing.T) {
+		key := newKey(t, map[string]interface{}{jwk.KeyOpsKey: jwk.KeyOperationList{jwk.KeyOpSign}})
+		if key == nil {
+			return
+		}
+		signed, err := jws.Sign([]byte(`payload`), jwa.EdDSA, key)
+		if !assert.NoError(t, err, `jws.Sign should succeed`) {
+			return
+		}
+		pubkey, err := jwk.PublicKeyOf(key)
+		if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+			return
+		}
+
+		if _, err := jws.Verify(signed, jwa.EdDSA, pubkey); !assert.NoError(t, err, `jws.Verify should succeed when not strict`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.EdDSA, pubkey, jws.WithStrictKeyUsage(true)); !assert.Error(t, err, `jws.Verify should fail`) {
+			return
+		}
+		if !assert.NoError(t, pubkey.Set(jwk.KeyOpsKey, jwk.KeyOperationList{jwk.KeyOpVerify}), `pubkey.Set should succeed`) {
+			return
+		}
+		if _, err := jws.Verify(signed, jwa.EdDSA, pubkey, jws.WithStrictKeyUsage(true)); !assert.NoError(t, err, `jws.Verify should succeed`) {
+			return
+		}
+	})
+	t.Run("Global setting", func(t *testing.T) {
+		jwk.Settings(jwk.WithStrictKeyUsage(true))
+		defer jwk.Settings(jwk.WithStrictKeyUsage(false))
+
+		key := newKey(t, map[string]interface{}{jwk.KeyUsageKey: jwk.ForEncryption})
+		if key == nil {
+			return
+		}
+		if _, err := jws.Sign([]byte(`payload`), jwa.EdDSA, key); !assert.Error(t, err, `jws.Sign should fail`) {
+			return
+		}
+		if _, err := jws.Sign([]byte(`payload`), jwa.EdDSA, key, jws.WithStrictKeyUsage(false)); !assert.NoError(t, err, `jws.Sign should succeed when overridden`) {
+			return
+		}
+
+		var raw ed25519.PrivateKey
+		if !assert.NoError(t, key.Raw(&raw), `key.Raw should succeed`) {
+			return
+		}
+		if _, err := jws.Sign([]byte(`payload`), jwa.EdDSA, raw); !assert.NoError(t, err, `jws.Sign should succeed with raw keys`) {
+			return
+		}
+	})
+}
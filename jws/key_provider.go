@@ -0,0 +1,42 @@
+package jws
+
+import (
+	"context"
+
+	"github.com/lestrrat-go/jwx/jwa"
+)
+
+// KeyCandidate is a key that may be used to verify a signature, along
+// with the algorithm that it should be used with.
+type KeyCandidate struct {
+	Algorithm jwa.SignatureAlgorithm
+	// Key may be a "raw" key (e.g. *rsa.PublicKey) or a jwk.Key
+	Key interface{}
+}
+
+// KeyProvider is used by `jws.Verify()` to look up the keys that may
+// be used to verify a signature, instead of using a single fixed key.
+//
+// FetchKeys is called once for each signature in the message, with the
+// signature being verified and the message that contains it. The
+// message's payload may not be trusted until verification succeeds.
+// The returned candidates are tried in order, until one of them
+// successfully verifies the signature.
+//
+// A KeyProvider can be used to implement lookups that depend on the
+// contents of the message, such as per-tenant keys, routing based on
+// the issuer, or fetching keys from a `jwk.AutoRefresh` object.
+//
+// Note that the headers of the message are controlled by whoever
+// created it. The KeyProvider is responsible for making sure that the
+// returned algorithms are acceptable for the returned keys.
+type KeyProvider interface {
+	FetchKeys(context.Context, *Signature, *Message) ([]KeyCandidate, error)
+}
+
+// KeyProviderFunc is a KeyProvider represented by a function
+type KeyProviderFunc func(context.Context, *Signature, *Message) ([]KeyCandidate, error)
+
+func (f KeyProviderFunc) FetchKeys(ctx context.Context, sig *Signature, m *Message) ([]KeyCandidate, error) {
+	return f(ctx, sig, m)
+}
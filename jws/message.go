@@ -7,6 +7,7 @@ import (
 	"github.com/lestrrat-go/jwx/internal/base64"
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/internal/pool"
+	"github.com/lestrrat-go/jwx/jwa"
 	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/pkg/errors"
 )
@@ -49,6 +50,12 @@ func (s *Signature) SetSignature(v []byte) *Signature {
 // The second return value s the full three-segment signature
 // (e.g. "eyXXXX.XXXXX.XXXX")
 func (s *Signature) Sign(payload []byte, signer Signer, key interface{}) ([]byte, []byte, error) {
+	return s.sign(payload, signer, key, false)
+}
+
+// sign is the implementation of Sign. If `detached` is true, the payload
+// is omitted from the returned compact serialization (e.g. "eyXXXX..XXXX")
+func (s *Signature) sign(payload []byte, signer Signer, key interface{}, detached bool) ([]byte, []byte, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -57,44 +64,39 @@ func (s *Signature) Sign(payload []byte, signer Signer, key interface{}) ([]byte
 		return nil, nil, errors.Wrap(err, `failed to merge headers`)
 	}
 
-	if err := hdrs.Set(AlgorithmKey, signer.Algorithm()); err != nil {
-		return nil, nil, errors.Wrap(err, `failed to set "alg"`)
+	if err := setSigningHeaders(hdrs, signer.Algorithm(), key); err != nil {
+		return nil, nil, err
 	}
 
-	// If the key is a jwk.Key instance, obtain the raw key
-	if jwkKey, ok := key.(jwk.Key); ok {
-		// If we have a key ID specified by this jwk.Key, use that in the header
-		if kid := jwkKey.KeyID(); kid != "" {
-			if err := hdrs.Set(jwk.KeyIDKey, kid); err != nil {
-				return nil, nil, errors.Wrap(err, `set key ID from jwk.Key`)
-			}
-		}
-	}
 	hdrbuf, err := json.Marshal(hdrs)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, `failed to marshal headers`)
 	}
 
-	buf := pool.GetBytesBuffer()
-	defer pool.ReleaseBytesBuffer(buf)
-
-	buf.WriteString(base64.EncodeToString(hdrbuf))
-	buf.WriteByte('.')
-	if getB64Value(hdrs) {
-		buf.WriteString(base64.EncodeToString(payload))
-	} else {
-		if bytes.ContainsRune(payload, '.') {
-			return nil, nil, errors.New(`payload must not contain a "." when b64 = false`)
-		}
-		buf.Write(payload)
+	encodedHeader := base64.EncodeToString(hdrbuf)
+	b64 := getB64Value(hdrs)
+	if !b64 && !detached && bytes.ContainsRune(payload, '.') {
+		return nil, nil, errors.New(`payload must not contain a "." when b64 = false`)
 	}
 
-	signature, err := signer.Sign(buf.Bytes(), key)
+	signature, err := signer.Sign(signingInput(encodedHeader, payload, b64), key)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, `failed to sign payload`)
 	}
 	s.signature = signature
 
+	buf := pool.GetBytesBuffer()
+	defer pool.ReleaseBytesBuffer(buf)
+
+	buf.WriteString(encodedHeader)
+	buf.WriteByte('.')
+	if !detached {
+		if b64 {
+			buf.WriteString(base64.EncodeToString(payload))
+		} else {
+			buf.Write(payload)
+		}
+	}
 	buf.WriteByte('.')
 	buf.WriteString(base64.EncodeToString(signature))
 	ret := make([]byte, buf.Len())
@@ -103,6 +105,58 @@ func (s *Signature) Sign(payload []byte, signer Signer, key interface{}) ([]byte
 	return signature, ret, nil
 }
 
+// setSigningHeaders populates the headers that are required to sign
+// a payload: "alg", "kid" if the key is a jwk.Key with a key ID, and
+// "crit" if the payload is not base64 encoded (RFC7797)
+func setSigningHeaders(hdrs Headers, alg jwa.SignatureAlgorithm, key interface{}) error {
+	if err := hdrs.Set(AlgorithmKey, alg); err != nil {
+		return errors.Wrap(err, `failed to set "alg"`)
+	}
+
+	// If we have a key ID specified by this jwk.Key, use that in the header
+	if jwkKey, ok := key.(jwk.Key); ok {
+		if kid := jwkKey.KeyID(); kid != "" {
+			if err := hdrs.Set(jwk.KeyIDKey, kid); err != nil {
+				return errors.Wrap(err, `set key ID from jwk.Key`)
+			}
+		}
+	}
+
+	// RFC7797 requires that "b64" be listed in "crit" when it's used
+	if !getB64Value(hdrs) {
+		crit := hdrs.Critical()
+		var found bool
+		for _, v := range crit {
+			if v == "b64" {
+				found = true
+				break
+			}
+		}
+		if !found {
+			if err := hdrs.Set(CriticalKey, append(append([]string(nil), crit...), "b64")); err != nil {
+				return errors.Wrap(err, `failed to set "crit"`)
+			}
+		}
+	}
+	return nil
+}
+
+// signingInput creates the JWS Signing Input from the base64 encoded
+// protected header and the payload
+func signingInput(encodedHeader string, payload []byte, b64 bool) []byte {
+	var encodedPayload []byte
+	if b64 {
+		encodedPayload = base64.Encode(payload)
+	} else {
+		encodedPayload = payload
+	}
+
+	ret := make([]byte, 0, len(encodedHeader)+1+len(encodedPayload))
+	ret = append(ret, encodedHeader...)
+	ret = append(ret, '.')
+	return append(ret, encodedPayload...)
+}
+
 func NewMessage() *Message {
 	return &Message{}
 }
@@ -156,7 +210,7 @@ func (m Message) LookupSignature(kid string) []*Signature {
 }
 
 type messageProxy struct {
-	Payload    string            `json:"payload"` // base64 URL encoded
+	Payload    *string           `json:"payload,omitempty"` // base64 URL encoded
 	Signatures []*signatureProxy `json:"signatures,omitempty"`
 
 	// These are only available when we're using flattened JSON
@@ -238,15 +292,23 @@ func (m *Message) UnmarshalJSON(buf []byte) error {
 		m.signatures = append(m.signatures, &sig)
 	}
 
-	if !b64 {
-		m.payload = []byte(proxy.Payload)
-	} else {
-		// Everything in the proxy is base64 encoded, except for signatures.header
-		if len(proxy.Payload) == 0 {
+	switch {
+	case proxy.Payload == nil:
+		// The payload may be detached (RFC7515 Appendix F), in which
+		// case it must be provided separately upon verification
+		if len(m.signatures) == 0 {
 			return errors.New(`"payload" must be non-empty`)
 		}
-
-		buf, err := base64.DecodeString(proxy.Payload)
+	case !b64:
+		m.payload = []byte(*proxy.Payload)
+	case len(*proxy.Payload) == 0:
+		// An empty unencoded payload is valid, but an empty base64 encoded
+		// payload is not. Detached payloads are represented by omitting
+		// the "payload" member instead
+		return errors.New(`"payload" must be non-empty`)
+	default:
+		// Everything in the proxy is base64 encoded, except for signatures.header
+		buf, err := base64.DecodeString(*proxy.Payload)
 		if err != nil {
 			return errors.Wrap(err, `failed to decode payload`)
 		}
@@ -264,6 +326,39 @@ func (m Message) MarshalJSON() ([]byte, error) {
 	return m.marshalFull()
 }
 
+// payloadB64 returns the value of the "b64" header field, which is
+// required to be the same for all signatures
+func (m Message) payloadB64() bool {
+	for _, sig := range m.signatures {
+		if sig.protected != nil {
+			return getB64Value(sig.protected)
+		}
+	}
+	return true
+}
+
+// writePayload writes the "payload" member. The payload is written
+// without base64 encoding if "b64" is false (RFC7797)
+func (m Message) writePayload(buf *bytes.Buffer) error {
+	buf.WriteString(`"payload":`)
+	if m.payloadB64() {
+		buf.WriteRune('"')
+		buf.WriteString(base64.EncodeToString(m.payload))
+		buf.WriteRune('"')
+		return nil
+	}
+
+	payload, err := json.Marshal(string(m.payload))
+	if err != nil {
+		return errors.Wrap(err, `failed to marshal "payload"`)
+	}
+	buf.Write(payload)
+	return nil
+}
+
+// marshalFlattened and marshalFull omit the "payload" member if the
+// payload is nil, which is how messages with detached payloads are
+// represented
 func (m Message) marshalFlattened() ([]byte, error) {
 	buf := pool.GetBytesBuffer()
 	defer pool.ReleaseBytesBuffer(buf)
@@ -283,24 +378,34 @@ func (m Message) marshalFlattened() ([]byte, error) {
 		wrote = true
 	}
 
-	if wrote {
-		buf.WriteRune(',')
+	if m.payload != nil {
+		if wrote {
+			buf.WriteRune(',')
+		}
+		if err := m.writePayload(buf); err != nil {
+			return nil, err
+		}
+		wrote = true
 	}
-	buf.WriteString(`"payload":"`)
-	buf.WriteString(base64.EncodeToString(m.payload))
-	buf.WriteRune('"')
 
 	if protected := sig.protected; protected != nil {
 		protectedbuf, err := protected.MarshalJSON()
 		if err != nil {
 			return nil, errors.Wrap(err, `failed to marshal "protected" (flattened format)`)
 		}
-		buf.WriteString(`,"protected":"`)
+		if wrote {
+			buf.WriteRune(',')
+		}
+		buf.WriteString(`"protected":"`)
 		buf.WriteString(base64.EncodeToString(protectedbuf))
 		buf.WriteRune('"')
+		wrote = true
 	}
 
-	buf.WriteString(`,"signature":"`)
+	if wrote {
+		buf.WriteRune(',')
+	}
+	buf.WriteString(`"signature":"`)
 	buf.WriteString(base64.EncodeToString(sig.signature))
 	buf.WriteRune('"')
 	buf.WriteRune('}')
@@ -314,9 +419,14 @@ func (m Message) marshalFull() ([]byte, error) {
 	buf := pool.GetBytesBuffer()
 	defer pool.ReleaseBytesBuffer(buf)
 
-	buf.WriteString(`{"payload":"`)
-	buf.WriteString(base64.EncodeToString(m.payload))
-	buf.WriteString(`","signatures":[`)
+	buf.WriteRune('{')
+	if m.payload != nil {
+		if err := m.writePayload(buf); err != nil {
+			return nil, err
+		}
+		buf.WriteRune(',')
+	}
+	buf.WriteString(`"signatures":[`)
 	for i, sig := range m.signatures {
 		if i > 0 {
 			buf.WriteRune(',')
@@ -1,6 +1,11 @@
 package jws
 
 import (
+	"context"
+	"crypto/x509"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/option"
 )
 
@@ -8,6 +13,7 @@ type Option = option.Interface
 
 type identPayloadSigner struct{}
 type identDetachedPayload struct{}
+type identDetachedContent struct{}
 type identHeaders struct{}
 type identMessage struct{}
 
@@ -55,9 +61,167 @@ func WithMessage(m *Message) VerifyOption {
 	return &verifyOption{option.New(identMessage{}, m)}
 }
 
+// SignVerifyOption describes an option that can be passed to both
+// the jws.Sign and jws.Verify functions
+type SignVerifyOption interface {
+	SignOption
+	VerifyOption
+}
+
+type signVerifyOption struct {
+	Option
+}
+
+func (*signVerifyOption) signOption()   {}
+func (*signVerifyOption) verifyOption() {}
+
 // WithDetachedPayload can be used to verify a JWS message with a
 // detached payload. If you have to verify using this option, you should
 // know exactly how and why this works.
+//
+// For messages in compact serialization format, `v` is used as the
+// payload segment of the message as is, i.e. it must already be base64
+// encoded unless the "b64" header field is false. For messages in JSON
+// serialization format, `v` is the content before it is encoded.
+//
+// Use `jws.WithDetachedContent()` to always specify the unencoded content.
 func WithDetachedPayload(v []byte) VerifyOption {
 	return &verifyOption{option.New(identDetachedPayload{}, v)}
 }
+
+// WithDetachedContent can be used to sign or verify a JWS message with a
+// detached payload (RFC7515 Appendix F). `v` is the content that is
+// signed, but which is not included in the JWS message.
+//
+// When passed to `jws.Sign()` or `jws.SignMulti()`, the `payload`
+// argument must be empty. When passed to `jws.Verify()`, `v` is used
+// in place of the payload in the message.
+//
+// Unlike `jws.WithDetachedPayload()`, `v` is always the content before
+// it is encoded, regardless of the serialization format: `jws.Verify()`
+// base64 encodes it unless the "b64" header field is false.
+func WithDetachedContent(v []byte) SignVerifyOption {
+	return &signVerifyOption{option.New(identDetachedContent{}, v)}
+}
+
+type identStrictKeyUsage struct{}
+
+// WithStrictKeyUsage specifies if a jwk.Key may only be used to sign
+// or verify if its "use", "key_ops", and "alg" fields permit it. This
+// overrides the global setting specified using `jwk.WithStrictKeyUsage()`
+// for a single call to `jws.Sign()`, `jws.SignMulti()`, or `jws.Verify()`.
+func WithStrictKeyUsage(v bool) SignVerifyOption {
+	return &signVerifyOption{option.New(identStrictKeyUsage{}, v)}
+}
+
+type identCriticalHandler struct{}
+
+type criticalHandlerPair struct {
+	name    string
+	handler CriticalHandler
+}
+
+// WithCriticalHandler specifies a handler for the header extension `name`
+// listed in the "crit" header field, for a single call to `jws.Verify()`.
+// Handlers specified using this option take precedence over those
+// registered using `jws.RegisterCriticalHandler()`.
+func WithCriticalHandler(name string, h CriticalHandler) VerifyOption {
+	return &verifyOption{option.New(identCriticalHandler{}, &criticalHandlerPair{name: name, handler: h})}
+}
+
+type identKeyProvider struct{}
+type identContext struct{}
+
+// WithKeyProvider specifies a KeyProvider that is used by `jws.Verify()`
+// to look up the keys used to verify the message. When this option is
+// used, the `alg` and `key` arguments to `jws.Verify()` must be empty.
+func WithKeyProvider(p KeyProvider) VerifyOption {
+	return &verifyOption{option.New(identKeyProvider{}, p)}
+}
+
+// WithContext specifies the context.Context object that is passed to
+// the KeyProvider specified by `jws.WithKeyProvider()`. If not
+// specified, `context.Background()` is used.
+func WithContext(ctx context.Context) VerifyOption {
+	return &verifyOption{option.New(identContext{}, ctx)}
+}
+
+// URLKeyProviderOption describes an option that can be passed to
+// `jws.NewURLKeyProvider()`
+type URLKeyProviderOption interface {
+	Option
+	urlKeyProviderOption()
+}
+
+type urlKeyProviderOption struct {
+	Option
+}
+
+func (*urlKeyProviderOption) urlKeyProviderOption() {}
+
+type identHTTPClient struct{}
+type identAutoRefresh struct{}
+type identX509URLCacheTTL struct{}
+type identMaxCachedURLs struct{}
+
+// WithHTTPClient specifies the HTTP client used by jws.URLKeyProvider
+// to fetch the resources referenced by "jku" and "x5u".
+func WithHTTPClient(cl jwk.HTTPClient) URLKeyProviderOption {
+	return &urlKeyProviderOption{option.New(identHTTPClient{}, cl)}
+}
+
+// WithAutoRefresh specifies the jwk.AutoRefresh object used by
+// jws.URLKeyProvider to fetch and cache the JWK sets referenced by "jku".
+// The URLs are registered to it using `Configure()` the first time
+// they are encountered, and removed from it using `Remove()` when they
+// are evicted (see `jws.WithMaxCachedURLs()`).
+func WithAutoRefresh(ar *jwk.AutoRefresh) URLKeyProviderOption {
+	return &urlKeyProviderOption{option.New(identAutoRefresh{}, ar)}
+}
+
+// WithX509URLCacheTTL specifies how long the certificates referenced by
+// "x5u" are cached by jws.URLKeyProvider. The default is one hour.
+func WithX509URLCacheTTL(d time.Duration) URLKeyProviderOption {
+	return &urlKeyProviderOption{option.New(identX509URLCacheTTL{}, d)}
+}
+
+// WithMaxCachedURLs specifies the maximum number of distinct "jku" URLs,
+// and separately the maximum number of distinct "x5u" URLs, that
+// jws.URLKeyProvider keeps track of. When a new URL is encountered and
+// the limit has been reached, the least recently used "jku" URL, or the
+// "x5u" URL that was fetched first, is evicted. The default is 100.
+func WithMaxCachedURLs(n int) URLKeyProviderOption {
+	return &urlKeyProviderOption{option.New(identMaxCachedURLs{}, n)}
+}
+
+type identVerifyCertificateChain struct{}
+
+type certificateChainOptions struct {
+	roots *x509.CertPool
+	opts  x509.VerifyOptions
+}
+
+// WithVerifyCertificateChain specifies that the signature must be
+// verified using the public key of the leaf certificate in the "x5c"
+// header field, after validating the certificate chain against the
+// trusted root certificates in `roots` using `opts` (see
+// `jwk.VerifyCertificateChain()` for details). The "x5t" and "x5t#S256"
+// header fields, if present, must match the leaf certificate.
+//
+// `roots` is required, and replaces `opts.Roots`: the system root pool
+// is not used unless you pass it explicitly, as otherwise anybody holding
+// a publicly trusted certificate could sign messages that verify.
+//
+// If `opts.KeyUsages` is empty, certificates are accepted regardless of
+// their extended key usages (`x509.ExtKeyUsageAny`), rather than requiring
+// `x509.ExtKeyUsageServerAuth` as `crypto/x509` does. Specify the key
+// usages explicitly to restrict them.
+//
+// When this option is used, the `key` argument to `jws.Verify()` must
+// be nil. If `alg` is not empty, the "alg" header field must match it.
+func WithVerifyCertificateChain(roots *x509.CertPool, opts x509.VerifyOptions) VerifyOption {
+	if len(opts.KeyUsages) == 0 {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+	return &verifyOption{option.New(identVerifyCertificateChain{}, &certificateChainOptions{roots: roots, opts: opts})}
+}
@@ -0,0 +1,325 @@
+package jws
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// maxX509URLSize is the maximum number of bytes read from the
+// resource pointed to by "x5u"
+const maxX509URLSize = 1 << 20
+
+// defaultMaxCachedURLs is the default maximum number of "jku" and "x5u"
+// URLs that a URLKeyProvider keeps track of
+const defaultMaxCachedURLs = 100
+
+// URLKeyProvider is a KeyProvider that resolves the keys referenced by
+// the "jku" (JWK Set URL) and "x5u" (X.509 URL) header fields of a
+// signature. Use it with `jws.WithKeyProvider()` or `jwt.WithKeyProvider()`.
+//
+// Because these header fields are controlled by whoever created the
+// message, only URLs that are allowed by the jwk.Allowlist given to
+// `jws.NewURLKeyProvider()` are ever fetched. Only the protected headers
+// are consulted.
+//
+// JWK sets referenced by "jku" are fetched and cached using a
+// jwk.AutoRefresh object. Keys in the set are used if their "kid" matches
+// the "kid" of the signature (when specified), their "use" is empty or
+// "sig", and their "alg" (when specified) matches the "alg" of the signature.
+//
+// Certificates referenced by "x5u" must be PEM encoded. The public key
+// of the first certificate is used. The certificates are cached for
+// one hour by default. Note that the certificate chain is NOT validated.
+//
+// As an Allowlist may allow any number of URLs (e.g. any query string
+// under a prefix), the number of "jku" URLs registered to the
+// jwk.AutoRefresh object and the number of cached "x5u" URLs are
+// limited. When the limit is reached, a URL is evicted, and fetched
+// again the next time it is encountered. Use `jws.WithMaxCachedURLs()`
+// to change the limit.
+//
+// Symmetric algorithms (HS256, HS384, HS512) are never used with keys
+// obtained this way, as the keys are published to anyone who can access
+// the URL.
+type URLKeyProvider struct {
+	allowlist jwk.Allowlist
+	httpcl    jwk.HTTPClient
+	x5uTTL    time.Duration
+
+	maxURLs      int
+	ar           *jwk.AutoRefresh
+	muConfigured sync.Mutex
+	configured   map[string]time.Time // url -> last used
+
+	muCerts sync.RWMutex
+	certs   map[string]*x509URLEntry
+}
+
+type x509URLEntry struct {
+	certs   []*x509.Certificate
+	expires time.Time
+}
+
+// NewURLKeyProvider creates a new URLKeyProvider that only fetches
+// URLs allowed by `allowlist`.
+//
+// Unless a jwk.AutoRefresh object is specified via `jws.WithAutoRefresh()`,
+// one is created for the provider. The context object controls its
+// life-span, in the same way as `jwk.NewAutoRefresh()`.
+func NewURLKeyProvider(ctx context.Context, allowlist jwk.Allowlist, options ...URLKeyProviderOption) *URLKeyProvider {
+	var httpcl jwk.HTTPClient = http.DefaultClient
+	var ar *jwk.AutoRefresh
+	x5uTTL := time.Hour
+	maxURLs := defaultMaxCachedURLs
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHTTPClient{}:
+			httpcl = option.Value().(jwk.HTTPClient)
+		case identAutoRefresh{}:
+			ar = option.Value().(*jwk.AutoRefresh)
+		case identX509URLCacheTTL{}:
+			x5uTTL = option.Value().(time.Duration)
+		case identMaxCachedURLs{}:
+			maxURLs = option.Value().(int)
+		}
+	}
+
+	if ar == nil {
+		ar = jwk.NewAutoRefresh(ctx)
+	}
+	if maxURLs < 1 {
+		maxURLs = 1
+	}
+
+	// Redirects must not lead to URLs that are not allowed
+	if allowlist != nil {
+		httpcl = jwk.NewAllowlistClient(httpcl, allowlist)
+	}
+
+	return &URLKeyProvider{
+		allowlist:  allowlist,
+		httpcl:     httpcl,
+		x5uTTL:     x5uTTL,
+		maxURLs:    maxURLs,
+		ar:         ar,
+		configured: make(map[string]time.Time),
+		certs:      make(map[string]*x509URLEntry),
+	}
+}
+
+func (p *URLKeyProvider) isAllowed(u string) bool {
+	return p.allowlist != nil && p.allowlist.IsAllowed(u)
+}
+
+func (p *URLKeyProvider) FetchKeys(ctx context.Context, sig *Signature, _ *Message) ([]KeyCandidate, error) {
+	hdrs := sig.ProtectedHeaders()
+	if hdrs == nil {
+		return nil, nil
+	}
+
+	jku := hdrs.JWKSetURL()
+	x5u := hdrs.X509URL()
+	if jku == "" && x5u == "" {
+		return nil, nil
+	}
+
+	alg := hdrs.Algorithm()
+	switch alg {
+	case jwa.HS256, jwa.HS384, jwa.HS512, jwa.NoSignature, "":
+		return nil, errors.Errorf(`algorithm %q cannot be used with keys referenced by "jku" or "x5u"`, alg)
+	}
+
+	var candidates []KeyCandidate
+	if jku != "" {
+		set, err := p.fetchJWKSetURL(ctx, jku)
+		if err != nil {
+			return nil, err
+		}
+
+		filters := []jwk.KeyFilter{
+			jwk.MatchKeyUsage(jwk.ForSignature),
+			jwk.MatchAlgorithm(alg.String()),
+			jwk.KeyFilterFunc(func(key jwk.Key) bool {
+				return keyTypeMatchesAlgorithm(key.KeyType(), alg)
+			}),
+		}
+		if kid := hdrs.KeyID(); kid != "" {
+			filters = append(filters, jwk.MatchKeyID(kid))
+		}
+		for _, key := range jwk.FindKeys(set, filters...) {
+			candidates = append(candidates, KeyCandidate{Algorithm: alg, Key: key})
+		}
+	}
+
+	if x5u != "" {
+		certs, err := p.fetchX509URL(ctx, x5u)
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := jwk.New(certs[0].PublicKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, `failed to create key from certificate in %s`, x5u)
+		}
+		if keyTypeMatchesAlgorithm(key.KeyType(), alg) {
+			candidates = append(candidates, KeyCandidate{Algorithm: alg, Key: key})
+		}
+	}
+	return candidates, nil
+}
+
+func (p *URLKeyProvider) fetchJWKSetURL(ctx context.Context, u string) (jwk.Set, error) {
+	if !p.isAllowed(u) {
+		return nil, errors.Errorf(`"jku" %q is not allowed`, u)
+	}
+
+	p.muConfigured.Lock()
+	if _, ok := p.configured[u]; !ok {
+		if len(p.configured) >= p.maxURLs {
+			p.evictJWKSetURL()
+		}
+		p.ar.Configure(u, jwk.WithHTTPClient(p.httpcl), jwk.WithFetchAllowlist(p.allowlist))
+	}
+	p.configured[u] = time.Now()
+	p.muConfigured.Unlock()
+
+	set, err := p.ar.Fetch(ctx, u)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch "jku" %q`, u)
+	}
+	return set, nil
+}
+
+// evictJWKSetURL removes the least recently used "jku" URL from the
+// jwk.AutoRefresh object. muConfigured must be held by the caller
+func (p *URLKeyProvider) evictJWKSetURL() {
+	var oldest string
+	var oldestUsed time.Time
+	for u, used := range p.configured {
+		if oldest == "" || used.Before(oldestUsed) {
+			oldest, oldestUsed = u, used
+		}
+	}
+	delete(p.configured, oldest)
+	p.ar.Remove(oldest)
+}
+
+func (p *URLKeyProvider) fetchX509URL(ctx context.Context, u string) ([]*x509.Certificate, error) {
+	if !p.isAllowed(u) {
+		return nil, errors.Errorf(`"x5u" %q is not allowed`, u)
+	}
+
+	now := time.Now()
+	p.muCerts.RLock()
+	entry, ok := p.certs[u]
+	p.muCerts.RUnlock()
+	if ok && now.Before(entry.expires) {
+		return entry.certs, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create request for "x5u" %q`, u)
+	}
+
+	res, err := p.httpcl.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch "x5u" %q`, u)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(`failed to fetch "x5u" %q (status = %d)`, u, res.StatusCode)
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, maxX509URLSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to read "x5u" %q`, u)
+	}
+
+	certs, err := parsePEMCertificates(buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to parse "x5u" %q`, u)
+	}
+
+	p.muCerts.Lock()
+	if _, ok := p.certs[u]; !ok && len(p.certs) >= p.maxURLs {
+		p.evictX509URL(now)
+	}
+	p.certs[u] = &x509URLEntry{certs: certs, expires: now.Add(p.x5uTTL)}
+	p.muCerts.Unlock()
+	return certs, nil
+}
+
+// evictX509URL removes expired "x5u" entries from the cache, or the
+// one that expires first if none have expired. muCerts must be held
+// by the caller
+func (p *URLKeyProvider) evictX509URL(now time.Time) {
+	var oldest string
+	var oldestExpires time.Time
+	for u, entry := range p.certs {
+		if !now.Before(entry.expires) {
+			delete(p.certs, u)
+			continue
+		}
+		if oldest == "" || entry.expires.Before(oldestExpires) {
+			oldest, oldestExpires = u, entry.expires
+		}
+	}
+	if len(p.certs) >= p.maxURLs {
+		delete(p.certs, oldest)
+	}
+}
+
+func parsePEMCertificates(buf []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, buf = pem.Decode(buf)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.Wrap(err, `failed to parse certificate`)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New(`no certificates found`)
+	}
+	return certs, nil
+}
+
+// keyTypeMatchesAlgorithm returns true if keys of type `kty` can be used
+// to verify signatures using `alg`
+func keyTypeMatchesAlgorithm(kty jwa.KeyType, alg jwa.SignatureAlgorithm) bool {
+	switch alg {
+	case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+		return kty == jwa.RSA
+	case jwa.ES256, jwa.ES384, jwa.ES512, jwa.ES256K:
+		return kty == jwa.EC
+	case jwa.EdDSA:
+		return kty == jwa.OKP
+	case jwa.HS256, jwa.HS384, jwa.HS512:
+		return kty == jwa.OctetSeq
+	default:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package jws
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/pkg/errors"
+)
+
+// x5cKeyProvider is the KeyProvider used when
+// `jws.WithVerifyCertificateChain()` is specified. It returns the
+// public key of the leaf certificate in the "x5c" header field,
+// after validating the certificate chain.
+type x5cKeyProvider struct {
+	roots *x509.CertPool
+	opts  x509.VerifyOptions
+	alg   jwa.SignatureAlgorithm
+}
+
+func (p *x5cKeyProvider) FetchKeys(_ context.Context, sig *Signature, _ *Message) ([]KeyCandidate, error) {
+	hdrs := sig.ProtectedHeaders()
+	if hdrs == nil {
+		return nil, errors.New(`protected header is required to verify "x5c"`)
+	}
+
+	chain := hdrs.X509CertChain()
+	if len(chain) == 0 {
+		return nil, errors.New(`"x5c" is not present in the protected header`)
+	}
+
+	alg := hdrs.Algorithm()
+	if p.alg != "" && p.alg != alg {
+		return nil, errors.Errorf(`"alg" %q does not match the expected algorithm %q`, alg, p.alg)
+	}
+
+	// Build a key from the leaf certificate, and let
+	// jwk.VerifyCertificateChain do the rest
+	var certs jwk.CertificateChain
+	if err := certs.Accept(chain); err != nil {
+		return nil, errors.Wrap(err, `failed to parse "x5c"`)
+	}
+
+	key, err := jwk.New(certs.Get()[0].PublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, `failed to create key from leaf certificate`)
+	}
+
+	if !keyTypeMatchesAlgorithm(key.KeyType(), alg) || key.KeyType() == jwa.OctetSeq {
+		return nil, errors.Errorf(`algorithm %q cannot be used with the key in "x5c"`, alg)
+	}
+
+	if err := key.Set(jwk.X509CertChainKey, chain); err != nil {
+		return nil, errors.Wrap(err, `failed to set "x5c"`)
+	}
+	if v := hdrs.X509CertThumbprint(); v != "" {
+		if err := key.Set(jwk.X509CertThumbprintKey, v); err != nil {
+			return nil, errors.Wrap(err, `failed to set "x5t"`)
+		}
+	}
+	if v := hdrs.X509CertThumbprintS256(); v != "" {
+		if err := key.Set(jwk.X509CertThumbprintS256Key, v); err != nil {
+			return nil, errors.Wrap(err, `failed to set "x5t#S256"`)
+		}
+	}
+
+	if _, err := jwk.VerifyCertificateChain(key, p.roots, p.opts); err != nil {
+		return nil, errors.Wrap(err, `failed to verify "x5c"`)
+	}
+
+	return []KeyCandidate{{Algorithm: alg, Key: key}}, nil
+}
@@ -6,19 +6,71 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/lestrrat-go/jwx/internal/pool"
 	"github.com/pkg/errors"
 )
 
+// ErrNoTokenInRequest is matched by the error returned from
+// `jwt.ParseRequest()` when none of the searched locations in the
+// request contain a token. It can be tested using `errors.Is()`
+var ErrNoTokenInRequest = errors.New(`no token found in request`)
+
+// locationError is an error encountered while extracting or parsing
+// the token found in a location of the request
+type locationError struct {
+	location string
+	err      error
+}
+
+// parseRequestError is the error returned from `jwt.ParseRequest()`.
+// The errors encountered while parsing each location can be inspected
+// using `errors.Is()` and `errors.As()`
+type parseRequestError struct {
+	message string
+	errs    []error
+}
+
+func (e *parseRequestError) Error() string {
+	return e.message
+}
+
+func (e *parseRequestError) Is(target error) bool {
+	if target == ErrNoTokenInRequest {
+		return len(e.errs) == 0
+	}
+	for _, err := range e.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *parseRequestError) As(target interface{}) bool {
+	for _, err := range e.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // ParseHeader parses a JWT stored in a http.Header.
 //
 // For the header "Authorization", it will strip the prefix "Bearer " and will
 // treat the remaining value as a JWT.
 func ParseHeader(hdr http.Header, name string, options ...ParseOption) (Token, error) {
+	v, err := headerValue(hdr, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(v, options...)
+}
+
+func headerValue(hdr http.Header, name string) (string, error) {
 	key := http.CanonicalHeaderKey(name)
 	v := strings.TrimSpace(hdr.Get(key))
 	if v == "" {
-		return nil, errors.Errorf(`empty header (%s)`, key)
+		return "", errors.Errorf(`empty header (%s)`, key)
 	}
 
 	if key == "Authorization" {
@@ -26,25 +78,115 @@ func ParseHeader(hdr http.Header, name string, options ...ParseOption) (Token, e
 		// the prefix
 		v = strings.TrimSpace(strings.TrimPrefix(v, "Bearer"))
 	}
-
-	return ParseString(v, options...)
+	return v, nil
 }
 
 // ParseForm parses a JWT stored in a url.Value.
 func ParseForm(values url.Values, name string, options ...ParseOption) (Token, error) {
+	v, err := formValue(values, name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseString(v, options...)
+}
+
+func formValue(values url.Values, name string) (string, error) {
 	v := strings.TrimSpace(values.Get(name))
 	if v == "" {
-		return nil, errors.Errorf(`empty value (%s)`, name)
+		return "", errors.Errorf(`empty value (%s)`, name)
 	}
+	return v, nil
+}
 
-	return ParseString(v, options...)
+// TokenExtractor extracts a serialized token from a location in a
+// http.Request. Custom extractors can be passed to `jwt.ParseRequest()`
+// using `jwt.WithTokenExtractor()`.
+type TokenExtractor interface {
+	// Location describes where the extractor looks for the token,
+	// such as `header "Authorization"`. It is used in error messages.
+	Location() string
+
+	// Extract returns the serialized token. If the location does not
+	// exist in the request, it should return an empty string and a nil
+	// error, in which case the next location is searched.
+	Extract(*http.Request) (string, error)
+}
+
+type headerExtractor string
+
+func (e headerExtractor) Location() string {
+	return `header ` + strconv.Quote(string(e))
+}
+
+func (e headerExtractor) Extract(req *http.Request) (string, error) {
+	// Check presence via a direct map lookup
+	if _, ok := req.Header[http.CanonicalHeaderKey(string(e))]; !ok {
+		return "", nil
+	}
+	return headerValue(req.Header, string(e))
+}
+
+type cookieExtractor string
+
+func (e cookieExtractor) Location() string {
+	return `cookie ` + strconv.Quote(string(e))
+}
+
+func (e cookieExtractor) Extract(req *http.Request) (string, error) {
+	c, err := req.Cookie(string(e))
+	if err != nil {
+		// http.ErrNoCookie is the only possible error
+		return "", nil
+	}
+
+	v := strings.TrimSpace(c.Value)
+	if v == "" {
+		return "", errors.Errorf(`empty cookie (%s)`, string(e))
+	}
+	return v, nil
+}
+
+type queryExtractor string
+
+func (e queryExtractor) Location() string {
+	return `query ` + strconv.Quote(string(e))
+}
+
+func (e queryExtractor) Extract(req *http.Request) (string, error) {
+	values := req.URL.Query()
+	if _, ok := values[string(e)]; !ok {
+		return "", nil
+	}
+	return formValue(values, string(e))
+}
+
+type formExtractor string
+
+func (e formExtractor) Location() string {
+	return `form ` + strconv.Quote(string(e))
+}
+
+func (e formExtractor) Extract(req *http.Request) (string, error) {
+	if cl := req.ContentLength; cl > 0 {
+		if err := req.ParseForm(); err != nil {
+			return "", errors.Wrap(err, `failed to parse form`)
+		}
+	}
+
+	// Check presence via a direct map lookup
+	if _, ok := req.Form[string(e)]; !ok {
+		return "", nil
+	}
+	return formValue(req.Form, string(e))
 }
 
 // ParseRequest searches a http.Request object for a JWT token.
 //
 // Specifying WithHeaderKey() will tell it to search under a specific
-// header key. Specifying WithFormKey() will tell it to search under
-// a specific form field.
+// header key. Specifying WithCookieKey(), WithQueryKey(), or WithFormKey()
+// will tell it to search under a specific cookie, query parameter, or
+// form field, respectively. Locations that are not covered by these
+// options can be searched using WithTokenExtractor().
 //
 // By default, "Authorization" header will be searched.
 //
@@ -58,130 +200,101 @@ func ParseForm(values url.Values, name string, options ...ParseOption) (Token, e
 //
 //   # searches for "Authorization" AND "x-my-token"
 //   jwt.ParseRequest(req, http.WithHeaderKey("Authorization"), http.WithHeaderKey("x-my-token"))
+//
+// Locations are searched in the following order, regardless of the
+// order of the options: headers, cookies, query parameters, form fields,
+// and finally custom extractors. Locations of the same kind are searched
+// in the order that they were specified. The first token that is
+// successfully parsed is returned.
+//
+// If no token is found, the returned error matches `jwt.ErrNoTokenInRequest`.
+// Otherwise the errors from parsing each location (for example, validation
+// errors) can be inspected using `errors.Is()` and `errors.As()`.
+// The error message lists all locations that were searched.
 func ParseRequest(req *http.Request, options ...ParseOption) (Token, error) {
-	var hdrkeys []string
-	var formkeys []string
+	var hdrkeys []TokenExtractor
+	var cookiekeys []TokenExtractor
+	var querykeys []TokenExtractor
+	var formkeys []TokenExtractor
+	var custom []TokenExtractor
 	var parseOptions []ParseOption
+	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
 		case identHeaderKey{}:
-			hdrkeys = append(hdrkeys, option.Value().(string))
+			hdrkeys = append(hdrkeys, headerExtractor(option.Value().(string)))
+		case identCookieKey{}:
+			cookiekeys = append(cookiekeys, cookieExtractor(option.Value().(string)))
+		case identQueryKey{}:
+			querykeys = append(querykeys, queryExtractor(option.Value().(string)))
 		case identFormKey{}:
-			formkeys = append(formkeys, option.Value().(string))
+			formkeys = append(formkeys, formExtractor(option.Value().(string)))
+		case identTokenExtractor{}:
+			custom = append(custom, option.Value().(TokenExtractor))
 		default:
 			parseOptions = append(parseOptions, option)
 		}
 	}
 	if len(hdrkeys) == 0 {
-		hdrkeys = append(hdrkeys, "Authorization")
+		hdrkeys = append(hdrkeys, headerExtractor("Authorization"))
 	}
 
-	mhdrs := pool.GetKeyToErrorMap()
-	defer pool.ReleaseKeyToErrorMap(mhdrs)
-	mfrms := pool.GetKeyToErrorMap()
-	defer pool.ReleaseKeyToErrorMap(mfrms)
+	extractors := make([]TokenExtractor, 0, len(hdrkeys)+len(cookiekeys)+len(querykeys)+len(formkeys)+len(custom))
+	extractors = append(extractors, hdrkeys...)
+	extractors = append(extractors, cookiekeys...)
+	extractors = append(extractors, querykeys...)
+	extractors = append(extractors, formkeys...)
+	extractors = append(extractors, custom...)
 
-	for _, hdrkey := range hdrkeys {
-		// Check presence via a direct map lookup
-		if _, ok := req.Header[http.CanonicalHeaderKey(hdrkey)]; !ok {
-			// if non-existent, not error
-			continue
-		}
-
-		tok, err := ParseHeader(req.Header, hdrkey, parseOptions...)
+	// Errors are recorded in the order in which the extractors are tried.
+	// More than one extractor may report the same location
+	var lerrs []locationError
+	for _, extractor := range extractors {
+		v, err := extractor.Extract(req)
 		if err != nil {
-			mhdrs[hdrkey] = err
+			lerrs = append(lerrs, locationError{location: extractor.Location(), err: err})
 			continue
 		}
-		return tok, nil
-	}
-
-	if cl := req.ContentLength; cl > 0 {
-		if err := req.ParseForm(); err != nil {
-			return nil, errors.Wrap(err, `failed to parse form`)
-		}
-	}
 
-	for _, formkey := range formkeys {
-		// Check presence via a direct map lookup
-		if _, ok := req.Form[formkey]; !ok {
+		if v == "" {
 			// if non-existent, not error
 			continue
 		}
 
-		tok, err := ParseForm(req.Form, formkey, parseOptions...)
+		tok, err := ParseString(v, parseOptions...)
 		if err != nil {
-			mfrms[formkey] = err
+			lerrs = append(lerrs, locationError{location: extractor.Location(), err: err})
 			continue
 		}
 		return tok, nil
 	}
 
 	// Everything below is a preulde to error reporting.
-	var triedHdrs strings.Builder
-	for i, hdrkey := range hdrkeys {
-		if i > 0 {
-			triedHdrs.WriteString(", ")
-		}
-		triedHdrs.WriteString(strconv.Quote(hdrkey))
-	}
-
-	var triedForms strings.Builder
-	for i, formkey := range formkeys {
+	var b strings.Builder
+	b.WriteString(`failed to find a valid token in any location of the request (tried: [`)
+	for i, extractor := range extractors {
 		if i > 0 {
-			triedForms.WriteString(", ")
+			b.WriteString(", ")
 		}
-		triedForms.WriteString(strconv.Quote(formkey))
+		b.WriteString(extractor.Location())
 	}
+	b.WriteString("])")
 
-	var b strings.Builder
-	b.WriteString(`failed to find a valid token in any location of the request (tried: [header keys: `)
-	b.WriteString(triedHdrs.String())
-	b.WriteByte(']')
-	if triedForms.Len() > 0 {
-		b.WriteString(", form keys: [")
-		b.WriteString(triedForms.String())
-		b.WriteByte(']')
-	}
-	b.WriteByte(')')
-
-	lmhdrs := len(mhdrs)
-	lmfrms := len(mfrms)
-	if lmhdrs > 0 || lmfrms > 0 {
-		b.WriteString(". Additionally, errors were encountered during attempts to parse")
-
-		if lmhdrs > 0 {
-			b.WriteString(" headers: (")
-			count := 0
-			for hdrkey, err := range mhdrs {
-				if count > 0 {
-					b.WriteString(", ")
-				}
-				b.WriteString("[header key: ")
-				b.WriteString(strconv.Quote(hdrkey))
-				b.WriteString(", error: ")
-				b.WriteString(strconv.Quote(err.Error()))
-				b.WriteString("]")
-				count++
-			}
-			b.WriteString(")")
-		}
-
-		if lmfrms > 0 {
-			count := 0
-			b.WriteString(" forms: (")
-			for formkey, err := range mfrms {
-				if count > 0 {
-					b.WriteString(", ")
-				}
-				b.WriteString("[form key: ")
-				b.WriteString(strconv.Quote(formkey))
-				b.WriteString(", error: ")
-				b.WriteString(strconv.Quote(err.Error()))
-				b.WriteString("]")
-				count++
+	var errs []error
+	if len(lerrs) > 0 {
+		b.WriteString(". Additionally, errors were encountered during attempts to parse: (")
+		for i, lerr := range lerrs {
+			if i > 0 {
+				b.WriteString(", ")
 			}
+			b.WriteString("[")
+			b.WriteString(lerr.location)
+			b.WriteString(", error: ")
+			b.WriteString(strconv.Quote(lerr.err.Error()))
+			b.WriteString("]")
+			errs = append(errs, lerr.err)
 		}
+		b.WriteString(")")
 	}
-	return nil, errors.New(b.String())
+	return nil, &parseRequestError{message: b.String(), errs: errs}
 }
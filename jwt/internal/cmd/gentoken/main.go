@@ -334,6 +334,48 @@ func init() {
 					hasGet:     true,
 					hasAccept:  true,
 				},
+				{
+					name:       "nonce",
+					method:     "Nonce",
+					returnType: "string",
+					typ:        "string",
+					key:        "nonce",
+					Comment:    `https://openid.net/specs/openid-connect-core-1_0.html#IDToken`,
+				},
+				{
+					name:       "authTime",
+					method:     "AuthTime",
+					returnType: "time.Time",
+					typ:        "types.NumericDate",
+					key:        "auth_time",
+					hasGet:     true,
+					hasAccept:  true,
+					Comment:    `https://openid.net/specs/openid-connect-core-1_0.html#IDToken`,
+				},
+				{
+					name:       "authorizedParty",
+					method:     "AuthorizedParty",
+					returnType: "string",
+					typ:        "string",
+					key:        "azp",
+					Comment:    `https://openid.net/specs/openid-connect-core-1_0.html#IDToken`,
+				},
+				{
+					name:       "accessTokenHash",
+					method:     "AccessTokenHash",
+					returnType: "string",
+					typ:        "string",
+					key:        "at_hash",
+					Comment:    `https://openid.net/specs/openid-connect-core-1_0.html#CodeIDToken`,
+				},
+				{
+					name:       "codeHash",
+					method:     "CodeHash",
+					returnType: "string",
+					typ:        "string",
+					key:        "c_hash",
+					Comment:    `https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken`,
+				},
 			}...),
 		},
 	}
@@ -5,6 +5,7 @@ package jwt
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"io/ioutil"
 	"strings"
@@ -60,8 +61,14 @@ func ParseString(s string, options ...ParseOption) (Token, error) {
 // JWE, or JWE then JWS)
 //
 // If the token is signed and you want to verify the payload matches the signature,
-// you must pass the jwt.WithVerify(alg, key) or jwt.WithKeySet(jwk.Set) option.
-// If you do not specify these parameters, no verification will be performed.
+// you must pass the jwt.WithVerify(alg, key), jwt.WithKeySet(jwk.Set), or
+// jwt.WithKeyProvider(jws.KeyProvider) option. If you do not specify these
+// parameters, no verification will be performed.
+//
+// Note that specifying these options does not by itself reject tokens
+// that are not signed at all (e.g. a raw JSON object). Pass
+// `jwt.WithRequireSignature(true)` to make sure that the token was
+// verified.
 //
 // If you also want to assert the validity of the JWT itself (i.e. expiration
 // and such), use the `Validate()` function on the returned token, or pass the
@@ -71,6 +78,9 @@ func ParseString(s string, options ...ParseOption) (Token, error) {
 // This function takes both ParseOption and ValidateOption types:
 // ParseOptions control the parsing behavior, and ValidateOptions are
 // passed to `Validate()` when `jwt.WithValidate` is specified.
+//
+// Errors caused by validation can be distinguished from those caused by
+// parsing, verification or decryption using `jwt.IsValidationError()`.
 func Parse(s []byte, options ...ParseOption) (Token, error) {
 	return parseBytes(s, options...)
 }
@@ -86,23 +96,27 @@ func ParseReader(src io.Reader, options ...ParseOption) (Token, error) {
 }
 
 type parseCtx struct {
+	ctx           context.Context
 	decryptParams DecryptParameters
 	verifyParams  VerifyParameters
 	keySet        jwk.Set
+	keyProvider   jws.KeyProvider
 	token         Token
 	validateOpts  []ValidateOption
 	localReg      *json.Registry
 	pedantic      bool
+	requireSig    bool
 	useDefault    bool
 	validate      bool
 }
 
 func parseBytes(data []byte, options ...ParseOption) (Token, error) {
-	var ctx parseCtx
+	ctx := parseCtx{ctx: context.Background()}
 	for _, o := range options {
+		// ValidateOptions are passed to Validate(). Some of them
+		// (e.g. WithContext) also affect parsing, so we don't skip them
 		if v, ok := o.(ValidateOption); ok {
 			ctx.validateOpts = append(ctx.validateOpts, v)
-			continue
 		}
 
 		//nolint:forcetypeassert
@@ -117,6 +131,10 @@ func parseBytes(data []byte, options ...ParseOption) (Token, error) {
 				return nil, errors.Errorf(`invalid JWK set passed via WithKeySet() option (%T)`, o.Value())
 			}
 			ctx.keySet = ks
+		case identKeyProvider{}:
+			ctx.keyProvider = o.Value().(jws.KeyProvider)
+		case identContext{}:
+			ctx.ctx = o.Value().(context.Context)
 		case identToken{}:
 			token, ok := o.Value().(Token)
 			if !ok {
@@ -125,6 +143,8 @@ func parseBytes(data []byte, options ...ParseOption) (Token, error) {
 			ctx.token = token
 		case identPedantic{}:
 			ctx.pedantic = o.Value().(bool)
+		case identRequireSignature{}:
+			ctx.requireSig = o.Value().(bool)
 		case identDefault{}:
 			ctx.useDefault = o.Value().(bool)
 		case identValidate{}:
@@ -140,6 +160,10 @@ func parseBytes(data []byte, options ...ParseOption) (Token, error) {
 
 	data = bytes.TrimSpace(data)
 
+	if ctx.keyProvider != nil && (ctx.keySet != nil || ctx.verifyParams != nil) {
+		return nil, errors.New(`jwt.WithKeyProvider() cannot be used together with jwt.WithVerify() or jwt.WithKeySet()`)
+	}
+
 	// TODO: This must be moved elsewhere
 	// If with matching kid is true, then look for the corresponding key in the
 	// given key set, by matching the "kid" key
@@ -162,6 +186,9 @@ func parse(ctx *parseCtx, data []byte) (Token, error) {
 	// If cty = `JWT`, we expect this to be a nested structure
 	var expectNested bool
 
+	// Set when a JWS layer has been verified
+	var verified bool
+
 OUTER:
 	for i := 0; i < maxDecodeLevels; i++ {
 		switch kind := jwx.GuessFormat(payload); kind {
@@ -182,7 +209,7 @@ OUTER:
 		case jwx.JWS:
 			// For backwards compatibility, we must allow parsing the JWT
 			// without verifying its contents
-			if vp := ctx.verifyParams; vp != nil {
+			if vp, kp := ctx.verifyParams, ctx.keyProvider; vp != nil || kp != nil {
 				// If verify is true, the data MUST be a valid jws message
 				var m *jws.Message
 				var verifyOpts []jws.VerifyOption
@@ -190,10 +217,21 @@ OUTER:
 					m = jws.NewMessage()
 					verifyOpts = []jws.VerifyOption{jws.WithMessage(m)}
 				}
-				v, err := jws.Verify(payload, vp.Algorithm(), vp.Key(), verifyOpts...)
+
+				var alg jwa.SignatureAlgorithm
+				var key interface{}
+				if kp != nil {
+					verifyOpts = append(verifyOpts, jws.WithKeyProvider(kp), jws.WithContext(ctx.ctx))
+				} else {
+					alg = vp.Algorithm()
+					key = vp.Key()
+				}
+
+				v, err := jws.Verify(payload, alg, key, verifyOpts...)
 				if err != nil {
 					return nil, errors.Wrap(err, `failed to verify jws signature`)
 				}
+				verified = true
 
 				if !ctx.pedantic {
 					payload = v
@@ -264,6 +302,10 @@ OUTER:
 		expectNested = false
 	}
 
+	if ctx.requireSig && !verified {
+		return nil, errors.New(`token must be a verified JWS message`)
+	}
+
 	if ctx.token == nil {
 		ctx.token = New()
 	}
@@ -307,17 +349,23 @@ func lookupMatchingKey(data []byte, keyset jwk.Set, useDefault bool) (jwa.Signat
 	}
 
 	var key jwk.Key
-	var ok bool
 	if kid == "" {
-		key, ok = keyset.Get(0)
-		if !ok {
+		if keyset.Len() == 0 {
 			return "", nil, errors.New(`empty keyset`)
 		}
+		// The default key is subject to the same checks as keys that
+		// are looked up by their key ID
+		keys := jwk.FindKeys(keyset, jwk.MatchKeyUsage(jwk.ForSignature))
+		if len(keys) == 0 {
+			return "", nil, errors.New(`failed to find matching key: the only key in key set may not be used for signatures`)
+		}
+		key = keys[0]
 	} else {
-		key, ok = keyset.LookupKeyID(kid)
-		if !ok {
+		keys := jwk.FindKeys(keyset, jwk.MatchKeyID(kid), jwk.MatchKeyUsage(jwk.ForSignature))
+		if len(keys) == 0 {
 			return "", nil, errors.Errorf(`failed to find matching key for key ID %#v in key set`, kid)
 		}
+		key = keys[0]
 	}
 
 	var rawKey interface{}
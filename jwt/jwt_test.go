@@ -200,6 +200,26 @@ func TestJWTParseVerify(t *testing.T) {
 				return
 			}
 		})
+		t.Run("Default key for encryption should fail", func(t *testing.T) {
+			t.Parallel()
+			pubkey := jwk.NewRSAPublicKey()
+			if !assert.NoError(t, pubkey.FromRaw(&key.PublicKey)) {
+				return
+			}
+
+			pubkey.Set(jwk.AlgorithmKey, alg)
+			pubkey.Set(jwk.KeyUsageKey, jwk.ForEncryption)
+			signedNoKid, err := jwt.Sign(t1, alg, key)
+			if err != nil {
+				t.Fatal("Failed to sign JWT")
+			}
+			set := jwk.NewSet()
+			set.Add(pubkey)
+			_, err = jwt.Parse(signedNoKid, jwt.WithKeySet(set), jwt.UseDefaultKey(true))
+			if !assert.Error(t, err, `jwt.Parse should fail`) {
+				return
+			}
+		})
 		t.Run("UseDefault with multiple keys should fail", func(t *testing.T) {
 			t.Parallel()
 			pubkey1 := jwk.NewRSAPublicKey()
@@ -227,6 +247,53 @@ func TestJWTParseVerify(t *testing.T) {
 		})
 	})
 
+	t.Run("Parse (w/jws.KeyProvider)", func(t *testing.T) {
+		t.Parallel()
+		type ctxKey struct{}
+
+		decoy, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, "RSA key generated") {
+			return
+		}
+
+		var called bool
+		provider := jws.KeyProviderFunc(func(ctx context.Context, sig *jws.Signature, _ *jws.Message) ([]jws.KeyCandidate, error) {
+			called = true
+			if ctx.Value(ctxKey{}) != "tenant" {
+				return nil, errors.New(`context was not passed`)
+			}
+			if sig.ProtectedHeaders().KeyID() != kid {
+				return nil, errors.New(`unknown kid`)
+			}
+			return []jws.KeyCandidate{
+				{Algorithm: alg, Key: &decoy.PublicKey},
+				{Algorithm: alg, Key: &key.PublicKey},
+			}, nil
+		})
+
+		ctx := context.WithValue(context.Background(), ctxKey{}, "tenant")
+		t2, err := jwt.Parse(signed, jwt.WithKeyProvider(provider), jwt.WithContext(ctx))
+		if !assert.NoError(t, err, `jwt.Parse with key provider should succeed`) {
+			return
+		}
+		if !assert.True(t, called, `key provider should be called`) {
+			return
+		}
+		if !assert.True(t, jwt.Equal(t1, t2), `t1 == t2`) {
+			return
+		}
+
+		_, err = jwt.Parse(signed, jwt.WithKeyProvider(provider))
+		if !assert.Error(t, err, `jwt.Parse without context should fail`) {
+			return
+		}
+
+		_, err = jwt.Parse(signed, jwt.WithKeyProvider(provider), jwt.WithVerify(alg, &key.PublicKey))
+		if !assert.Error(t, err, `jwt.Parse with both jwt.WithKeyProvider and jwt.WithVerify should fail`) {
+			return
+		}
+	})
+
 	// This is a test to check if we allow alg: none in the protected header section.
 	// But in truth, since we delegate everything to jws.Verify anyways, it's really
 	// a test to see if jws.Verify returns an error if alg: none is specified in the
@@ -312,6 +379,55 @@ func TestValidateClaims(t *testing.T) {
 	})
 }
 
+func TestParseValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	t1 := jwt.New()
+	t1.Set(jwt.ExpirationKey, time.Now().Add(-1*time.Hour))
+
+	signed, err := jwt.Sign(t1, jwa.RS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	t.Run("Expired token", func(t *testing.T) {
+		t.Parallel()
+		_, err := jwt.Parse(signed, jwt.WithVerify(jwa.RS256, &key.PublicKey), jwt.WithValidate(true))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `error should be jwt.ErrTokenExpired`) {
+			return
+		}
+		if !assert.True(t, jwt.IsValidationError(err), `error should be a validation error`) {
+			return
+		}
+	})
+	t.Run("Invalid signature", func(t *testing.T) {
+		t.Parallel()
+		otherKey, err := jwxtest.GenerateRsaKey()
+		if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(signed, jwt.WithVerify(jwa.RS256, &otherKey.PublicKey), jwt.WithValidate(true))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+		if !assert.False(t, jwt.IsValidationError(err), `error should not be a validation error`) {
+			return
+		}
+		if !assert.False(t, errors.Is(err, jwt.ErrTokenExpired), `error should not be jwt.ErrTokenExpired`) {
+			return
+		}
+	})
+}
+
 const aLongLongTimeAgo = 233431200
 const aLongLongTimeAgoString = "233431200"
 
@@ -753,6 +869,70 @@ func TestParseRequest(t *testing.T) {
 			},
 			Error: true,
 		},
+		{
+			Name: "Token in cookie (w/ option)",
+			Request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, u, nil)
+				req.AddCookie(&http.Cookie{Name: "token", Value: string(signed)})
+				return req
+			},
+			Parse: func(req *http.Request) (jwt.Token, error) {
+				return jwt.ParseRequest(req, jwt.WithCookieKey("token"), jwt.WithVerify(jwa.ES256, pubkey))
+			},
+		},
+		{
+			Name: "Token in cookie (w/o option)",
+			Request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, u, nil)
+				req.AddCookie(&http.Cookie{Name: "token", Value: string(signed)})
+				return req
+			},
+			Parse: func(req *http.Request) (jwt.Token, error) {
+				return jwt.ParseRequest(req, jwt.WithVerify(jwa.ES256, pubkey))
+			},
+			Error: true,
+		},
+		{
+			Name: "Token in access_token query parameter (w/ option)",
+			Request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, u+"?access_token="+string(signed), nil)
+			},
+			Parse: func(req *http.Request) (jwt.Token, error) {
+				return jwt.ParseRequest(req, jwt.WithQueryKey("access_token"), jwt.WithVerify(jwa.ES256, pubkey))
+			},
+		},
+		{
+			Name: "Token in access_token query parameter (w/o option)",
+			Request: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, u+"?access_token="+string(signed), nil)
+			},
+			Parse: func(req *http.Request) (jwt.Token, error) {
+				return jwt.ParseRequest(req, jwt.WithFormKey("access_token"), jwt.WithVerify(jwa.ES256, pubkey))
+			},
+			Error: true,
+		},
+		{
+			Name: "Invalid token in header, valid token in query parameter",
+			Request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, u+"?access_token="+string(signed), nil)
+				req.Header.Add("Authorization", "Bearer "+string(signed)+"foobarbaz")
+				return req
+			},
+			Parse: func(req *http.Request) (jwt.Token, error) {
+				return jwt.ParseRequest(req, jwt.WithQueryKey("access_token"), jwt.WithVerify(jwa.ES256, pubkey))
+			},
+		},
+		{
+			Name: "Token in custom location (w/ option)",
+			Request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, u, nil)
+				req.Header.Add("Sec-WebSocket-Protocol", "jwt, "+string(signed))
+				return req
+			},
+			Parse: func(req *http.Request) (jwt.Token, error) {
+				return jwt.ParseRequest(req, jwt.WithTokenExtractor(testTokenExtractor{}), jwt.WithVerify(jwa.ES256, pubkey))
+			},
+		},
 	}
 
 	for _, tc := range testcases {
@@ -784,6 +964,117 @@ func TestParseRequest(t *testing.T) {
 	}
 }
 
+type testTokenExtractor struct{}
+
+func (testTokenExtractor) Location() string {
+	return `websocket protocol`
+}
+
+func (testTokenExtractor) Extract(req *http.Request) (string, error) {
+	v := req.Header.Get(`Sec-WebSocket-Protocol`)
+	if v == "" {
+		return "", nil
+	}
+
+	list := strings.Split(v, `,`)
+	if len(list) != 2 || strings.TrimSpace(list[0]) != `jwt` {
+		return "", errors.New(`invalid protocol list`)
+	}
+	return strings.TrimSpace(list[1]), nil
+}
+
+type failingTokenExtractor struct {
+	err error
+}
+
+func (failingTokenExtractor) Location() string {
+	return `websocket protocol`
+}
+
+func (e failingTokenExtractor) Extract(*http.Request) (string, error) {
+	return "", e.err
+}
+
+func TestParseRequestErrors(t *testing.T) {
+	key := jwxtest.GenerateSymmetricKey()
+	tok := jwt.New()
+	_ = tok.Set(jwt.ExpirationKey, time.Now().Add(-time.Hour))
+	signed, err := jwt.Sign(tok, jwa.HS256, key)
+	if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+		return
+	}
+
+	t.Run("No token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, `https://example.com`, nil)
+		_, err := jwt.ParseRequest(req, jwt.WithVerify(jwa.HS256, key))
+		if !assert.True(t, errors.Is(err, jwt.ErrNoTokenInRequest), `error should be jwt.ErrNoTokenInRequest`) {
+			return
+		}
+	})
+	t.Run("Every location is reported", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, `https://example.com?token=`+string(signed), nil)
+		req.Header.Set(`Sec-WebSocket-Protocol`, `chat`)
+		_, err := jwt.ParseRequest(req,
+			jwt.WithFormKey(`access_token`),
+			jwt.WithTokenExtractor(testTokenExtractor{}),
+			jwt.WithQueryKey(`token`),
+			jwt.WithCookieKey(`session`),
+			jwt.WithHeaderKey(`X-Token`),
+			jwt.WithVerify(jwa.HS256, key),
+			jwt.WithValidate(true),
+		)
+		if !assert.Error(t, err, `jwt.ParseRequest should fail`) {
+			return
+		}
+		if !assert.True(t, strings.HasPrefix(err.Error(), `failed to find a valid token in any location of the request (tried: [header "X-Token", cookie "session", query "token", form "access_token", websocket protocol])`), `error should list locations in search order`) {
+			t.Logf("%s", err)
+			return
+		}
+		if !assert.Contains(t, err.Error(), `[query "token", error: `, `error should contain the error for the query parameter`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `[websocket protocol, error: "invalid protocol list"]`, `error should contain the error for the custom extractor`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `error should be jwt.ErrTokenExpired`) {
+			return
+		}
+	})
+	t.Run("Extractors with the same location", func(t *testing.T) {
+		errFailed := errors.New(`failed to read protocol`)
+		req := httptest.NewRequest(http.MethodGet, `https://example.com`, nil)
+		req.Header.Set(`Sec-WebSocket-Protocol`, `chat`)
+		_, err := jwt.ParseRequest(req,
+			jwt.WithTokenExtractor(testTokenExtractor{}),
+			jwt.WithTokenExtractor(failingTokenExtractor{err: errFailed}),
+			jwt.WithVerify(jwa.HS256, key),
+		)
+		if !assert.Error(t, err, `jwt.ParseRequest should fail`) {
+			return
+		}
+		if !assert.Contains(t, err.Error(), `[websocket protocol, error: "invalid protocol list"], [websocket protocol, error: "failed to read protocol"]`, `error should contain every error in order`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, errFailed), `error should match the error from the second extractor`) {
+			return
+		}
+	})
+	t.Run("Expired token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, `https://example.com`, nil)
+		req.Header.Set(`Authorization`, `Bearer `+string(signed))
+		_, err := jwt.ParseRequest(req, jwt.WithVerify(jwa.HS256, key), jwt.WithValidate(true))
+		if !assert.False(t, errors.Is(err, jwt.ErrNoTokenInRequest), `error should not be jwt.ErrNoTokenInRequest`) {
+			return
+		}
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `error should be jwt.ErrTokenExpired`) {
+			return
+		}
+		if !assert.True(t, jwt.IsValidationError(err), `jwt.IsValidationError should be true`) {
+			return
+		}
+	})
+}
+
 func TestGHIssue368(t *testing.T) {
 	// DO NOT RUN THIS IN PARALLEL
 	for _, flatten := range []bool{true, false} {
@@ -1103,6 +1394,59 @@ func TestNested(t *testing.T) {
 		return
 	}
 	_ = parsed
+
+	_, err = jwt.Parse(serialized,
+		jwt.WithRequireSignature(true),
+		jwt.WithVerify(jwa.RS256, key.PublicKey),
+		jwt.WithDecrypt(jwa.RSA_OAEP, key),
+	)
+	if !assert.NoError(t, err, `jwt.Parse with jwt.WithRequireSignature should succeed`) {
+		return
+	}
+}
+
+func TestRequireSignature(t *testing.T) {
+	key, err := jwxtest.GenerateRsaKey()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaKey should succeed`) {
+		return
+	}
+
+	token := jwt.New()
+	token.Set(jwt.SubjectKey, `admin`)
+
+	encrypted, err := jwt.NewSerializer().
+		Encrypt(jwa.RSA_OAEP, key.PublicKey, jwa.A256GCM, jwa.NoCompress).
+		Serialize(token)
+	if !assert.NoError(t, err, `jwt.NewSerializer should succeed`) {
+		return
+	}
+
+	testcases := []struct {
+		Name  string
+		Input []byte
+	}{
+		{Name: "Raw JSON", Input: []byte(`{"sub":"admin","aud":["x"]}`)},
+		{Name: "Encrypted but not signed", Input: encrypted},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			options := []jwt.ParseOption{
+				jwt.WithVerify(jwa.RS256, key.PublicKey),
+				jwt.WithDecrypt(jwa.RSA_OAEP, key),
+			}
+
+			// Without jwt.WithRequireSignature, the token is accepted
+			if _, err := jwt.Parse(tc.Input, options...); !assert.NoError(t, err, `jwt.Parse should succeed`) {
+				return
+			}
+
+			_, err := jwt.Parse(tc.Input, append(options, jwt.WithRequireSignature(true))...)
+			if !assert.Error(t, err, `jwt.Parse with jwt.WithRequireSignature should fail`) {
+				return
+			}
+		})
+	}
 }
 
 func TestRFC7797(t *testing.T) {
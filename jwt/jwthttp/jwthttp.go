@@ -0,0 +1,217 @@
+// Package jwthttp provides a net/http middleware that authenticates
+// requests using JWTs.
+package jwthttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+)
+
+type identToken struct{}
+
+// ErrKeyRetrievalFailed is matched by the errors passed to the
+// ErrorHandler when the keys to verify the token could not be retrieved,
+// for example because the key set could not be fetched by the
+// jwk.AutoRefresh object, or because the jws.KeyProvider returned an
+// error. It can be tested using `errors.Is()`
+var ErrKeyRetrievalFailed = errors.New(`failed to retrieve keys`)
+
+// keyRetrievalError wraps errors encountered while retrieving keys, so
+// that they can be told apart from errors caused by the token itself
+type keyRetrievalError struct {
+	err error
+}
+
+func (e *keyRetrievalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *keyRetrievalError) Is(target error) bool {
+	return target == ErrKeyRetrievalFailed
+}
+
+func (e *keyRetrievalError) Unwrap() error {
+	return e.err
+}
+
+// FromContext returns the token that was stored in the context by the
+// middleware, or nil if the context does not contain a token.
+func FromContext(ctx context.Context) jwt.Token {
+	if tok, ok := ctx.Value(identToken{}).(jwt.Token); ok {
+		return tok
+	}
+	return nil
+}
+
+// ErrorHandler is called by the middleware when a request cannot be
+// authenticated. The error is the one returned by `jwt.ParseRequest()`
+// (or from fetching the keys), and can be inspected using `errors.Is()`
+// and `errors.As()`, for example against `jwt.ErrNoTokenInRequest`,
+// `jwt.ErrTokenExpired`, or `jwthttp.ErrKeyRetrievalFailed`.
+type ErrorHandler interface {
+	HandleError(http.ResponseWriter, *http.Request, error)
+}
+
+// ErrorHandlerFunc is an ErrorHandler represented by a function
+type ErrorHandlerFunc func(http.ResponseWriter, *http.Request, error)
+
+func (f ErrorHandlerFunc) HandleError(w http.ResponseWriter, r *http.Request, err error) {
+	f(w, r, err)
+}
+
+// DefaultErrorHandler returns the ErrorHandler that is used when none
+// is specified. It responds with "401 Unauthorized" and a WWW-Authenticate
+// header as described in RFC6750. If `realm` is not empty, it is
+// included in the header.
+//
+// If the request does not contain a token, the header does not contain
+// an error code. Otherwise the error code is "invalid_token".
+//
+// If the keys to verify the token could not be retrieved, the token
+// may well be valid, so it responds with "503 Service Unavailable"
+// instead.
+func DefaultErrorHandler(realm string) ErrorHandler {
+	return ErrorHandlerFunc(func(w http.ResponseWriter, _ *http.Request, err error) {
+		if errors.Is(err, ErrKeyRetrievalFailed) {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		var params []string
+		if realm != "" {
+			params = append(params, `realm=`+strconv.Quote(realm))
+		}
+		if !errors.Is(err, jwt.ErrNoTokenInRequest) {
+			params = append(params, `error="invalid_token"`)
+			if errors.Is(err, jwt.ErrTokenExpired) {
+				params = append(params, `error_description="the token has expired"`)
+			}
+		}
+
+		challenge := `Bearer`
+		if len(params) > 0 {
+			challenge += ` ` + strings.Join(params, `, `)
+		}
+		w.Header().Set(`WWW-Authenticate`, challenge)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// Middleware authenticates requests using JWTs. Requests that carry a
+// valid token are passed to the next handler, with the token stored in
+// the request context. Use `jwthttp.FromContext()` to retrieve it.
+type Middleware struct {
+	keyOption    func(context.Context) (jwt.ParseOption, error)
+	parseOptions []jwt.ParseOption
+	errorHandler ErrorHandler
+}
+
+// New creates a new Middleware. Exactly one of `jwthttp.WithVerify()`,
+// `jwthttp.WithKeySet()`, `jwthttp.WithKeyProvider()`, or
+// `jwthttp.WithAutoRefresh()` must be specified, so that tokens are
+// always verified. Tokens that are not signed are always rejected.
+//
+// Tokens are always validated. Use `jwthttp.WithValidateOptions()` to
+// specify how.
+func New(options ...Option) (*Middleware, error) {
+	var keyOptions []func(context.Context) (jwt.ParseOption, error)
+	var parseOptions []jwt.ParseOption
+	var errorHandler ErrorHandler
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identVerify{}:
+			src := option.Value().(verifySource)
+			keyOptions = append(keyOptions, staticKeyOption(jwt.WithVerify(src.alg, src.key)))
+		case identKeySet{}:
+			keyOptions = append(keyOptions, staticKeyOption(jwt.WithKeySet(option.Value().(jwk.Set))))
+		case identKeyProvider{}:
+			keyOptions = append(keyOptions, staticKeyOption(jwt.WithKeyProvider(keyRetrievalProvider(option.Value().(jws.KeyProvider)))))
+		case identAutoRefresh{}:
+			src := option.Value().(autoRefreshSource)
+			keyOptions = append(keyOptions, func(ctx context.Context) (jwt.ParseOption, error) {
+				set, err := src.ar.Fetch(ctx, src.url)
+				if err != nil {
+					return nil, &keyRetrievalError{err: errors.Wrapf(err, `failed to fetch key set from %s`, src.url)}
+				}
+				return jwt.WithKeySet(set), nil
+			})
+		case identParseOptions{}:
+			parseOptions = append(parseOptions, option.Value().([]jwt.ParseOption)...)
+		case identErrorHandler{}:
+			errorHandler = option.Value().(ErrorHandler)
+		}
+	}
+
+	switch len(keyOptions) {
+	case 0:
+		return nil, errors.New(`one of jwthttp.WithVerify(), jwthttp.WithKeySet(), jwthttp.WithKeyProvider(), or jwthttp.WithAutoRefresh() must be specified`)
+	case 1:
+	default:
+		return nil, errors.New(`only one of jwthttp.WithVerify(), jwthttp.WithKeySet(), jwthttp.WithKeyProvider(), or jwthttp.WithAutoRefresh() may be specified`)
+	}
+
+	if errorHandler == nil {
+		errorHandler = DefaultErrorHandler("")
+	}
+
+	return &Middleware{
+		keyOption:    keyOptions[0],
+		parseOptions: parseOptions,
+		errorHandler: errorHandler,
+	}, nil
+}
+
+// keyRetrievalProvider wraps the errors returned by `p`, so that they
+// are reported as key retrieval errors
+func keyRetrievalProvider(p jws.KeyProvider) jws.KeyProvider {
+	return jws.KeyProviderFunc(func(ctx context.Context, sig *jws.Signature, m *jws.Message) ([]jws.KeyCandidate, error) {
+		keys, err := p.FetchKeys(ctx, sig, m)
+		if err != nil {
+			return nil, &keyRetrievalError{err: err}
+		}
+		return keys, nil
+	})
+}
+
+func staticKeyOption(option jwt.ParseOption) func(context.Context) (jwt.ParseOption, error) {
+	return func(context.Context) (jwt.ParseOption, error) {
+		return option, nil
+	}
+}
+
+// ParseRequest parses, verifies, and validates the token in the request,
+// in the same way as the middleware does.
+func (m *Middleware) ParseRequest(req *http.Request) (jwt.Token, error) {
+	ctx := req.Context()
+	keyOption, err := m.keyOption(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]jwt.ParseOption, 0, len(m.parseOptions)+4)
+	options = append(options, m.parseOptions...)
+	options = append(options, keyOption, jwt.WithRequireSignature(true), jwt.WithValidate(true), jwt.WithContext(ctx))
+	return jwt.ParseRequest(req, options...)
+}
+
+// Wrap returns a http.Handler that authenticates requests before
+// passing them to `next`. Requests that cannot be authenticated are
+// passed to the ErrorHandler instead.
+func (m *Middleware) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tok, err := m.ParseRequest(req)
+		if err != nil {
+			m.errorHandler.HandleError(w, req, err)
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), identToken{}, tok)))
+	})
+}
@@ -0,0 +1,297 @@
+package jwthttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/jwxtest"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/jwthttp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	privkey, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = privkey.Set(jwk.KeyIDKey, `my-key`)
+	_ = privkey.Set(jwk.AlgorithmKey, jwa.RS256)
+	pubkey, err := jwk.PublicKeyOf(privkey)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+
+	sign := func(t *testing.T, exp time.Time) string {
+		t.Helper()
+		tok := jwt.New()
+		_ = tok.Set(jwt.IssuerKey, `github.com/lestrrat-go/jwx`)
+		_ = tok.Set(jwt.SubjectKey, `alice`)
+		_ = tok.Set(jwt.ExpirationKey, exp)
+		signed, err := jwt.Sign(tok, jwa.RS256, privkey)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			t.FailNow()
+		}
+		return string(signed)
+	}
+	valid := sign(t, time.Now().Add(time.Hour))
+	expired := sign(t, time.Now().Add(-time.Hour))
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tok := jwthttp.FromContext(r.Context())
+		if tok == nil {
+			http.Error(w, `no token in context`, http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(tok.Subject()))
+	})
+
+	serve := func(t *testing.T, m *jwthttp.Middleware, authz string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, `https://example.com`, nil)
+		if authz != "" {
+			req.Header.Set(`Authorization`, authz)
+		}
+		w := httptest.NewRecorder()
+		m.Wrap(next).ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Key source is required", func(t *testing.T) {
+		_, err := jwthttp.New()
+		if !assert.Error(t, err, `jwthttp.New should fail`) {
+			return
+		}
+		_, err = jwthttp.New(jwthttp.WithVerify(jwa.RS256, pubkey), jwthttp.WithKeyProvider(jws.KeyProviderFunc(nil)))
+		if !assert.Error(t, err, `jwthttp.New should fail`) {
+			return
+		}
+	})
+	t.Run("WithVerify", func(t *testing.T) {
+		m, err := jwthttp.New(jwthttp.WithVerify(jwa.RS256, pubkey))
+		if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+			return
+		}
+
+		t.Run("Valid token", func(t *testing.T) {
+			w := serve(t, m, `Bearer `+valid)
+			if !assert.Equal(t, http.StatusOK, w.Code, `status should be 200`) {
+				return
+			}
+			if !assert.Equal(t, `alice`, w.Body.String(), `token should be available from the context`) {
+				return
+			}
+		})
+		t.Run("No token", func(t *testing.T) {
+			w := serve(t, m, ``)
+			if !assert.Equal(t, http.StatusUnauthorized, w.Code, `status should be 401`) {
+				return
+			}
+			if !assert.Equal(t, `Bearer`, w.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should not contain an error`) {
+				return
+			}
+		})
+		t.Run("Unsigned token", func(t *testing.T) {
+			w := serve(t, m, `Bearer {"sub":"admin","aud":["x"]}`)
+			if !assert.Equal(t, http.StatusUnauthorized, w.Code, `status should be 401`) {
+				return
+			}
+			if !assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should match`) {
+				return
+			}
+		})
+		t.Run("Bad signature", func(t *testing.T) {
+			w := serve(t, m, `Bearer `+valid+`AAAA`)
+			if !assert.Equal(t, http.StatusUnauthorized, w.Code, `status should be 401`) {
+				return
+			}
+			if !assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should match`) {
+				return
+			}
+		})
+		t.Run("Expired token", func(t *testing.T) {
+			w := serve(t, m, `Bearer `+expired)
+			if !assert.Equal(t, http.StatusUnauthorized, w.Code, `status should be 401`) {
+				return
+			}
+			if !assert.Equal(t, `Bearer error="invalid_token", error_description="the token has expired"`, w.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should match`) {
+				return
+			}
+		})
+	})
+	t.Run("WithValidateOptions", func(t *testing.T) {
+		m, err := jwthttp.New(
+			jwthttp.WithVerify(jwa.RS256, pubkey),
+			jwthttp.WithValidateOptions(jwt.WithSubject(`bob`)),
+			jwthttp.WithErrorHandler(jwthttp.DefaultErrorHandler(`example`)),
+		)
+		if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+			return
+		}
+
+		w := serve(t, m, `Bearer `+valid)
+		if !assert.Equal(t, http.StatusUnauthorized, w.Code, `status should be 401`) {
+			return
+		}
+		if !assert.Equal(t, `Bearer realm="example", error="invalid_token"`, w.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should match`) {
+			return
+		}
+	})
+	t.Run("WithParseOptions", func(t *testing.T) {
+		m, err := jwthttp.New(
+			jwthttp.WithKeySet(func() jwk.Set {
+				set := jwk.NewSet()
+				set.Add(pubkey)
+				return set
+			}()),
+			jwthttp.WithParseOptions(jwt.WithHeaderKey(`X-Token`)),
+		)
+		if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+			return
+		}
+
+		req := httptest.NewRequest(http.MethodGet, `https://example.com`, nil)
+		req.Header.Set(`X-Token`, valid)
+		w := httptest.NewRecorder()
+		m.Wrap(next).ServeHTTP(w, req)
+		if !assert.Equal(t, http.StatusOK, w.Code, `status should be 200`) {
+			return
+		}
+	})
+	t.Run("WithErrorHandler", func(t *testing.T) {
+		var handled error
+		m, err := jwthttp.New(
+			jwthttp.WithVerify(jwa.RS256, pubkey),
+			jwthttp.WithErrorHandler(jwthttp.ErrorHandlerFunc(func(w http.ResponseWriter, _ *http.Request, err error) {
+				handled = err
+				w.WriteHeader(http.StatusForbidden)
+			})),
+		)
+		if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+			return
+		}
+
+		w := serve(t, m, `Bearer `+expired)
+		if !assert.Equal(t, http.StatusForbidden, w.Code, `status should be 403`) {
+			return
+		}
+		if !assert.True(t, errors.Is(handled, jwt.ErrTokenExpired), `error should be jwt.ErrTokenExpired`) {
+			return
+		}
+	})
+	t.Run("WithKeyProvider", func(t *testing.T) {
+		var called bool
+		m, err := jwthttp.New(jwthttp.WithKeyProvider(jws.KeyProviderFunc(func(_ context.Context, sig *jws.Signature, _ *jws.Message) ([]jws.KeyCandidate, error) {
+			called = true
+			return []jws.KeyCandidate{{Algorithm: jwa.RS256, Key: pubkey}}, nil
+		})))
+		if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+			return
+		}
+
+		w := serve(t, m, `Bearer `+valid)
+		if !assert.Equal(t, http.StatusOK, w.Code, `status should be 200`) {
+			return
+		}
+		if !assert.True(t, called, `key provider should be called`) {
+			return
+		}
+	})
+	t.Run("WithAutoRefresh", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			set := jwk.NewSet()
+			set.Add(pubkey)
+			w.Header().Set(`Content-Type`, `application/json`)
+			_ = json.NewEncoder(w).Encode(set)
+		}))
+		defer srv.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ar := jwk.NewAutoRefresh(ctx)
+		ar.Configure(srv.URL)
+
+		m, err := jwthttp.New(jwthttp.WithAutoRefresh(ar, srv.URL))
+		if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+			return
+		}
+
+		w := serve(t, m, `Bearer `+valid)
+		if !assert.Equal(t, http.StatusOK, w.Code, `status should be 200`) {
+			return
+		}
+		if !assert.Equal(t, `alice`, w.Body.String(), `token should be available from the context`) {
+			return
+		}
+	})
+	t.Run("Key retrieval errors", func(t *testing.T) {
+		t.Run("WithKeyProvider", func(t *testing.T) {
+			var handled error
+			m, err := jwthttp.New(
+				jwthttp.WithKeyProvider(jws.KeyProviderFunc(func(context.Context, *jws.Signature, *jws.Message) ([]jws.KeyCandidate, error) {
+					return nil, errors.New(`key store is unavailable`)
+				})),
+				jwthttp.WithErrorHandler(jwthttp.ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) {
+					handled = err
+					jwthttp.DefaultErrorHandler("").HandleError(w, r, err)
+				})),
+			)
+			if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+				return
+			}
+
+			w := serve(t, m, `Bearer `+valid)
+			if !assert.Equal(t, http.StatusServiceUnavailable, w.Code, `status should be 503`) {
+				return
+			}
+			if !assert.Empty(t, w.Header().Get(`WWW-Authenticate`), `WWW-Authenticate should not be set`) {
+				return
+			}
+			if !assert.True(t, errors.Is(handled, jwthttp.ErrKeyRetrievalFailed), `error should be jwthttp.ErrKeyRetrievalFailed`) {
+				return
+			}
+
+			// Errors caused by the token are still reported as such
+			w = serve(t, m, `Bearer garbage`)
+			if !assert.Equal(t, http.StatusUnauthorized, w.Code, `status should be 401`) {
+				return
+			}
+		})
+		t.Run("WithAutoRefresh", func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			ar := jwk.NewAutoRefresh(ctx)
+			ar.Configure(srv.URL)
+
+			m, err := jwthttp.New(jwthttp.WithAutoRefresh(ar, srv.URL))
+			if !assert.NoError(t, err, `jwthttp.New should succeed`) {
+				return
+			}
+
+			w := serve(t, m, `Bearer `+valid)
+			if !assert.Equal(t, http.StatusServiceUnavailable, w.Code, `status should be 503`) {
+				return
+			}
+		})
+	})
+	t.Run("FromContext without token", func(t *testing.T) {
+		if !assert.Nil(t, jwthttp.FromContext(context.Background()), `FromContext should return nil`) {
+			return
+		}
+	})
+}
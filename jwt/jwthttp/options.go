@@ -0,0 +1,83 @@
+package jwthttp
+
+import (
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identAutoRefresh struct{}
+type identErrorHandler struct{}
+type identKeyProvider struct{}
+type identKeySet struct{}
+type identParseOptions struct{}
+type identVerify struct{}
+
+type autoRefreshSource struct {
+	ar  *jwk.AutoRefresh
+	url string
+}
+
+type verifySource struct {
+	alg jwa.SignatureAlgorithm
+	key interface{}
+}
+
+// WithVerify specifies the algorithm and the key used to verify tokens,
+// in the same way as `jwt.WithVerify()`.
+func WithVerify(alg jwa.SignatureAlgorithm, key interface{}) Option {
+	return option.New(identVerify{}, verifySource{alg: alg, key: key})
+}
+
+// WithKeySet specifies the set of keys used to verify tokens, in the
+// same way as `jwt.WithKeySet()`.
+func WithKeySet(set jwk.Set) Option {
+	return option.New(identKeySet{}, set)
+}
+
+// WithKeyProvider specifies the jws.KeyProvider used to look up the
+// keys that verify tokens, in the same way as `jwt.WithKeyProvider()`.
+func WithKeyProvider(p jws.KeyProvider) Option {
+	return option.New(identKeyProvider{}, p)
+}
+
+// WithAutoRefresh specifies that tokens are verified using the key set
+// at `url`, as fetched by `ar`. The URL must be registered with
+// `(jwk.AutoRefresh).Configure()` beforehand. The set is used in the
+// same way as `jwt.WithKeySet()`.
+func WithAutoRefresh(ar *jwk.AutoRefresh, url string) Option {
+	return option.New(identAutoRefresh{}, autoRefreshSource{ar: ar, url: url})
+}
+
+// WithParseOptions specifies options that are passed to `jwt.ParseRequest()`,
+// such as `jwt.WithHeaderKey()`, `jwt.WithFormKey()`, and validation options
+// such as `jwt.WithIssuer()` and `jwt.WithAudience()`. This option can be
+// specified multiple times.
+//
+// The options that specify how tokens are verified must be specified
+// using the options in this package instead.
+func WithParseOptions(options ...jwt.ParseOption) Option {
+	return option.New(identParseOptions{}, options)
+}
+
+// WithValidateOptions specifies options that are used to validate
+// tokens. It is a shorthand for `jwthttp.WithParseOptions()` that only
+// accepts jwt.ValidateOption values.
+func WithValidateOptions(options ...jwt.ValidateOption) Option {
+	list := make([]jwt.ParseOption, len(options))
+	for i, option := range options {
+		list[i] = option
+	}
+	return WithParseOptions(list...)
+}
+
+// WithErrorHandler specifies the ErrorHandler that is called when a
+// request cannot be authenticated. By default, `jwthttp.DefaultErrorHandler("")`
+// is used.
+func WithErrorHandler(h ErrorHandler) Option {
+	return option.New(identErrorHandler{}, h)
+}
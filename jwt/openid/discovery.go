@@ -0,0 +1,258 @@
+package openid
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/lestrrat-go/jwx/internal/json"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/jwx/jws"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
+)
+
+// maxMetadataSize is the maximum number of bytes read from the
+// provider metadata document
+const maxMetadataSize = 1 << 20
+
+const (
+	openIDConfigurationPath = `/.well-known/openid-configuration`
+	oauthServerMetadataPath = `/.well-known/oauth-authorization-server`
+)
+
+// ProviderMetadata represents the metadata of an OpenID Provider, as
+// described in OpenID Connect Discovery 1.0, section 3, or of an OAuth 2.0
+// authorization server, as described in RFC8414, section 2.
+type ProviderMetadata struct {
+	Issuer                                     string                           `json:"issuer"`
+	AuthorizationEndpoint                      string                           `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                              string                           `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                           string                           `json:"userinfo_endpoint,omitempty"`
+	JWKSetURL                                  string                           `json:"jwks_uri,omitempty"`
+	RegistrationEndpoint                       string                           `json:"registration_endpoint,omitempty"`
+	RevocationEndpoint                         string                           `json:"revocation_endpoint,omitempty"`
+	IntrospectionEndpoint                      string                           `json:"introspection_endpoint,omitempty"`
+	EndSessionEndpoint                         string                           `json:"end_session_endpoint,omitempty"`
+	ScopesSupported                            []string                         `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported                     []string                         `json:"response_types_supported,omitempty"`
+	ResponseModesSupported                     []string                         `json:"response_modes_supported,omitempty"`
+	GrantTypesSupported                        []string                         `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported                      []string                         `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported           []jwa.SignatureAlgorithm         `json:"id_token_signing_alg_values_supported,omitempty"`
+	IDTokenEncryptionAlgValuesSupported        []jwa.KeyEncryptionAlgorithm     `json:"id_token_encryption_alg_values_supported,omitempty"`
+	IDTokenEncryptionEncValuesSupported        []jwa.ContentEncryptionAlgorithm `json:"id_token_encryption_enc_values_supported,omitempty"`
+	UserinfoSigningAlgValuesSupported          []jwa.SignatureAlgorithm         `json:"userinfo_signing_alg_values_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported          []string                         `json:"token_endpoint_auth_methods_supported,omitempty"`
+	TokenEndpointAuthSigningAlgValuesSupported []jwa.SignatureAlgorithm         `json:"token_endpoint_auth_signing_alg_values_supported,omitempty"`
+	ClaimsSupported                            []string                         `json:"claims_supported,omitempty"`
+	CodeChallengeMethodsSupported              []string                         `json:"code_challenge_methods_supported,omitempty"`
+}
+
+// Provider holds the metadata of an OpenID Provider obtained by
+// `openid.Discover()`, along with the jwk.AutoRefresh object that
+// fetches the keys published at its "jwks_uri".
+//
+// Provider implements jws.KeyProvider. Keys in the set are used if
+// their "kid" matches the "kid" of the signature (when specified),
+// their "use" is empty or "sig", and their "alg" (when specified)
+// matches the "alg" of the signature. The "alg" of the signature must
+// be listed in "id_token_signing_alg_values_supported" (when specified),
+// and symmetric algorithms are never used, as the keys are published
+// to anyone who can access the URL.
+//
+// If the key referenced by the "kid" of the signature is not found, the
+// key set is refreshed using `(jwk.AutoRefresh).LookupKeyID()`.
+type Provider struct {
+	metadata *ProviderMetadata
+	ar       *jwk.AutoRefresh
+}
+
+// DiscoveryURL returns the URL from which the metadata of `issuer` is
+// fetched. See `openid.WithOAuthServerMetadata()` for the format.
+func DiscoveryURL(issuer string, oauth bool) (string, error) {
+	if !oauth {
+		return strings.TrimSuffix(issuer, `/`) + openIDConfigurationPath, nil
+	}
+
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return "", errors.Wrapf(err, `failed to parse issuer %q`, issuer)
+	}
+	u.Path = oauthServerMetadataPath + strings.TrimSuffix(u.Path, `/`)
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// Discover fetches the metadata of the OpenID Provider identified by
+// `issuer`, and registers its "jwks_uri" with a jwk.AutoRefresh object.
+//
+// The "issuer" in the metadata must be equal to `issuer`, and the
+// metadata must contain "jwks_uri".
+//
+// Unless a jwk.AutoRefresh object is specified via `openid.WithAutoRefresh()`,
+// one is created for the provider. The context object controls its
+// life-span, in the same way as `jwk.NewAutoRefresh()`.
+func Discover(ctx context.Context, issuer string, options ...Option) (*Provider, error) {
+	var httpcl jwk.HTTPClient = http.DefaultClient
+	var ar *jwk.AutoRefresh
+	var refreshOptions []jwk.AutoRefreshOption
+	var oauth bool
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identHTTPClient{}:
+			httpcl = option.Value().(jwk.HTTPClient)
+		case identAutoRefresh{}:
+			ar = option.Value().(*jwk.AutoRefresh)
+		case identRefreshOptions{}:
+			refreshOptions = append(refreshOptions, option.Value().([]jwk.AutoRefreshOption)...)
+		case identOAuthServerMetadata{}:
+			oauth = option.Value().(bool)
+		}
+	}
+
+	u, err := DiscoveryURL(issuer, oauth)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := fetchProviderMetadata(ctx, httpcl, u)
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata.Issuer != issuer {
+		return nil, errors.Errorf(`issuer in provider metadata %q does not match %q`, metadata.Issuer, issuer)
+	}
+
+	if metadata.JWKSetURL == "" {
+		return nil, errors.Errorf(`provider metadata for %q does not contain "jwks_uri"`, issuer)
+	}
+
+	if ar == nil {
+		ar = jwk.NewAutoRefresh(ctx)
+	}
+	ar.Configure(metadata.JWKSetURL, append([]jwk.AutoRefreshOption{jwk.WithHTTPClient(httpcl)}, refreshOptions...)...)
+
+	return &Provider{
+		metadata: metadata,
+		ar:       ar,
+	}, nil
+}
+
+func fetchProviderMetadata(ctx context.Context, httpcl jwk.HTTPClient, u string) (*ProviderMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to create request for %q`, u)
+	}
+	req.Header.Set(`Accept`, `application/json`)
+
+	res, err := httpcl.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch provider metadata from %q`, u)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf(`failed to fetch provider metadata from %q (status = %d)`, u, res.StatusCode)
+	}
+
+	buf, err := ioutil.ReadAll(io.LimitReader(res.Body, maxMetadataSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to read provider metadata from %q`, u)
+	}
+
+	var metadata ProviderMetadata
+	if err := json.Unmarshal(buf, &metadata); err != nil {
+		return nil, errors.Wrapf(err, `failed to parse provider metadata from %q`, u)
+	}
+	return &metadata, nil
+}
+
+// Metadata returns the metadata of the provider
+func (p *Provider) Metadata() *ProviderMetadata {
+	return p.metadata
+}
+
+// KeySet returns the key set published at the "jwks_uri" of the provider
+func (p *Provider) KeySet(ctx context.Context) (jwk.Set, error) {
+	set, err := p.ar.Fetch(ctx, p.metadata.JWKSetURL)
+	if err != nil {
+		return nil, errors.Wrapf(err, `failed to fetch "jwks_uri" %q`, p.metadata.JWKSetURL)
+	}
+	return set, nil
+}
+
+// ParseOptions returns the options to pass to `jwt.Parse()` to verify
+// tokens issued by the provider using its keys, and to validate them
+// including the "iss" claim.
+//
+//	tok, err := jwt.Parse(src, provider.ParseOptions()...)
+func (p *Provider) ParseOptions() []jwt.ParseOption {
+	return []jwt.ParseOption{
+		jwt.WithKeyProvider(p),
+		jwt.WithValidate(true),
+		jwt.WithIssuer(p.metadata.Issuer),
+	}
+}
+
+func (p *Provider) isAllowedAlgorithm(alg jwa.SignatureAlgorithm) bool {
+	switch alg {
+	case jwa.HS256, jwa.HS384, jwa.HS512, jwa.NoSignature, "":
+		return false
+	}
+
+	if len(p.metadata.IDTokenSigningAlgValuesSupported) == 0 {
+		return true
+	}
+	for _, supported := range p.metadata.IDTokenSigningAlgValuesSupported {
+		if supported == alg {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) FetchKeys(ctx context.Context, sig *jws.Signature, _ *jws.Message) ([]jws.KeyCandidate, error) {
+	hdrs := sig.ProtectedHeaders()
+	if hdrs == nil {
+		return nil, nil
+	}
+
+	alg := hdrs.Algorithm()
+	if !p.isAllowedAlgorithm(alg) {
+		return nil, errors.Errorf(`algorithm %q is not allowed for keys of %q`, alg, p.metadata.Issuer)
+	}
+
+	kid := hdrs.KeyID()
+	if kid != "" {
+		// If the key is unknown, this triggers a refresh of the set, in
+		// case the provider has rotated its keys. Whether the key was
+		// found or not is determined below
+		_, _ = p.ar.LookupKeyID(ctx, p.metadata.JWKSetURL, kid)
+	}
+
+	set, err := p.KeySet(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filters := []jwk.KeyFilter{
+		jwk.MatchKeyUsage(jwk.ForSignature),
+		jwk.MatchAlgorithm(alg.String()),
+	}
+	if kid != "" {
+		filters = append(filters, jwk.MatchKeyID(kid))
+	}
+
+	var candidates []jws.KeyCandidate
+	for _, key := range jwk.FindKeys(set, filters...) {
+		candidates = append(candidates, jws.KeyCandidate{Algorithm: alg, Key: key})
+	}
+	return candidates, nil
+}
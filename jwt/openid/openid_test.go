@@ -3,6 +3,8 @@ package openid_test
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 	"time"
@@ -11,9 +13,11 @@ import (
 	"github.com/lestrrat-go/jwx/internal/jwxtest"
 
 	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
 	"github.com/lestrrat-go/jwx/jwt/internal/types"
 	"github.com/lestrrat-go/jwx/jwt/openid"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -373,6 +377,48 @@ func TestOpenIDClaims(t *testing.T) {
 				assert.Equal(t, time.Unix(aLongLongTimeAgo, 0).UTC(), token.UpdatedAt())
 			},
 		},
+		{
+			Value: "n-0S6_WzA2Mj",
+			Key:   openid.NonceKey,
+			Check: func(token openid.Token) {
+				assert.Equal(t, "n-0S6_WzA2Mj", token.Nonce())
+			},
+		},
+		{
+			Value: aLongLongTimeAgoString,
+			Key:   openid.AuthTimeKey,
+			Expected: func(v interface{}) interface{} {
+				var n types.NumericDate
+				if err := n.Accept(v); err != nil {
+					panic(err)
+				}
+				return n.Get()
+			},
+			Check: func(token openid.Token) {
+				assert.Equal(t, time.Unix(aLongLongTimeAgo, 0).UTC(), token.AuthTime())
+			},
+		},
+		{
+			Value: "s6BhdRkqt3",
+			Key:   openid.AuthorizedPartyKey,
+			Check: func(token openid.Token) {
+				assert.Equal(t, "s6BhdRkqt3", token.AuthorizedParty())
+			},
+		},
+		{
+			Value: "77QmUPtjPfzWtF2AnpK9RQ",
+			Key:   openid.AccessTokenHashKey,
+			Check: func(token openid.Token) {
+				assert.Equal(t, "77QmUPtjPfzWtF2AnpK9RQ", token.AccessTokenHash())
+			},
+		},
+		{
+			Value: "LDktKdoQak3Pk0cnXxCltA",
+			Key:   openid.CodeHashKey,
+			Check: func(token openid.Token) {
+				assert.Equal(t, "LDktKdoQak3Pk0cnXxCltA", token.CodeHash())
+			},
+		},
 		{
 			Value: `dummy`,
 			Key:   `dummy`,
@@ -644,3 +690,424 @@ func TestBirthdateClaim(t *testing.T) {
 		}
 	})
 }
+
+func TestTokenHash(t *testing.T) {
+	t.Parallel()
+
+	// at_hash and c_hash are the examples from OpenID Connect Core 1.0,
+	// Appendix A
+	testcases := []struct {
+		Name      string
+		Algorithm jwa.SignatureAlgorithm
+		Curve     jwa.EllipticCurveAlgorithm
+		Value     string
+		Expected  string
+	}{
+		{
+			Name:      "at_hash",
+			Algorithm: jwa.RS256,
+			Value:     "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y",
+			Expected:  "77QmUPtjPfzWtF2AnpK9RQ",
+		},
+		{
+			Name:      "c_hash",
+			Algorithm: jwa.RS256,
+			Value:     "Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk",
+			Expected:  "LDktKdoQak3Pk0cnXxCltA",
+		},
+		{
+			Name:      "EdDSA (Ed25519)",
+			Algorithm: jwa.EdDSA,
+			Curve:     jwa.Ed25519,
+			Value:     "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y",
+			Expected:  "q7nS86GgvvFaZkzALLWqJYaJIKw2wCDAVfCAsm5CrBM",
+		},
+		{
+			Name:      "EdDSA (Ed448)",
+			Algorithm: jwa.EdDSA,
+			Curve:     jwa.Ed448,
+			Value:     "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y",
+			Expected:  "W6Ie3EoycJT-JESJ2WSAWX7LRP3FuHvmvNycBMeL-NngYGhJXChp7YRUBdOXcrKGZD4qnhCAstjO",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			h, err := openid.TokenHash(tc.Algorithm, tc.Curve, tc.Value)
+			if !assert.NoError(t, err, `openid.TokenHash should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, h, `hash should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Unsupported algorithm", func(t *testing.T) {
+		_, err := openid.TokenHash(jwa.NoSignature, "", "foo")
+		if !assert.Error(t, err, `openid.TokenHash should fail`) {
+			return
+		}
+	})
+	t.Run("EdDSA without curve", func(t *testing.T) {
+		_, err := openid.TokenHash(jwa.EdDSA, "", "foo")
+		if !assert.Error(t, err, `openid.TokenHash should fail`) {
+			return
+		}
+	})
+	t.Run("EdDSA with unsupported curve", func(t *testing.T) {
+		_, err := openid.TokenHash(jwa.EdDSA, jwa.P256, "foo")
+		if !assert.Error(t, err, `openid.TokenHash should fail`) {
+			return
+		}
+	})
+}
+
+func TestIDTokenValidator(t *testing.T) {
+	t.Parallel()
+
+	const issuer = "https://server.example.com"
+	const clientID = "s6BhdRkqt3"
+	const accessToken = "jHkWEdUXMU1BwAsC4vtUsZwnNvTIxEl0z9K3vx5KF0Y"
+	const code = "Qcb0Orv1zh30vL1MPRsbm-diHiMwcLyZvn1arpZv-Jxf_11jnpEX3Tgfvk"
+	// at_hash of accessToken, for tokens signed using Ed448
+	const ed448AccessTokenHash = "W6Ie3EoycJT-JESJ2WSAWX7LRP3FuHvmvNycBMeL-NngYGhJXChp7YRUBdOXcrKGZD4qnhCAstjO"
+
+	now := time.Unix(1311281970, 0)
+	clock := jwt.ClockFunc(func() time.Time { return now })
+
+	newToken := func() openid.Token {
+		tok := openid.New()
+		_ = tok.Set(openid.IssuerKey, issuer)
+		_ = tok.Set(openid.SubjectKey, "24400320")
+		_ = tok.Set(openid.AudienceKey, clientID)
+		_ = tok.Set(openid.ExpirationKey, now.Add(10*time.Minute))
+		_ = tok.Set(openid.IssuedAtKey, now)
+		_ = tok.Set(openid.NonceKey, "n-0S6_WzA2Mj")
+		_ = tok.Set(openid.AuthTimeKey, now.Add(-5*time.Minute))
+		_ = tok.Set(openid.AccessTokenHashKey, "77QmUPtjPfzWtF2AnpK9RQ")
+		_ = tok.Set(openid.CodeHashKey, "LDktKdoQak3Pk0cnXxCltA")
+		return tok
+	}
+
+	testcases := []struct {
+		Name    string
+		Modify  func(openid.Token)
+		Options []openid.Option
+		Error   error
+	}{
+		{
+			Name: "Valid token",
+			Options: []openid.Option{
+				openid.WithNonce("n-0S6_WzA2Mj"),
+				openid.WithMaxAge(10 * time.Minute),
+				openid.WithAccessToken(jwa.RS256, accessToken),
+				openid.WithCode(jwa.RS256, code),
+			},
+		},
+		{
+			Name:   "Missing sub",
+			Modify: func(tok openid.Token) { _ = tok.Remove(openid.SubjectKey) },
+			Error:  jwt.ErrMissingRequiredClaim{Name: openid.SubjectKey},
+		},
+		{
+			Name:   "Missing iat",
+			Modify: func(tok openid.Token) { _ = tok.Remove(openid.IssuedAtKey) },
+			Error:  jwt.ErrMissingRequiredClaim{Name: openid.IssuedAtKey},
+		},
+		{
+			Name:   "Wrong issuer",
+			Modify: func(tok openid.Token) { _ = tok.Set(openid.IssuerKey, "https://evil.example.com") },
+			Error:  jwt.ErrInvalidIssuer,
+		},
+		{
+			Name:   "Wrong audience",
+			Modify: func(tok openid.Token) { _ = tok.Set(openid.AudienceKey, "another-client") },
+			Error:  jwt.ErrInvalidAudience,
+		},
+		{
+			Name:   "Multiple audiences without azp",
+			Modify: func(tok openid.Token) { _ = tok.Set(openid.AudienceKey, []string{clientID, "another-client"}) },
+			Error:  jwt.ErrMissingRequiredClaim{Name: openid.AuthorizedPartyKey},
+		},
+		{
+			Name: "Multiple audiences with azp",
+			Modify: func(tok openid.Token) {
+				_ = tok.Set(openid.AudienceKey, []string{clientID, "another-client"})
+				_ = tok.Set(openid.AuthorizedPartyKey, clientID)
+			},
+		},
+		{
+			Name:   "Wrong azp",
+			Modify: func(tok openid.Token) { _ = tok.Set(openid.AuthorizedPartyKey, "another-client") },
+			Error:  openid.ErrInvalidAuthorizedParty,
+		},
+		{
+			Name:    "Wrong nonce",
+			Options: []openid.Option{openid.WithNonce("another-nonce")},
+			Error:   openid.ErrInvalidNonce,
+		},
+		{
+			Name:    "Missing nonce",
+			Modify:  func(tok openid.Token) { _ = tok.Remove(openid.NonceKey) },
+			Options: []openid.Option{openid.WithNonce("n-0S6_WzA2Mj")},
+			Error:   jwt.ErrMissingRequiredClaim{Name: openid.NonceKey},
+		},
+		{
+			Name:    "auth_time too old",
+			Options: []openid.Option{openid.WithMaxAge(time.Minute)},
+			Error:   openid.ErrInvalidAuthTime,
+		},
+		{
+			Name:    "Missing auth_time",
+			Modify:  func(tok openid.Token) { _ = tok.Remove(openid.AuthTimeKey) },
+			Options: []openid.Option{openid.WithMaxAge(10 * time.Minute)},
+			Error:   jwt.ErrMissingRequiredClaim{Name: openid.AuthTimeKey},
+		},
+		{
+			Name:    "Wrong at_hash",
+			Options: []openid.Option{openid.WithAccessToken(jwa.RS256, "another-token")},
+			Error:   openid.ErrInvalidAccessTokenHash,
+		},
+		{
+			Name:    "at_hash uses the hash for the algorithm",
+			Options: []openid.Option{openid.WithAccessToken(jwa.RS512, accessToken)},
+			Error:   openid.ErrInvalidAccessTokenHash,
+		},
+		{
+			Name:    "Missing at_hash",
+			Modify:  func(tok openid.Token) { _ = tok.Remove(openid.AccessTokenHashKey) },
+			Options: []openid.Option{openid.WithAccessToken(jwa.RS256, "another-token")},
+		},
+		{
+			Name:    "Wrong c_hash",
+			Options: []openid.Option{openid.WithCode(jwa.RS256, "another-code")},
+			Error:   openid.ErrInvalidCodeHash,
+		},
+		{
+			Name:    "at_hash for a token signed using Ed448",
+			Modify:  func(tok openid.Token) { _ = tok.Set(openid.AccessTokenHashKey, ed448AccessTokenHash) },
+			Options: []openid.Option{openid.WithAccessToken(jwa.EdDSA, accessToken), openid.WithCurve(jwa.Ed448)},
+		},
+		{
+			Name:    "at_hash uses the hash for the curve",
+			Modify:  func(tok openid.Token) { _ = tok.Set(openid.AccessTokenHashKey, ed448AccessTokenHash) },
+			Options: []openid.Option{openid.WithAccessToken(jwa.EdDSA, accessToken), openid.WithCurve(jwa.Ed25519)},
+			Error:   openid.ErrInvalidAccessTokenHash,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			tok := newToken()
+			if tc.Modify != nil {
+				tc.Modify(tok)
+			}
+
+			err := jwt.Validate(tok, jwt.WithClock(clock), jwt.WithValidator(openid.IDTokenValidator(issuer, clientID, tc.Options...)))
+			if tc.Error == nil {
+				if !assert.NoError(t, err, `jwt.Validate should succeed`) {
+					return
+				}
+				return
+			}
+
+			if !assert.True(t, errors.Is(err, tc.Error), `error should match %s (got %s)`, tc.Error, err) {
+				return
+			}
+			if !assert.True(t, jwt.IsValidationError(err), `jwt.IsValidationError should be true`) {
+				return
+			}
+		})
+	}
+
+	t.Run("Works with jwt.Token", func(t *testing.T) {
+		tok := newToken()
+		signed, err := jwt.Sign(tok, jwa.HS256, []byte("secret"))
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(signed,
+			jwt.WithVerify(jwa.HS256, []byte("secret")),
+			jwt.WithValidate(true),
+			jwt.WithClock(clock),
+			jwt.WithValidator(openid.IDTokenValidator(issuer, clientID, openid.WithMaxAge(time.Minute))),
+		)
+		if !assert.True(t, errors.Is(err, openid.ErrInvalidAuthTime), `error should be openid.ErrInvalidAuthTime (got %s)`, err) {
+			return
+		}
+	})
+}
+
+func TestDiscoveryURL(t *testing.T) {
+	t.Parallel()
+
+	testcases := []struct {
+		Issuer   string
+		OAuth    bool
+		Expected string
+	}{
+		{
+			Issuer:   "https://server.example.com",
+			Expected: "https://server.example.com/.well-known/openid-configuration",
+		},
+		{
+			Issuer:   "https://server.example.com/tenant/",
+			Expected: "https://server.example.com/tenant/.well-known/openid-configuration",
+		},
+		{
+			Issuer:   "https://server.example.com",
+			OAuth:    true,
+			Expected: "https://server.example.com/.well-known/oauth-authorization-server",
+		},
+		{
+			Issuer:   "https://server.example.com/issuer1",
+			OAuth:    true,
+			Expected: "https://server.example.com/.well-known/oauth-authorization-server/issuer1",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(fmt.Sprintf("%s (oauth=%t)", tc.Issuer, tc.OAuth), func(t *testing.T) {
+			u, err := openid.DiscoveryURL(tc.Issuer, tc.OAuth)
+			if !assert.NoError(t, err, `openid.DiscoveryURL should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Expected, u, `URL should match`) {
+				return
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	privkey, err := jwxtest.GenerateRsaJwk()
+	if !assert.NoError(t, err, `jwxtest.GenerateRsaJwk should succeed`) {
+		return
+	}
+	_ = privkey.Set(jwk.KeyIDKey, `my-key`)
+	pubkey, err := jwk.PublicKeyOf(privkey)
+	if !assert.NoError(t, err, `jwk.PublicKeyOf should succeed`) {
+		return
+	}
+
+	var issuer string
+	mux := http.NewServeMux()
+	writeMetadata := func(w http.ResponseWriter, iss string) {
+		w.Header().Set(`Content-Type`, `application/json`)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                iss,
+			"authorization_endpoint":                issuer + "/authorize",
+			"token_endpoint":                        issuer + "/token",
+			"jwks_uri":                              issuer + "/jwks.json",
+			"response_types_supported":              []string{"code", "id_token"},
+			"subject_types_supported":               []string{"public"},
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	}
+	mux.HandleFunc(`/.well-known/openid-configuration`, func(w http.ResponseWriter, _ *http.Request) {
+		writeMetadata(w, issuer)
+	})
+	mux.HandleFunc(`/.well-known/oauth-authorization-server/tenant`, func(w http.ResponseWriter, _ *http.Request) {
+		writeMetadata(w, issuer+"/tenant")
+	})
+	mux.HandleFunc(`/mismatch/.well-known/openid-configuration`, func(w http.ResponseWriter, _ *http.Request) {
+		writeMetadata(w, issuer)
+	})
+	mux.HandleFunc(`/jwks.json`, func(w http.ResponseWriter, _ *http.Request) {
+		set := jwk.NewSet()
+		set.Add(pubkey)
+		w.Header().Set(`Content-Type`, `application/json`)
+		_ = json.NewEncoder(w).Encode(set)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	issuer = srv.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sign := func(t *testing.T, alg jwa.SignatureAlgorithm, key interface{}) []byte {
+		t.Helper()
+		tok := jwt.New()
+		_ = tok.Set(jwt.IssuerKey, issuer)
+		_ = tok.Set(jwt.SubjectKey, `alice`)
+		signed, err := jwt.Sign(tok, alg, key)
+		if !assert.NoError(t, err, `jwt.Sign should succeed`) {
+			t.FailNow()
+		}
+		return signed
+	}
+
+	t.Run("OpenID Connect Discovery", func(t *testing.T) {
+		p, err := openid.Discover(ctx, issuer)
+		if !assert.NoError(t, err, `openid.Discover should succeed`) {
+			return
+		}
+
+		md := p.Metadata()
+		if !assert.Equal(t, issuer, md.Issuer, `issuer should match`) {
+			return
+		}
+		if !assert.Equal(t, issuer+"/jwks.json", md.JWKSetURL, `jwks_uri should match`) {
+			return
+		}
+		if !assert.Equal(t, []jwa.SignatureAlgorithm{jwa.RS256}, md.IDTokenSigningAlgValuesSupported, `algorithms should match`) {
+			return
+		}
+
+		tok, err := jwt.Parse(sign(t, jwa.RS256, privkey), p.ParseOptions()...)
+		if !assert.NoError(t, err, `jwt.Parse should succeed`) {
+			return
+		}
+		if !assert.Equal(t, `alice`, tok.Subject(), `subject should match`) {
+			return
+		}
+
+		_, err = jwt.Parse(sign(t, jwa.HS256, []byte(`secret`)), p.ParseOptions()...)
+		if !assert.Error(t, err, `jwt.Parse should fail for HS256`) {
+			return
+		}
+
+		_, err = jwt.Parse(sign(t, jwa.PS256, privkey), p.ParseOptions()...)
+		if !assert.Error(t, err, `jwt.Parse should fail for algorithms that are not supported by the provider`) {
+			return
+		}
+	})
+	t.Run("OAuth Server Metadata", func(t *testing.T) {
+		ar := jwk.NewAutoRefresh(ctx)
+		p, err := openid.Discover(ctx, issuer+"/tenant", openid.WithOAuthServerMetadata(true), openid.WithAutoRefresh(ar))
+		if !assert.NoError(t, err, `openid.Discover should succeed`) {
+			return
+		}
+		if !assert.Equal(t, issuer+"/tenant", p.Metadata().Issuer, `issuer should match`) {
+			return
+		}
+
+		set, err := ar.Fetch(ctx, issuer+"/jwks.json")
+		if !assert.NoError(t, err, `jwks_uri should be registered with jwk.AutoRefresh`) {
+			return
+		}
+		if !assert.Equal(t, 1, set.Len(), `set should contain one key`) {
+			return
+		}
+	})
+	t.Run("Issuer mismatch", func(t *testing.T) {
+		_, err := openid.Discover(ctx, issuer+"/mismatch")
+		if !assert.Error(t, err, `openid.Discover should fail`) {
+			return
+		}
+	})
+	t.Run("Not found", func(t *testing.T) {
+		_, err := openid.Discover(ctx, issuer+"/notfound")
+		if !assert.Error(t, err, `openid.Discover should fail`) {
+			return
+		}
+	})
+}
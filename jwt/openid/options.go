@@ -0,0 +1,99 @@
+package openid
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
+	"github.com/lestrrat-go/option"
+)
+
+type Option = option.Interface
+
+type identAccessToken struct{}
+type identAutoRefresh struct{}
+type identCode struct{}
+type identCurve struct{}
+type identHTTPClient struct{}
+type identMaxAge struct{}
+type identNonce struct{}
+type identOAuthServerMetadata struct{}
+type identRefreshOptions struct{}
+
+type tokenHashSource struct {
+	alg   jwa.SignatureAlgorithm
+	value string
+}
+
+// WithNonce specifies the value that the "nonce" claim must be equal to.
+// Use it when the authentication request contained a nonce.
+func WithNonce(s string) Option {
+	return option.New(identNonce{}, s)
+}
+
+// WithMaxAge specifies the maximum amount of time that may have elapsed
+// since the end-user authenticated. Use it when the authentication
+// request contained the "max_age" parameter. The "auth_time" claim is
+// required when this option is specified.
+func WithMaxAge(dur time.Duration) Option {
+	return option.New(identMaxAge{}, dur)
+}
+
+// WithAccessToken specifies the access token that was issued along with
+// the ID token. If the ID token contains the "at_hash" claim, its value
+// is compared against the hash of the access token.
+//
+// `alg` must be the "alg" header parameter of the ID token, as it
+// determines the hash function that is used. If `alg` is EdDSA,
+// `openid.WithCurve()` must also be specified.
+func WithAccessToken(alg jwa.SignatureAlgorithm, token string) Option {
+	return option.New(identAccessToken{}, tokenHashSource{alg: alg, value: token})
+}
+
+// WithCode specifies the authorization code that was issued along with
+// the ID token. If the ID token contains the "c_hash" claim, its value
+// is compared against the hash of the code.
+//
+// `alg` must be the "alg" header parameter of the ID token, as it
+// determines the hash function that is used. If `alg` is EdDSA,
+// `openid.WithCurve()` must also be specified.
+func WithCode(alg jwa.SignatureAlgorithm, code string) Option {
+	return option.New(identCode{}, tokenHashSource{alg: alg, value: code})
+}
+
+// WithCurve specifies the curve of the key that signed the ID token
+// (Ed25519 or Ed448). It is required to compare the "at_hash" and
+// "c_hash" claims of ID tokens signed using EdDSA, as the hash function
+// depends on the curve.
+func WithCurve(crv jwa.EllipticCurveAlgorithm) Option {
+	return option.New(identCurve{}, crv)
+}
+
+// WithHTTPClient specifies the HTTP client used to fetch the provider
+// metadata and the key set. By default, http.DefaultClient is used.
+func WithHTTPClient(cl jwk.HTTPClient) Option {
+	return option.New(identHTTPClient{}, cl)
+}
+
+// WithAutoRefresh specifies the jwk.AutoRefresh object with which the
+// "jwks_uri" of the provider is registered. If not specified, one is
+// created by `openid.Discover()`.
+func WithAutoRefresh(ar *jwk.AutoRefresh) Option {
+	return option.New(identAutoRefresh{}, ar)
+}
+
+// WithRefreshOptions specifies the options passed to
+// `(jwk.AutoRefresh).Configure()` when registering the "jwks_uri" of
+// the provider.
+func WithRefreshOptions(options ...jwk.AutoRefreshOption) Option {
+	return option.New(identRefreshOptions{}, options)
+}
+
+// WithOAuthServerMetadata specifies that the metadata should be fetched
+// from the location defined by RFC8414 ("/.well-known/oauth-authorization-server"
+// inserted before the path of the issuer), instead of the location
+// defined by OpenID Connect Discovery 1.0 ("/.well-known/openid-configuration"
+// appended to the issuer).
+func WithOAuthServerMetadata(b bool) Option {
+	return option.New(identOAuthServerMetadata{}, b)
+}
@@ -46,6 +46,11 @@ const (
 	PhoneNumberVerifiedKey = "phone_number_verified"
 	AddressKey             = "address"
 	UpdatedAtKey           = "updated_at"
+	NonceKey               = "nonce"
+	AuthTimeKey            = "auth_time"
+	AuthorizedPartyKey     = "azp"
+	AccessTokenHashKey     = "at_hash"
+	CodeHashKey            = "c_hash"
 )
 
 type Token interface {
@@ -75,6 +80,11 @@ type Token interface {
 	PhoneNumberVerified() bool
 	Address() *AddressClaim
 	UpdatedAt() time.Time
+	Nonce() string
+	AuthTime() time.Time
+	AuthorizedParty() string
+	AccessTokenHash() string
+	CodeHash() string
 	PrivateClaims() map[string]interface{}
 	Get(string) (interface{}, bool)
 	Set(string, interface{}) error
@@ -113,11 +123,16 @@ type stdToken struct {
 	phoneNumberVerified *bool              //
 	address             *AddressClaim      //
 	updatedAt           *types.NumericDate //
+	nonce               *string            // https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+	authTime            *types.NumericDate // https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+	authorizedParty     *string            // https://openid.net/specs/openid-connect-core-1_0.html#IDToken
+	accessTokenHash     *string            // https://openid.net/specs/openid-connect-core-1_0.html#CodeIDToken
+	codeHash            *string            // https://openid.net/specs/openid-connect-core-1_0.html#HybridIDToken
 	privateClaims       map[string]interface{}
 }
 
 // New creates a standard token, with minimal knowledge of
-// possible claims. Standard claims include"aud", "exp", "iat", "iss", "jti", "nbf", "sub", "name", "given_name", "middle_name", "family_name", "nickname", "preferred_username", "profile", "picture", "website", "email", "email_verified", "gender", "birthdate", "zoneinfo", "locale", "phone_number", "phone_number_verified", "address" and "updated_at".
+// possible claims. Standard claims include"aud", "exp", "iat", "iss", "jti", "nbf", "sub", "name", "given_name", "middle_name", "family_name", "nickname", "preferred_username", "profile", "picture", "website", "email", "email_verified", "gender", "birthdate", "zoneinfo", "locale", "phone_number", "phone_number_verified", "address", "updated_at", "nonce", "auth_time", "azp", "at_hash" and "c_hash".
 // Convenience accessors are provided for these standard claims
 func New() Token {
 	return &stdToken{
@@ -286,6 +301,36 @@ func (t *stdToken) Get(name string) (interface{}, bool) {
 		}
 		v := t.updatedAt.Get()
 		return v, true
+	case NonceKey:
+		if t.nonce == nil {
+			return nil, false
+		}
+		v := *(t.nonce)
+		return v, true
+	case AuthTimeKey:
+		if t.authTime == nil {
+			return nil, false
+		}
+		v := t.authTime.Get()
+		return v, true
+	case AuthorizedPartyKey:
+		if t.authorizedParty == nil {
+			return nil, false
+		}
+		v := *(t.authorizedParty)
+		return v, true
+	case AccessTokenHashKey:
+		if t.accessTokenHash == nil {
+			return nil, false
+		}
+		v := *(t.accessTokenHash)
+		return v, true
+	case CodeHashKey:
+		if t.codeHash == nil {
+			return nil, false
+		}
+		v := *(t.codeHash)
+		return v, true
 	default:
 		v, ok := t.privateClaims[name]
 		return v, ok
@@ -348,6 +393,16 @@ func (t *stdToken) Remove(key string) error {
 		t.address = nil
 	case UpdatedAtKey:
 		t.updatedAt = nil
+	case NonceKey:
+		t.nonce = nil
+	case AuthTimeKey:
+		t.authTime = nil
+	case AuthorizedPartyKey:
+		t.authorizedParty = nil
+	case AccessTokenHashKey:
+		t.accessTokenHash = nil
+	case CodeHashKey:
+		t.codeHash = nil
 	default:
 		delete(t.privateClaims, key)
 	}
@@ -537,6 +592,37 @@ func (t *stdToken) setNoLock(name string, value interface{}) error {
 		}
 		t.updatedAt = &acceptor
 		return nil
+	case NonceKey:
+		if v, ok := value.(string); ok {
+			t.nonce = &v
+			return nil
+		}
+		return errors.Errorf(`invalid value for %s key: %T`, NonceKey, value)
+	case AuthTimeKey:
+		var acceptor types.NumericDate
+		if err := acceptor.Accept(value); err != nil {
+			return errors.Wrapf(err, `invalid value for %s key`, AuthTimeKey)
+		}
+		t.authTime = &acceptor
+		return nil
+	case AuthorizedPartyKey:
+		if v, ok := value.(string); ok {
+			t.authorizedParty = &v
+			return nil
+		}
+		return errors.Errorf(`invalid value for %s key: %T`, AuthorizedPartyKey, value)
+	case AccessTokenHashKey:
+		if v, ok := value.(string); ok {
+			t.accessTokenHash = &v
+			return nil
+		}
+		return errors.Errorf(`invalid value for %s key: %T`, AccessTokenHashKey, value)
+	case CodeHashKey:
+		if v, ok := value.(string); ok {
+			t.codeHash = &v
+			return nil
+		}
+		return errors.Errorf(`invalid value for %s key: %T`, CodeHashKey, value)
 	default:
 		if t.privateClaims == nil {
 			t.privateClaims = map[string]interface{}{}
@@ -774,6 +860,51 @@ func (t *stdToken) UpdatedAt() time.Time {
 	return time.Time{}
 }
 
+func (t *stdToken) Nonce() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.nonce != nil {
+		return *(t.nonce)
+	}
+	return ""
+}
+
+func (t *stdToken) AuthTime() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.authTime != nil {
+		return t.authTime.Get()
+	}
+	return time.Time{}
+}
+
+func (t *stdToken) AuthorizedParty() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.authorizedParty != nil {
+		return *(t.authorizedParty)
+	}
+	return ""
+}
+
+func (t *stdToken) AccessTokenHash() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.accessTokenHash != nil {
+		return *(t.accessTokenHash)
+	}
+	return ""
+}
+
+func (t *stdToken) CodeHash() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.codeHash != nil {
+		return *(t.codeHash)
+	}
+	return ""
+}
+
 func (t *stdToken) PrivateClaims() map[string]interface{} {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
@@ -784,7 +915,7 @@ func (t *stdToken) makePairs() []*ClaimPair {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
 
-	pairs := make([]*ClaimPair, 0, 26)
+	pairs := make([]*ClaimPair, 0, 31)
 	if t.audience != nil {
 		v := t.audience.Get()
 		pairs = append(pairs, &ClaimPair{Key: AudienceKey, Value: v})
@@ -889,6 +1020,26 @@ func (t *stdToken) makePairs() []*ClaimPair {
 		v := t.updatedAt.Get()
 		pairs = append(pairs, &ClaimPair{Key: UpdatedAtKey, Value: v})
 	}
+	if t.nonce != nil {
+		v := *(t.nonce)
+		pairs = append(pairs, &ClaimPair{Key: NonceKey, Value: v})
+	}
+	if t.authTime != nil {
+		v := t.authTime.Get()
+		pairs = append(pairs, &ClaimPair{Key: AuthTimeKey, Value: v})
+	}
+	if t.authorizedParty != nil {
+		v := *(t.authorizedParty)
+		pairs = append(pairs, &ClaimPair{Key: AuthorizedPartyKey, Value: v})
+	}
+	if t.accessTokenHash != nil {
+		v := *(t.accessTokenHash)
+		pairs = append(pairs, &ClaimPair{Key: AccessTokenHashKey, Value: v})
+	}
+	if t.codeHash != nil {
+		v := *(t.codeHash)
+		pairs = append(pairs, &ClaimPair{Key: CodeHashKey, Value: v})
+	}
 	for k, v := range t.privateClaims {
 		pairs = append(pairs, &ClaimPair{Key: k, Value: v})
 	}
@@ -927,6 +1078,11 @@ func (t *stdToken) UnmarshalJSON(buf []byte) error {
 	t.phoneNumberVerified = nil
 	t.address = nil
 	t.updatedAt = nil
+	t.nonce = nil
+	t.authTime = nil
+	t.authorizedParty = nil
+	t.accessTokenHash = nil
+	t.codeHash = nil
 	dec := json.NewDecoder(bytes.NewReader(buf))
 LOOP:
 	for {
@@ -1067,6 +1223,28 @@ LOOP:
 					return errors.Wrapf(err, `failed to decode value for key %s`, UpdatedAtKey)
 				}
 				t.updatedAt = &decoded
+			case NonceKey:
+				if err := json.AssignNextStringToken(&t.nonce, dec); err != nil {
+					return errors.Wrapf(err, `failed to decode value for key %s`, NonceKey)
+				}
+			case AuthTimeKey:
+				var decoded types.NumericDate
+				if err := dec.Decode(&decoded); err != nil {
+					return errors.Wrapf(err, `failed to decode value for key %s`, AuthTimeKey)
+				}
+				t.authTime = &decoded
+			case AuthorizedPartyKey:
+				if err := json.AssignNextStringToken(&t.authorizedParty, dec); err != nil {
+					return errors.Wrapf(err, `failed to decode value for key %s`, AuthorizedPartyKey)
+				}
+			case AccessTokenHashKey:
+				if err := json.AssignNextStringToken(&t.accessTokenHash, dec); err != nil {
+					return errors.Wrapf(err, `failed to decode value for key %s`, AccessTokenHashKey)
+				}
+			case CodeHashKey:
+				if err := json.AssignNextStringToken(&t.codeHash, dec); err != nil {
+					return errors.Wrapf(err, `failed to decode value for key %s`, CodeHashKey)
+				}
 			default:
 				if dc := t.dc; dc != nil {
 					if localReg := dc.Registry(); localReg != nil {
@@ -1112,7 +1290,7 @@ func (t stdToken) MarshalJSON() ([]byte, error) {
 				return nil, errors.Wrap(err, `failed to encode "aud"`)
 			}
 			continue
-		case ExpirationKey, IssuedAtKey, NotBeforeKey, UpdatedAtKey:
+		case ExpirationKey, IssuedAtKey, NotBeforeKey, UpdatedAtKey, AuthTimeKey:
 			enc.Encode(pair.Value.(time.Time).Unix())
 			continue
 		}
@@ -0,0 +1,263 @@
+package openid
+
+import (
+	"context"
+	"crypto"
+	"crypto/subtle"
+	"time"
+
+	"github.com/lestrrat-go/jwx/internal/base64"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/lestrrat-go/jwx/jwt/internal/types"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/sha3"
+
+	// Register the hash functions used by TokenHash
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Errors that describe why an ID token failed validation. Errors
+// returned from the validator created by `openid.IDTokenValidator()`
+// are jwt.ValidationError objects that wrap one of these values, or
+// one of the errors defined in the jwt package.
+var (
+	ErrInvalidNonce           = errors.New(`nonce not satisfied`)
+	ErrInvalidAuthorizedParty = errors.New(`azp not satisfied`)
+	ErrInvalidAuthTime        = errors.New(`auth_time not satisfied`)
+	ErrInvalidAccessTokenHash = errors.New(`at_hash not satisfied`)
+	ErrInvalidCodeHash        = errors.New(`c_hash not satisfied`)
+)
+
+// TokenHash computes the value of the "at_hash" or "c_hash" claim for
+// `value`, which is either an access token or an authorization code,
+// as described in OpenID Connect Core 1.0, section 3.1.3.6.
+//
+// The hash function is the one used by the signature algorithm `alg`:
+// SHA-256 for RS256, ES256, ES256K, PS256, and HS256, SHA-384 for
+// RS384, ES384, PS384, and HS384, and SHA-512 for RS512, ES512, PS512,
+// and HS512.
+//
+// For EdDSA, the hash function depends on the curve of the key that
+// signed the token, which must be given as `crv`: SHA-512 for Ed25519,
+// and SHAKE256 with a 114 byte output for Ed448. `crv` is ignored for
+// other algorithms.
+func TokenHash(alg jwa.SignatureAlgorithm, crv jwa.EllipticCurveAlgorithm, value string) (string, error) {
+	var sum []byte
+	switch alg {
+	case jwa.RS256, jwa.ES256, jwa.ES256K, jwa.PS256, jwa.HS256:
+		sum = hashSum(crypto.SHA256, value)
+	case jwa.RS384, jwa.ES384, jwa.PS384, jwa.HS384:
+		sum = hashSum(crypto.SHA384, value)
+	case jwa.RS512, jwa.ES512, jwa.PS512, jwa.HS512:
+		sum = hashSum(crypto.SHA512, value)
+	case jwa.EdDSA:
+		switch crv {
+		case jwa.Ed25519:
+			sum = hashSum(crypto.SHA512, value)
+		case jwa.Ed448:
+			sum = make([]byte, 114)
+			sha3.ShakeSum256(sum, []byte(value))
+		case "":
+			return "", errors.New(`curve must be specified to compute token hash for EdDSA`)
+		default:
+			return "", errors.Errorf(`unsupported curve for token hash: %s`, crv)
+		}
+	default:
+		return "", errors.Errorf(`unsupported signature algorithm for token hash: %s`, alg)
+	}
+
+	return base64.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+func hashSum(h crypto.Hash, value string) []byte {
+	hh := h.New()
+	hh.Write([]byte(value))
+	return hh.Sum(nil)
+}
+
+// IDTokenValidator returns a jwt.Validator that validates an ID token
+// as described in OpenID Connect Core 1.0, section 3.1.3.7. Use it with
+// `jwt.WithValidator()`.
+//
+// The validator checks that
+//
+//   * the "iss", "sub", "aud", "exp", and "iat" claims are present
+//   * the "iss" claim is equal to `issuer`, unless `issuer` is empty
+//   * the "aud" claim contains `clientID`
+//   * the "azp" claim is present if "aud" contains multiple values,
+//     and is equal to `clientID` if present
+//   * the "nonce" claim matches the value given by `openid.WithNonce()`
+//   * the "auth_time" claim is within the duration given by
+//     `openid.WithMaxAge()`
+//   * the "at_hash" and "c_hash" claims, if present, match the values
+//     given by `openid.WithAccessToken()` and `openid.WithCode()`
+//
+// The validity of "exp" and "iat" is checked by `jwt.Validate()` by
+// default, and therefore not by this validator. Flows that require
+// "at_hash" or "c_hash" to be present should additionally use
+// `jwt.WithRequiredClaim()`.
+//
+// The token may be an openid.Token or any other jwt.Token.
+func IDTokenValidator(issuer, clientID string, options ...Option) jwt.Validator {
+	v := &idTokenValidator{
+		issuer:   issuer,
+		clientID: clientID,
+	}
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case identNonce{}:
+			v.nonce = option.Value().(string)
+			v.hasNonce = true
+		case identMaxAge{}:
+			v.maxAge = option.Value().(time.Duration)
+			v.hasMaxAge = true
+		case identAccessToken{}:
+			src := option.Value().(tokenHashSource)
+			v.accessToken = &src
+		case identCode{}:
+			src := option.Value().(tokenHashSource)
+			v.code = &src
+		case identCurve{}:
+			v.crv = option.Value().(jwa.EllipticCurveAlgorithm)
+		}
+	}
+	return v
+}
+
+type idTokenValidator struct {
+	issuer      string
+	clientID    string
+	nonce       string
+	hasNonce    bool
+	maxAge      time.Duration
+	hasMaxAge   bool
+	accessToken *tokenHashSource
+	code        *tokenHashSource
+	crv         jwa.EllipticCurveAlgorithm
+}
+
+func (v *idTokenValidator) Validate(ctx context.Context, t jwt.Token) error {
+	validators := []jwt.Validator{
+		jwt.IsRequired(IssuerKey),
+		jwt.IsRequired(SubjectKey),
+		jwt.IsRequired(AudienceKey),
+		jwt.IsRequired(ExpirationKey),
+		jwt.IsRequired(IssuedAtKey),
+	}
+	if v.issuer != "" {
+		validators = append(validators, jwt.ClaimValueIs(IssuerKey, v.issuer))
+	}
+	validators = append(validators,
+		jwt.ClaimContainsString(AudienceKey, v.clientID),
+		jwt.ValidatorFunc(v.validateAuthorizedParty),
+	)
+	if v.hasNonce {
+		validators = append(validators, jwt.ValidatorFunc(v.validateNonce))
+	}
+	if v.hasMaxAge {
+		validators = append(validators, jwt.ValidatorFunc(v.validateAuthTime))
+	}
+	if v.accessToken != nil {
+		validators = append(validators, tokenHashValidator(AccessTokenHashKey, *v.accessToken, v.crv, ErrInvalidAccessTokenHash))
+	}
+	if v.code != nil {
+		validators = append(validators, tokenHashValidator(CodeHashKey, *v.code, v.crv, ErrInvalidCodeHash))
+	}
+
+	for _, validator := range validators {
+		if err := validator.Validate(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *idTokenValidator) validateAuthorizedParty(_ context.Context, t jwt.Token) error {
+	azp, ok := stringClaim(t, AuthorizedPartyKey)
+	if !ok {
+		if len(t.Audience()) > 1 {
+			return jwt.NewValidationError(AuthorizedPartyKey, jwt.ErrMissingRequiredClaim{Name: AuthorizedPartyKey})
+		}
+		return nil
+	}
+
+	if azp != v.clientID {
+		verr := jwt.NewValidationError(AuthorizedPartyKey, ErrInvalidAuthorizedParty)
+		verr.Expected = v.clientID
+		verr.Actual = azp
+		return verr
+	}
+	return nil
+}
+
+func (v *idTokenValidator) validateNonce(_ context.Context, t jwt.Token) error {
+	nonce, ok := stringClaim(t, NonceKey)
+	if !ok {
+		return jwt.NewValidationError(NonceKey, jwt.ErrMissingRequiredClaim{Name: NonceKey})
+	}
+
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(v.nonce)) != 1 {
+		verr := jwt.NewValidationError(NonceKey, ErrInvalidNonce)
+		verr.Expected = v.nonce
+		verr.Actual = nonce
+		return verr
+	}
+	return nil
+}
+
+func (v *idTokenValidator) validateAuthTime(ctx context.Context, t jwt.Token) error {
+	raw, ok := t.Get(AuthTimeKey)
+	if !ok {
+		return jwt.NewValidationError(AuthTimeKey, jwt.ErrMissingRequiredClaim{Name: AuthTimeKey})
+	}
+
+	var authTime types.NumericDate
+	if err := authTime.Accept(raw); err != nil {
+		return jwt.NewValidationError(AuthTimeKey, errors.Wrap(err, `invalid auth_time`))
+	}
+
+	now := jwt.ValidationCtxClock(ctx).Now().Truncate(time.Second)
+	elapsed := now.Sub(authTime.Truncate(time.Second))
+	if elapsed > v.maxAge+jwt.ValidationCtxSkew(ctx) {
+		verr := jwt.NewValidationError(AuthTimeKey, ErrInvalidAuthTime)
+		verr.Expected = v.maxAge
+		verr.Actual = elapsed
+		return verr
+	}
+	return nil
+}
+
+func tokenHashValidator(name string, src tokenHashSource, crv jwa.EllipticCurveAlgorithm, sentinel error) jwt.Validator {
+	return jwt.ValidatorFunc(func(_ context.Context, t jwt.Token) error {
+		actual, ok := stringClaim(t, name)
+		if !ok {
+			return nil
+		}
+
+		expected, err := TokenHash(src.alg, crv, src.value)
+		if err != nil {
+			return jwt.NewValidationError(name, errors.Wrapf(err, `failed to compute %s`, name))
+		}
+
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) != 1 {
+			verr := jwt.NewValidationError(name, sentinel)
+			verr.Expected = expected
+			verr.Actual = actual
+			return verr
+		}
+		return nil
+	})
+}
+
+func stringClaim(t jwt.Token, name string) (string, bool) {
+	v, ok := t.Get(name)
+	if !ok {
+		return "", false
+	}
+	// Values that are not strings never match
+	s, _ := v.(string)
+	return s, true
+}
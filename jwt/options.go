@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	"context"
 	"time"
 
 	"github.com/lestrrat-go/jwx/jwa"
@@ -111,6 +112,8 @@ type identAcceptableSkew struct{}
 type identAudience struct{}
 type identClaim struct{}
 type identClock struct{}
+type identCollectAllErrors struct{}
+type identContext struct{}
 type identDecrypt struct{}
 type identDefault struct{}
 type identFlattenAudience struct{}
@@ -118,18 +121,25 @@ type identIssuer struct{}
 type identJweHeaders struct{}
 type identJwsHeaders struct{}
 type identJwtid struct{}
+type identKeyProvider struct{}
 type identKeySet struct{}
 type identPedantic struct{}
+type identRequireSignature struct{}
 type identRequiredClaim struct{}
+type identResetDefaultValidators struct{}
 type identSubject struct{}
 type identTimeDelta struct{}
 type identToken struct{}
 type identTypedClaim struct{}
 type identValidate struct{}
+type identValidator struct{}
 type identVerify struct{}
 
 type identHeaderKey struct{}
 type identFormKey struct{}
+type identCookieKey struct{}
+type identQueryKey struct{}
+type identTokenExtractor struct{}
 
 type VerifyParameters interface {
 	Algorithm() jwa.SignatureAlgorithm
@@ -177,10 +187,32 @@ func WithKeySet(set jwk.Set) ParseOption {
 	return newParseOption(identKeySet{}, set)
 }
 
+// WithKeyProvider forces the Parse method to verify the JWT message
+// using the keys returned by the given jws.KeyProvider. Unlike
+// `jwt.WithKeySet()`, the provider is consulted for each signature in
+// the message, and may return multiple candidate keys.
+//
+// This option cannot be used together with `jwt.WithVerify()` or
+// `jwt.WithKeySet()`.
+func WithKeyProvider(p jws.KeyProvider) ParseOption {
+	return newParseOption(identKeyProvider{}, p)
+}
+
+// WithContext specifies the context.Context object that is passed to
+// the jws.KeyProvider specified by `jwt.WithKeyProvider()`, and to
+// the validators that are run by `jwt.Validate()`. If not specified,
+// `context.Background()` is used.
+func WithContext(ctx context.Context) ValidateOption {
+	return newValidateOption(identContext{}, ctx)
+}
+
 // UseDefaultKey is used in conjunction with the option WithKeySet
 // to instruct the Parse method to default to the single key in a key
 // set when no Key ID is included in the JWT. If the key set contains
 // multiple keys then the behaviour is unchanged.
+//
+// As with keys that are looked up by their Key ID, the default key is
+// not used if its "use" field is set to a value other than "sig".
 func UseDefaultKey(value bool) ParseOption {
 	return newParseOption(identDefault{}, value)
 }
@@ -269,6 +301,43 @@ func WithClaimValue(name string, v interface{}) ValidateOption {
 	return newValidateOption(identClaim{}, claimValue{name, v})
 }
 
+// WithValidator specifies a Validator to be run against the token,
+// in addition to the built-in checks. The option may be specified
+// multiple times, in which case the validators are run in the order
+// that they were specified.
+//
+// The built-in checks are also available as validators (e.g.
+// `jwt.IsExpirationValid()`, `jwt.ClaimValueIs()`), so that they
+// may be composed with custom validators.
+func WithValidator(v Validator) ValidateOption {
+	return newValidateOption(identValidator{}, v)
+}
+
+// WithResetDefaultValidators specifies that the validators that
+// `jwt.Validate()` runs by default, namely `jwt.IsExpirationValid()`,
+// `jwt.IsIssuedAtValid()`, and `jwt.IsNbfValid()`, should not be run.
+// Use this when you want to replace them, or run them in a different
+// order, using `jwt.WithValidator()`.
+//
+// Only these three checks are affected. Checks that are configured
+// through other options, such as `jwt.WithIssuer()`, `jwt.WithRequiredClaim()`,
+// and `jwt.WithMaxDelta()`, are always performed before the validators
+// passed via `jwt.WithValidator()`. To control their order, use the
+// equivalent validators (e.g. `jwt.ClaimValueIs()`, `jwt.IsRequired()`)
+// instead of the options.
+func WithResetDefaultValidators(b bool) ValidateOption {
+	return newValidateOption(identResetDefaultValidators{}, b)
+}
+
+// WithCollectAllErrors specifies that `jwt.Validate()` should run all
+// of the configured checks, instead of returning the first error that
+// it encounters. When one or more checks fail, the returned error is
+// a `jwt.ValidationErrors`, which lists the failed claims along with
+// their expected and actual values.
+func WithCollectAllErrors(b bool) ValidateOption {
+	return newValidateOption(identCollectAllErrors{}, b)
+}
+
 // WithHeaderKey is used to specify header keys to search for tokens.
 //
 // While the type system allows this option to be passed to jwt.Parse() directly,
@@ -285,6 +354,31 @@ func WithFormKey(v string) ParseRequestOption {
 	return &httpParseOption{newParseOption(identFormKey{}, v)}
 }
 
+// WithCookieKey is used to specify cookie names to search for tokens.
+//
+// While the type system allows this option to be passed to jwt.Parse() directly,
+// doing so will have no effect. Only use it for HTTP request parsing functions
+func WithCookieKey(v string) ParseRequestOption {
+	return &httpParseOption{newParseOption(identCookieKey{}, v)}
+}
+
+// WithQueryKey is used to specify query parameter names to search for tokens.
+//
+// While the type system allows this option to be passed to jwt.Parse() directly,
+// doing so will have no effect. Only use it for HTTP request parsing functions
+func WithQueryKey(v string) ParseRequestOption {
+	return &httpParseOption{newParseOption(identQueryKey{}, v)}
+}
+
+// WithTokenExtractor is used to specify a custom location to search for
+// tokens. Custom extractors are searched after all other locations.
+//
+// While the type system allows this option to be passed to jwt.Parse() directly,
+// doing so will have no effect. Only use it for HTTP request parsing functions
+func WithTokenExtractor(v TokenExtractor) ParseRequestOption {
+	return &httpParseOption{newParseOption(identTokenExtractor{}, v)}
+}
+
 // WithFlattenAudience specifies if the "aud" claim should be flattened
 // to a single string upon the token being serialized to JSON.
 //
@@ -344,6 +438,15 @@ type delta struct {
 	less bool // if true, d =< c1 - c2. otherwise d >= c1 - c2
 }
 
+// claim returns the name of the claim that is reported when
+// the delta is not satisfied
+func (d delta) claim() string {
+	if d.c1 != "" {
+		return d.c1
+	}
+	return d.c2
+}
+
 // WithMaxDelta specifies that given two claims `c1` and `c2` that represent time, the difference in
 // time.Duration must be less than equal to the value specified by `d`. If `c1` or `c2` is the
 // empty string, the current time (as computed by `time.Now` or the object passed via
@@ -423,3 +526,12 @@ func WithDecrypt(alg jwa.KeyEncryptionAlgorithm, key interface{}) ParseOption {
 func WithPedantic(v bool) ParseOption {
 	return newParseOption(identPedantic{}, v)
 }
+
+// WithRequireSignature specifies that `jwt.Parse()` should fail unless
+// the token is, or contains, a JWS message that was verified using
+// `jwt.WithVerify()`, `jwt.WithKeySet()`, or `jwt.WithKeyProvider()`.
+// Without it, tokens that are not signed (e.g. a raw JSON object) are
+// parsed as is, even if one of these options is specified.
+func WithRequireSignature(v bool) ParseOption {
+	return newParseOption(identRequireSignature{}, v)
+}
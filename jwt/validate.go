@@ -1,13 +1,166 @@
 package jwt
 
 import (
+	"context"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
+// Errors that describe why a token failed validation. Errors returned
+// from `jwt.Validate()` (and `jwt.Parse()` when `jwt.WithValidate(true)`
+// is specified) wrap one of these values, and can be tested using
+// `errors.Is()`
+var (
+	ErrTokenExpired      = errors.New(`exp not satisfied`)
+	ErrTokenNotYetValid  = errors.New(`nbf not satisfied`)
+	ErrInvalidIssuedAt   = errors.New(`iat not satisfied`)
+	ErrInvalidIssuer     = errors.New(`iss not satisfied`)
+	ErrInvalidSubject    = errors.New(`sub not satisfied`)
+	ErrInvalidAudience   = errors.New(`aud not satisfied`)
+	ErrInvalidJwtID      = errors.New(`jti not satisfied`)
+	ErrInvalidTimeDelta  = errors.New(`time delta not satisfied`)
+	ErrInvalidClaimValue = errors.New(`claim value not satisfied`)
+)
+
+// ErrMissingRequiredClaim is the error used when a claim that is
+// required by `jwt.WithRequiredClaim()` (or implicitly required by
+// other validation options) does not exist in the token.
+//
+// When used as the target of `errors.Is()`, an ErrMissingRequiredClaim
+// with an empty Name matches any missing claim.
+type ErrMissingRequiredClaim struct {
+	Name string
+}
+
+func (e ErrMissingRequiredClaim) Error() string {
+	return `required claim ` + e.Name + ` was not found`
+}
+
+func (e ErrMissingRequiredClaim) Is(target error) bool {
+	t, ok := target.(ErrMissingRequiredClaim)
+	return ok && (t.Name == "" || t.Name == e.Name)
+}
+
+// ValidationError is the type of error returned by `jwt.Validate()`
+// when a token does not satisfy one of the validation constraints.
+// Claim is the name of the claim that failed validation. The
+// underlying reason can be inspected using `errors.Is()` or `errors.As()`.
+//
+// Expected and Actual hold the values that were compared, when available.
+// Actual is nil if the claim does not exist in the token. For checks
+// against the current time, Expected is the time that the claim was
+// compared to, and for time deltas, Expected is the configured duration
+// and Actual is the computed difference.
+type ValidationError struct {
+	Claim    string
+	Expected interface{}
+	Actual   interface{}
+	err      error
+	message  string
+}
+
+// NewValidationError creates a ValidationError for the claim `claim`,
+// wrapping `err`. It can be used by custom validators to report errors
+// in the same way as the built-in validators. Expected and Actual may
+// be set on the returned object.
+func NewValidationError(claim string, err error) *ValidationError {
+	return newValidationError(claim, err)
+}
+
+func newValidationError(claim string, err error) *ValidationError {
+	return &ValidationError{Claim: claim, err: err}
+}
+
+func (e *ValidationError) withValues(expected, actual interface{}) *ValidationError {
+	e.Expected = expected
+	e.Actual = actual
+	return e
+}
+
+func newValidationErrorf(claim string, err error, f string, args ...interface{}) *ValidationError {
+	return &ValidationError{Claim: claim, err: err, message: fmt.Sprintf(f, args...)}
+}
+
+func (e *ValidationError) Error() string {
+	if e.message != "" {
+		return e.message
+	}
+	return e.err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// ValidationErrors is the error returned by `jwt.Validate()` when
+// `jwt.WithCollectAllErrors(true)` is specified, and the token fails
+// one or more checks. It holds an error for each failed check, in the
+// order that the checks were run.
+//
+// `errors.Is()` and `errors.As()` match if any of the errors matches.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, `token failed %d validation check(s): `, len(e))
+	for i, err := range e {
+		if i > 0 {
+			buf.WriteString(`; `)
+		}
+		if err.Claim != "" {
+			fmt.Fprintf(&buf, `%s: `, err.Claim)
+		}
+		buf.WriteString(err.Error())
+		if err.Expected != nil || err.Actual != nil {
+			fmt.Fprintf(&buf, ` (expected %s, actual %s)`, formatValidationValue(err.Expected), formatValidationValue(err.Actual))
+		}
+	}
+	return buf.String()
+}
+
+func (e ValidationErrors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e ValidationErrors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatValidationValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return `(none)`
+	case string:
+		return strconv.Quote(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339)
+	default:
+		return fmt.Sprintf(`%v`, v)
+	}
+}
+
+// IsValidationError returns true if the error was caused by a token
+// failing validation, as opposed to, for example, failures in parsing
+// the token, verifying its signature, or decrypting it.
+func IsValidationError(err error) bool {
+	var verr *ValidationError
+	return errors.As(err, &verr)
+}
+
 type Clock interface {
 	Now() time.Time
 }
@@ -39,23 +192,59 @@ func timeClaim(t Token, clock Clock, c string) time.Time {
 	return time.Time{} // should *NEVER* reach here, but...
 }
 
+// addClaimValue adds `claim` to `list`, keeping the order in which the
+// options were specified. If the claim is specified more than once, the
+// last value is used
+func addClaimValue(list []claimValue, claim claimValue) []claimValue {
+	for i, existing := range list {
+		if existing.name == claim.name {
+			list[i] = claim
+			return list
+		}
+	}
+	return append(list, claim)
+}
+
 // Validate makes sure that the essential claims stand.
 //
 // See the various `WithXXX` functions for optional parameters
 // that can control the behavior of this method.
+//
+// The checks are run in the following order: required claims, time
+// deltas, "iss", "jti", "sub", "aud", the default checks, claim values,
+// and finally the validators passed via `jwt.WithValidator()`, in the
+// order that they were specified.
+//
+// Unless `jwt.WithResetDefaultValidators(true)` is specified, the default
+// checks are run, which check the "exp", "iat", and "nbf" claims using
+// `jwt.IsExpirationValid()`, `jwt.IsIssuedAtValid()`, and `jwt.IsNbfValid()`.
 func Validate(t Token, options ...ValidateOption) error {
+	ctx := context.Background()
 	var issuer string
 	var subject string
 	var audience string
 	var jwtid string
 	var clock Clock = ClockFunc(time.Now)
 	var skew time.Duration
-	var deltas []delta
+	var resetDefaultValidators bool
+	var collectAll bool
+	var deltas []Validator
+	var custom []Validator
+	var required []string
 	requiredMap := make(map[string]struct{})
-	claimValues := make(map[string]interface{})
+	var claimValues []claimValue
+	addRequired := func(name string) {
+		if _, ok := requiredMap[name]; ok {
+			return
+		}
+		requiredMap[name] = struct{}{}
+		required = append(required, name)
+	}
 	for _, o := range options {
 		//nolint:forcetypeassert
 		switch o.Ident() {
+		case identContext{}:
+			ctx = o.Value().(context.Context)
 		case identClock{}:
 			clock = o.Value().(Clock)
 		case identAcceptableSkew{}:
@@ -69,120 +258,73 @@ func Validate(t Token, options ...ValidateOption) error {
 		case identJwtid{}:
 			jwtid = o.Value().(string)
 		case identRequiredClaim{}:
-			requiredMap[o.Value().(string)] = struct{}{}
+			addRequired(o.Value().(string))
 		case identTimeDelta{}:
 			d := o.Value().(delta)
-			deltas = append(deltas, d)
+			deltas = append(deltas, &deltaValidator{delta: d})
 			if d.c1 != "" {
 				if err := isSupportedTimeClaim(d.c1); err != nil {
 					return err
 				}
-				requiredMap[d.c1] = struct{}{}
 			}
 
 			if d.c2 != "" {
 				if err := isSupportedTimeClaim(d.c2); err != nil {
 					return err
 				}
-				requiredMap[d.c2] = struct{}{}
 			}
 		case identClaim{}:
-			claim := o.Value().(claimValue)
-			claimValues[claim.name] = claim.value
+			claimValues = addClaimValue(claimValues, o.Value().(claimValue))
+		case identValidator{}:
+			custom = append(custom, o.Value().(Validator))
+		case identResetDefaultValidators{}:
+			resetDefaultValidators = o.Value().(bool)
+		case identCollectAllErrors{}:
+			collectAll = o.Value().(bool)
 		}
 	}
 
-	for c := range requiredMap {
-		if _, ok := t.Get(c); !ok {
-			return errors.Errorf(`required claim %s was not found`, c)
-		}
+	var validators []Validator
+	for _, c := range required {
+		validators = append(validators, IsRequired(c))
 	}
+	validators = append(validators, deltas...)
 
-	for _, delta := range deltas {
-		// We don't check if the claims already exist, because we already did that
-		// by piggybacking on `required` check.
-		t1 := timeClaim(t, clock, delta.c1).Truncate(time.Second)
-		t2 := timeClaim(t, clock, delta.c2).Truncate(time.Second)
-		if delta.less { // t1 - t2 <= delta.dur
-			// t1 - t2 < delta.dur + skew
-			if t1.Sub(t2) > delta.dur+skew {
-				return errors.Errorf(`delta between %s and %s exceeds %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew)
-			}
-		} else {
-			if t1.Sub(t2) < delta.dur-skew {
-				return errors.Errorf(`delta between %s and %s is less than %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew)
-			}
-		}
-	}
-
-	// check for iss
 	if len(issuer) > 0 {
-		if v := t.Issuer(); v != issuer {
-			return errors.New(`iss not satisfied`)
-		}
+		validators = append(validators, ClaimValueIs(IssuerKey, issuer))
 	}
-
-	// check for jti
 	if len(jwtid) > 0 {
-		if v := t.JwtID(); v != jwtid {
-			return errors.New(`jti not satisfied`)
-		}
+		validators = append(validators, ClaimValueIs(JwtIDKey, jwtid))
 	}
-
-	// check for sub
 	if len(subject) > 0 {
-		if v := t.Subject(); v != subject {
-			return errors.New(`sub not satisfied`)
-		}
+		validators = append(validators, ClaimValueIs(SubjectKey, subject))
 	}
-
-	// check for aud
 	if len(audience) > 0 {
-		var found bool
-		for _, v := range t.Audience() {
-			if v == audience {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return errors.New(`aud not satisfied`)
-		}
+		validators = append(validators, ClaimContainsString(AudienceKey, audience))
 	}
 
-	// check for exp
-	if tv := t.Expiration(); !tv.IsZero() && tv.Unix() != 0 {
-		now := clock.Now().Truncate(time.Second)
-		ttv := tv.Truncate(time.Second)
-		if !now.Before(ttv.Add(skew)) {
-			return errors.New(`exp not satisfied`)
-		}
+	if !resetDefaultValidators {
+		validators = append(validators, IsExpirationValid(), IsIssuedAtValid(), IsNbfValid())
 	}
 
-	// check for iat
-	if tv := t.IssuedAt(); !tv.IsZero() && tv.Unix() != 0 {
-		now := clock.Now().Truncate(time.Second)
-		ttv := tv.Truncate(time.Second)
-		if now.Before(ttv.Add(-1 * skew)) {
-			return errors.New(`iat not satisfied`)
-		}
+	for _, claim := range claimValues {
+		validators = append(validators, ClaimValueIs(claim.name, claim.value))
 	}
+	validators = append(validators, custom...)
 
-	// check for nbf
-	if tv := t.NotBefore(); !tv.IsZero() && tv.Unix() != 0 {
-		now := clock.Now().Truncate(time.Second)
-		ttv := tv.Truncate(time.Second)
-		// now cannot be before t, so we check for now > t - skew
-		if !now.Equal(ttv) && !now.After(ttv.Add(-1*skew)) {
-			return errors.New(`nbf not satisfied`)
+	ctx = setValidationCtx(ctx, clock, skew)
+	var errs ValidationErrors
+	for _, v := range validators {
+		if err := v.Validate(ctx, t); err != nil {
+			if !collectAll {
+				return asValidationError(err)
+			}
+			errs = append(errs, asValidationError(err))
 		}
 	}
 
-	for name, expectedValue := range claimValues {
-		if v, ok := t.Get(name); !ok || v != expectedValue {
-			return fmt.Errorf(`%v not satisfied`, name)
-		}
+	if len(errs) > 0 {
+		return errs
 	}
-
 	return nil
 }
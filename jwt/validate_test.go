@@ -1,11 +1,13 @@
 package jwt_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
 	"github.com/lestrrat-go/jwx/internal/json"
 	"github.com/lestrrat-go/jwx/jwt"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -256,3 +258,463 @@ func TestGHIssue10(t *testing.T) {
 		}
 	})
 }
+
+func TestValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	clock := jwt.ClockFunc(func() time.Time { return now })
+
+	t1 := jwt.New()
+	t1.Set(jwt.IssuerKey, "github.com/lestrrat-go/jwx")
+	t1.Set(jwt.SubjectKey, "subject")
+	t1.Set(jwt.AudienceKey, []string{"audience"})
+	t1.Set(jwt.JwtIDKey, "jti")
+	t1.Set(jwt.IssuedAtKey, now.Add(-1*time.Hour))
+	t1.Set(jwt.NotBeforeKey, now.Add(-1*time.Hour))
+	t1.Set(jwt.ExpirationKey, now.Add(time.Hour))
+	t1.Set("email", "email@example.com")
+
+	testcases := []struct {
+		Name    string
+		Token   func() jwt.Token
+		Options []jwt.ValidateOption
+		Error   error
+		Claim   string
+	}{
+		{
+			Name:  "exp",
+			Token: func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{
+				jwt.WithClock(jwt.ClockFunc(func() time.Time { return now.Add(2 * time.Hour) })),
+			},
+			Error: jwt.ErrTokenExpired,
+			Claim: jwt.ExpirationKey,
+		},
+		{
+			Name: "nbf",
+			Token: func() jwt.Token {
+				tok := jwt.New()
+				tok.Set(jwt.NotBeforeKey, now.Add(time.Hour))
+				return tok
+			},
+			Options: []jwt.ValidateOption{jwt.WithClock(clock)},
+			Error:   jwt.ErrTokenNotYetValid,
+			Claim:   jwt.NotBeforeKey,
+		},
+		{
+			Name: "iat",
+			Token: func() jwt.Token {
+				tok := jwt.New()
+				tok.Set(jwt.IssuedAtKey, now.Add(time.Hour))
+				return tok
+			},
+			Options: []jwt.ValidateOption{jwt.WithClock(clock)},
+			Error:   jwt.ErrInvalidIssuedAt,
+			Claim:   jwt.IssuedAtKey,
+		},
+		{
+			Name:    "iss",
+			Token:   func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{jwt.WithClock(clock), jwt.WithIssuer("poop")},
+			Error:   jwt.ErrInvalidIssuer,
+			Claim:   jwt.IssuerKey,
+		},
+		{
+			Name:    "sub",
+			Token:   func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{jwt.WithClock(clock), jwt.WithSubject("poop")},
+			Error:   jwt.ErrInvalidSubject,
+			Claim:   jwt.SubjectKey,
+		},
+		{
+			Name:    "aud",
+			Token:   func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{jwt.WithClock(clock), jwt.WithAudience("poop")},
+			Error:   jwt.ErrInvalidAudience,
+			Claim:   jwt.AudienceKey,
+		},
+		{
+			Name:    "jti",
+			Token:   func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{jwt.WithClock(clock), jwt.WithJwtID("poop")},
+			Error:   jwt.ErrInvalidJwtID,
+			Claim:   jwt.JwtIDKey,
+		},
+		{
+			Name:    "required claim",
+			Token:   func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{jwt.WithClock(clock), jwt.WithRequiredClaim("xxxx")},
+			Error:   jwt.ErrMissingRequiredClaim{Name: "xxxx"},
+			Claim:   "xxxx",
+		},
+		{
+			Name:    "claim value",
+			Token:   func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{jwt.WithClock(clock), jwt.WithClaimValue("email", "poop")},
+			Error:   jwt.ErrInvalidClaimValue,
+			Claim:   "email",
+		},
+		{
+			Name:    "time delta",
+			Token:   func() jwt.Token { return t1 },
+			Options: []jwt.ValidateOption{jwt.WithClock(clock), jwt.WithMaxDelta(time.Minute, jwt.ExpirationKey, jwt.IssuedAtKey)},
+			Error:   jwt.ErrInvalidTimeDelta,
+			Claim:   jwt.ExpirationKey,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+			err := jwt.Validate(tc.Token(), tc.Options...)
+			if !assert.Error(t, err, `jwt.Validate should fail`) {
+				return
+			}
+
+			if !assert.True(t, errors.Is(err, tc.Error), `errors.Is(%s) should be true`, tc.Error) {
+				return
+			}
+
+			if !assert.True(t, jwt.IsValidationError(err), `jwt.IsValidationError should be true`) {
+				return
+			}
+
+			var verr *jwt.ValidationError
+			if !assert.True(t, errors.As(err, &verr), `errors.As should succeed`) {
+				return
+			}
+			if !assert.Equal(t, tc.Claim, verr.Claim, `claim names should match`) {
+				return
+			}
+		})
+	}
+
+	t.Run("ErrMissingRequiredClaim", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(t1, jwt.WithClock(clock), jwt.WithRequiredClaim("xxxx"))
+		if !assert.True(t, errors.Is(err, jwt.ErrMissingRequiredClaim{}), `errors.Is should match ErrMissingRequiredClaim without a name`) {
+			return
+		}
+		if !assert.False(t, errors.Is(err, jwt.ErrMissingRequiredClaim{Name: "yyyy"}), `errors.Is should not match ErrMissingRequiredClaim with a different name`) {
+			return
+		}
+
+		var merr jwt.ErrMissingRequiredClaim
+		if !assert.True(t, errors.As(err, &merr), `errors.As should succeed`) {
+			return
+		}
+		if !assert.Equal(t, "xxxx", merr.Name, `claim names should match`) {
+			return
+		}
+	})
+}
+
+func TestValidator(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	clock := jwt.ClockFunc(func() time.Time { return now })
+
+	t1 := jwt.New()
+	t1.Set(jwt.IssuerKey, "github.com/lestrrat-go/jwx")
+	t1.Set(jwt.ExpirationKey, now.Add(-1*time.Hour))
+	t1.Set("groups", []interface{}{"admin", "user"})
+
+	isAdmin := jwt.ValidatorFunc(func(_ context.Context, tok jwt.Token) error {
+		v, ok := tok.Get("groups")
+		if !ok {
+			return errors.New(`groups not found`)
+		}
+		list, ok := v.([]interface{})
+		if !ok {
+			return errors.New(`groups is not a list`)
+		}
+		for _, g := range list {
+			if g == "admin" {
+				return nil
+			}
+		}
+		return errors.New(`not an admin`)
+	})
+
+	t.Run("Custom validator", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(t1, jwt.WithClock(clock), jwt.WithAcceptableSkew(2*time.Hour), jwt.WithValidator(isAdmin))
+		if !assert.NoError(t, err, `jwt.Validate should succeed`) {
+			return
+		}
+
+		notUser := jwt.ValidatorFunc(func(_ context.Context, _ jwt.Token) error {
+			return errors.New(`custom validation failed`)
+		})
+		err = jwt.Validate(t1, jwt.WithClock(clock), jwt.WithAcceptableSkew(2*time.Hour), jwt.WithValidator(isAdmin), jwt.WithValidator(notUser))
+		if !assert.Error(t, err, `jwt.Validate should fail`) {
+			return
+		}
+		if !assert.Equal(t, `custom validation failed`, err.Error(), `error message should match`) {
+			return
+		}
+		if !assert.True(t, jwt.IsValidationError(err), `errors from custom validators should be validation errors`) {
+			return
+		}
+	})
+	t.Run("Context", func(t *testing.T) {
+		t.Parallel()
+		type ctxKey struct{}
+		ctx := context.WithValue(context.Background(), ctxKey{}, "expected")
+
+		var called bool
+		v := jwt.ValidatorFunc(func(ctx context.Context, _ jwt.Token) error {
+			called = true
+			if ctx.Value(ctxKey{}) != "expected" {
+				return errors.New(`context value not found`)
+			}
+			if !jwt.ValidationCtxClock(ctx).Now().Equal(now) {
+				return errors.New(`clock not found`)
+			}
+			if jwt.ValidationCtxSkew(ctx) != 2*time.Hour {
+				return errors.New(`skew not found`)
+			}
+			return nil
+		})
+		err := jwt.Validate(t1, jwt.WithContext(ctx), jwt.WithClock(clock), jwt.WithAcceptableSkew(2*time.Hour), jwt.WithValidator(v))
+		if !assert.NoError(t, err, `jwt.Validate should succeed`) {
+			return
+		}
+		if !assert.True(t, called, `validator should be called`) {
+			return
+		}
+	})
+	t.Run("Reset validators", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(t1, jwt.WithClock(clock))
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `token should be expired`) {
+			return
+		}
+
+		err = jwt.Validate(t1, jwt.WithClock(clock), jwt.WithResetDefaultValidators(true), jwt.WithValidator(isAdmin))
+		if !assert.NoError(t, err, `jwt.Validate should succeed when exp is not checked`) {
+			return
+		}
+
+		// Put the built-in validators back, in a different order
+		err = jwt.Validate(t1, jwt.WithClock(clock), jwt.WithResetDefaultValidators(true),
+			jwt.WithValidator(isAdmin),
+			jwt.WithValidator(jwt.IsNbfValid()),
+			jwt.WithValidator(jwt.IsExpirationValid()),
+		)
+		if !assert.True(t, errors.Is(err, jwt.ErrTokenExpired), `token should be expired`) {
+			return
+		}
+	})
+	t.Run("Built-in validators", func(t *testing.T) {
+		t.Parallel()
+		testcases := []struct {
+			Name      string
+			Validator jwt.Validator
+			Error     error
+		}{
+			{
+				Name:      "ClaimValueIs (success)",
+				Validator: jwt.ClaimValueIs(jwt.IssuerKey, "github.com/lestrrat-go/jwx"),
+			},
+			{
+				Name:      "ClaimValueIs (failure)",
+				Validator: jwt.ClaimValueIs(jwt.IssuerKey, "poop"),
+				Error:     jwt.ErrInvalidIssuer,
+			},
+			{
+				Name:      "ClaimContainsString (failure)",
+				Validator: jwt.ClaimContainsString(jwt.AudienceKey, "poop"),
+				Error:     jwt.ErrInvalidAudience,
+			},
+			{
+				Name:      "IsRequired (success)",
+				Validator: jwt.IsRequired("groups"),
+			},
+			{
+				Name:      "IsRequired (failure)",
+				Validator: jwt.IsRequired(jwt.SubjectKey),
+				Error:     jwt.ErrMissingRequiredClaim{Name: jwt.SubjectKey},
+			},
+			{
+				Name:      "MaxDeltaIs (failure)",
+				Validator: jwt.MaxDeltaIs("", jwt.ExpirationKey, time.Minute),
+				Error:     jwt.ErrInvalidTimeDelta,
+			},
+			{
+				Name:      "MinDeltaIs (success)",
+				Validator: jwt.MinDeltaIs("", jwt.ExpirationKey, time.Minute),
+			},
+			{
+				Name:      "IsExpirationValid (failure)",
+				Validator: jwt.IsExpirationValid(),
+				Error:     jwt.ErrTokenExpired,
+			},
+		}
+
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				t.Parallel()
+				err := jwt.Validate(t1, jwt.WithClock(clock), jwt.WithResetDefaultValidators(true), jwt.WithValidator(tc.Validator))
+				if tc.Error == nil {
+					if !assert.NoError(t, err, `jwt.Validate should succeed`) {
+						return
+					}
+					return
+				}
+				if !assert.True(t, errors.Is(err, tc.Error), `errors.Is(%s) should be true (got %v)`, tc.Error, err) {
+					return
+				}
+			})
+		}
+	})
+	t.Run("Parse", func(t *testing.T) {
+		t.Parallel()
+		t2 := jwt.New()
+		t2.Set("groups", []interface{}{"user"})
+		buf, err := json.Marshal(t2)
+		if !assert.NoError(t, err, `json.Marshal should succeed`) {
+			return
+		}
+
+		_, err = jwt.Parse(buf, jwt.WithValidate(true), jwt.WithValidator(isAdmin))
+		if !assert.Error(t, err, `jwt.Parse should fail`) {
+			return
+		}
+		if !assert.True(t, jwt.IsValidationError(err), `error should be a validation error`) {
+			return
+		}
+	})
+}
+
+func TestCollectAllErrors(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	clock := jwt.ClockFunc(func() time.Time { return now })
+
+	t1 := jwt.New()
+	t1.Set(jwt.IssuerKey, "github.com/lestrrat-go/jwx")
+	t1.Set(jwt.AudienceKey, []string{"foo"})
+	t1.Set(jwt.ExpirationKey, now.Add(-1*time.Hour))
+
+	options := []jwt.ValidateOption{
+		jwt.WithClock(clock),
+		jwt.WithIssuer("poop"),
+		jwt.WithAudience("bar"),
+		jwt.WithRequiredClaim(jwt.SubjectKey),
+	}
+
+	t.Run("Without WithCollectAllErrors", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(t1, options...)
+		if !assert.Error(t, err, `jwt.Validate should fail`) {
+			return
+		}
+
+		var verrs jwt.ValidationErrors
+		if !assert.False(t, errors.As(err, &verrs), `error should not be jwt.ValidationErrors`) {
+			return
+		}
+	})
+	t.Run("With WithCollectAllErrors", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(t1, append(options, jwt.WithCollectAllErrors(true))...)
+		if !assert.Error(t, err, `jwt.Validate should fail`) {
+			return
+		}
+
+		var verrs jwt.ValidationErrors
+		if !assert.True(t, errors.As(err, &verrs), `error should be jwt.ValidationErrors`) {
+			return
+		}
+		if !assert.Len(t, verrs, 4, `there should be 4 errors`) {
+			return
+		}
+
+		for _, target := range []error{jwt.ErrMissingRequiredClaim{Name: jwt.SubjectKey}, jwt.ErrInvalidIssuer, jwt.ErrInvalidAudience, jwt.ErrTokenExpired} {
+			if !assert.True(t, errors.Is(err, target), `errors.Is(%s) should be true`, target) {
+				return
+			}
+		}
+		if !assert.False(t, errors.Is(err, jwt.ErrTokenNotYetValid), `errors.Is(jwt.ErrTokenNotYetValid) should be false`) {
+			return
+		}
+		if !assert.True(t, jwt.IsValidationError(err), `jwt.IsValidationError should be true`) {
+			return
+		}
+
+		expected := []struct {
+			Claim    string
+			Expected interface{}
+			Actual   interface{}
+		}{
+			{Claim: jwt.SubjectKey},
+			{Claim: jwt.IssuerKey, Expected: "poop", Actual: "github.com/lestrrat-go/jwx"},
+			{Claim: jwt.AudienceKey, Expected: "bar", Actual: []string{"foo"}},
+			{Claim: jwt.ExpirationKey, Expected: now, Actual: now.Add(-1 * time.Hour)},
+		}
+		for i, e := range expected {
+			if !assert.Equal(t, e.Claim, verrs[i].Claim, `claim names should match`) {
+				return
+			}
+			if tm, ok := e.Expected.(time.Time); ok {
+				if !assert.True(t, tm.Equal(verrs[i].Expected.(time.Time)), `expected values should match`) {
+					return
+				}
+				if !assert.True(t, e.Actual.(time.Time).Equal(verrs[i].Actual.(time.Time)), `actual values should match`) {
+					return
+				}
+				continue
+			}
+			if !assert.Equal(t, e.Expected, verrs[i].Expected, `expected values should match`) {
+				return
+			}
+			if !assert.Equal(t, e.Actual, verrs[i].Actual, `actual values should match`) {
+				return
+			}
+		}
+
+		msg := err.Error()
+		for _, s := range []string{`iss: iss not satisfied (expected "poop", actual "github.com/lestrrat-go/jwx")`, `aud: aud not satisfied (expected "bar", actual [foo])`, `sub: required claim sub was not found`} {
+			if !assert.Contains(t, msg, s, `error message should contain %q`, s) {
+				return
+			}
+		}
+	})
+	t.Run("Claim values are checked in order", func(t *testing.T) {
+		t.Parallel()
+		claims := []string{"z", "a", "m", "b", "y", "c"}
+		var options []jwt.ValidateOption
+		for _, name := range claims {
+			options = append(options, jwt.WithClaimValue(name, "expected"))
+		}
+		options = append(options, jwt.WithClock(clock), jwt.WithResetDefaultValidators(true), jwt.WithCollectAllErrors(true))
+
+		// Run this a few times, as the order used to depend on map iteration
+		for i := 0; i < 10; i++ {
+			var verrs jwt.ValidationErrors
+			if !assert.True(t, errors.As(jwt.Validate(t1, options...), &verrs), `error should be jwt.ValidationErrors`) {
+				return
+			}
+			var names []string
+			for _, verr := range verrs {
+				names = append(names, verr.Claim)
+			}
+			if !assert.Equal(t, claims, names, `errors should be in option order`) {
+				return
+			}
+		}
+	})
+	t.Run("Valid token", func(t *testing.T) {
+		t.Parallel()
+		err := jwt.Validate(t1, jwt.WithClock(jwt.ClockFunc(func() time.Time { return now.Add(-2 * time.Hour) })), jwt.WithCollectAllErrors(true))
+		if !assert.NoError(t, err, `jwt.Validate should succeed`) {
+			return
+		}
+	})
+}
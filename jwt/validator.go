@@ -0,0 +1,247 @@
+package jwt
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Validator describes an object that can validate a token. Validators
+// can be passed to `jwt.Validate()` (and `jwt.Parse()`) using
+// `jwt.WithValidator()`.
+//
+// The context object passed to Validate contains the clock and the
+// acceptable skew specified by `jwt.WithClock()` and
+// `jwt.WithAcceptableSkew()`, which can be retrieved using
+// `jwt.ValidationCtxClock()` and `jwt.ValidationCtxSkew()`.
+type Validator interface {
+	Validate(context.Context, Token) error
+}
+
+// ValidatorFunc is a Validator represented by a function
+type ValidatorFunc func(context.Context, Token) error
+
+func (f ValidatorFunc) Validate(ctx context.Context, t Token) error {
+	return f(ctx, t)
+}
+
+type identValidationCtxClock struct{}
+type identValidationCtxSkew struct{}
+
+// ValidationCtxClock returns the Clock object associated with
+// the validation context. If none is associated, a Clock that
+// returns the result of `time.Now()` is returned.
+func ValidationCtxClock(ctx context.Context) Clock {
+	if v, ok := ctx.Value(identValidationCtxClock{}).(Clock); ok {
+		return v
+	}
+	return ClockFunc(time.Now)
+}
+
+// ValidationCtxSkew returns the acceptable skew associated with
+// the validation context.
+func ValidationCtxSkew(ctx context.Context) time.Duration {
+	if v, ok := ctx.Value(identValidationCtxSkew{}).(time.Duration); ok {
+		return v
+	}
+	return 0
+}
+
+func setValidationCtx(ctx context.Context, clock Clock, skew time.Duration) context.Context {
+	ctx = context.WithValue(ctx, identValidationCtxClock{}, clock)
+	return context.WithValue(ctx, identValidationCtxSkew{}, skew)
+}
+
+// IsExpirationValid returns a Validator that checks that the "exp"
+// claim, if present, is later than the current time.
+// It is one of the validators that `jwt.Validate()` uses by default.
+func IsExpirationValid() Validator {
+	return ValidatorFunc(isExpirationValid)
+}
+
+func isExpirationValid(ctx context.Context, t Token) error {
+	if tv := t.Expiration(); !tv.IsZero() && tv.Unix() != 0 {
+		now := ValidationCtxClock(ctx).Now().Truncate(time.Second)
+		ttv := tv.Truncate(time.Second)
+		if !now.Before(ttv.Add(ValidationCtxSkew(ctx))) {
+			return newValidationError(ExpirationKey, ErrTokenExpired).withValues(now, tv)
+		}
+	}
+	return nil
+}
+
+// IsIssuedAtValid returns a Validator that checks that the "iat"
+// claim, if present, is not later than the current time.
+// It is one of the validators that `jwt.Validate()` uses by default.
+func IsIssuedAtValid() Validator {
+	return ValidatorFunc(isIssuedAtValid)
+}
+
+func isIssuedAtValid(ctx context.Context, t Token) error {
+	if tv := t.IssuedAt(); !tv.IsZero() && tv.Unix() != 0 {
+		now := ValidationCtxClock(ctx).Now().Truncate(time.Second)
+		ttv := tv.Truncate(time.Second)
+		if now.Before(ttv.Add(-1 * ValidationCtxSkew(ctx))) {
+			return newValidationError(IssuedAtKey, ErrInvalidIssuedAt).withValues(now, tv)
+		}
+	}
+	return nil
+}
+
+// IsNbfValid returns a Validator that checks that the "nbf"
+// claim, if present, is not later than the current time.
+// It is one of the validators that `jwt.Validate()` uses by default.
+func IsNbfValid() Validator {
+	return ValidatorFunc(isNbfValid)
+}
+
+func isNbfValid(ctx context.Context, t Token) error {
+	if tv := t.NotBefore(); !tv.IsZero() && tv.Unix() != 0 {
+		now := ValidationCtxClock(ctx).Now().Truncate(time.Second)
+		ttv := tv.Truncate(time.Second)
+		// now cannot be before t, so we check for now > t - skew
+		if !now.Equal(ttv) && !now.After(ttv.Add(-1*ValidationCtxSkew(ctx))) {
+			return newValidationError(NotBeforeKey, ErrTokenNotYetValid).withValues(now, tv)
+		}
+	}
+	return nil
+}
+
+// IsRequired returns a Validator that checks that the claim `name`
+// exists in the token. This is the validator used by
+// `jwt.WithRequiredClaim()`.
+func IsRequired(name string) Validator {
+	return ValidatorFunc(func(_ context.Context, t Token) error {
+		if _, ok := t.Get(name); !ok {
+			return newValidationError(name, ErrMissingRequiredClaim{Name: name})
+		}
+		return nil
+	})
+}
+
+// claimValueError returns the error that is reported when the value
+// of the claim `name` does not match the expected value
+func claimValueError(name string) *ValidationError {
+	switch name {
+	case IssuerKey:
+		return newValidationError(name, ErrInvalidIssuer)
+	case SubjectKey:
+		return newValidationError(name, ErrInvalidSubject)
+	case JwtIDKey:
+		return newValidationError(name, ErrInvalidJwtID)
+	case AudienceKey:
+		return newValidationError(name, ErrInvalidAudience)
+	default:
+		return newValidationErrorf(name, ErrInvalidClaimValue, `%v not satisfied`, name)
+	}
+}
+
+// ClaimValueIs returns a Validator that checks that the value of the
+// claim `name` is equal to `value`, using the `!=` operator.
+// This is the validator used by `jwt.WithIssuer()`, `jwt.WithSubject()`,
+// `jwt.WithJwtID()`, and `jwt.WithClaimValue()`.
+//
+// Values such as slices and maps cannot be compared this way:
+// use a custom Validator for them.
+func ClaimValueIs(name string, value interface{}) Validator {
+	return ValidatorFunc(func(_ context.Context, t Token) error {
+		if v, ok := t.Get(name); !ok || v != value {
+			return claimValueError(name).withValues(value, v)
+		}
+		return nil
+	})
+}
+
+// ClaimContainsString returns a Validator that checks that the claim
+// `name` is a list of strings that contains `value`, or a string that
+// is equal to `value`. This is the validator used by `jwt.WithAudience()`.
+func ClaimContainsString(name, value string) Validator {
+	return ValidatorFunc(func(_ context.Context, t Token) error {
+		v, ok := t.Get(name)
+		if !ok {
+			return claimValueError(name).withValues(value, nil)
+		}
+
+		switch v := v.(type) {
+		case []string:
+			for _, s := range v {
+				if s == value {
+					return nil
+				}
+			}
+		case string:
+			if v == value {
+				return nil
+			}
+		}
+		return claimValueError(name).withValues(value, v)
+	})
+}
+
+// MaxDeltaIs returns a Validator that checks that the difference
+// between the time claims `c1` and `c2` is less than or equal to `dur`.
+// This is the validator used by `jwt.WithMaxDelta()`, and the
+// same rules regarding the claims apply.
+func MaxDeltaIs(c1, c2 string, dur time.Duration) Validator {
+	return &deltaValidator{delta{c1: c1, c2: c2, dur: dur, less: true}}
+}
+
+// MinDeltaIs returns a Validator that checks that the difference
+// between the time claims `c1` and `c2` is greater than or equal to `dur`.
+// This is the validator used by `jwt.WithMinDelta()`, and the
+// same rules regarding the claims apply.
+func MinDeltaIs(c1, c2 string, dur time.Duration) Validator {
+	return &deltaValidator{delta{c1: c1, c2: c2, dur: dur, less: false}}
+}
+
+type deltaValidator struct {
+	delta delta
+}
+
+func (v *deltaValidator) Validate(ctx context.Context, t Token) error {
+	delta := v.delta
+	for _, c := range []string{delta.c1, delta.c2} {
+		if c == "" {
+			continue
+		}
+		if err := isSupportedTimeClaim(c); err != nil {
+			return err
+		}
+		if _, ok := t.Get(c); !ok {
+			return newValidationError(c, ErrMissingRequiredClaim{Name: c})
+		}
+	}
+
+	clock := ValidationCtxClock(ctx)
+	skew := ValidationCtxSkew(ctx)
+	t1 := timeClaim(t, clock, delta.c1).Truncate(time.Second)
+	t2 := timeClaim(t, clock, delta.c2).Truncate(time.Second)
+	if delta.less { // t1 - t2 <= delta.dur
+		// t1 - t2 < delta.dur + skew
+		if t1.Sub(t2) > delta.dur+skew {
+			return newValidationErrorf(delta.claim(), ErrInvalidTimeDelta, `delta between %s and %s exceeds %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew).withValues(delta.dur, t1.Sub(t2))
+		}
+	} else {
+		if t1.Sub(t2) < delta.dur-skew {
+			return newValidationErrorf(delta.claim(), ErrInvalidTimeDelta, `delta between %s and %s is less than %s (skew %s)`, delta.c1, delta.c2, delta.dur, skew).withValues(delta.dur, t1.Sub(t2))
+		}
+	}
+	return nil
+}
+
+// asValidationError makes sure that errors returned from validators
+// can be recognized by IsValidationError. Validators may wrap the errors
+// returned by the built-in validators, in which case the claim and the
+// values are taken from the wrapped error.
+func asValidationError(err error) *ValidationError {
+	if verr, ok := err.(*ValidationError); ok {
+		return verr
+	}
+
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		return newValidationError(verr.Claim, err).withValues(verr.Expected, verr.Actual)
+	}
+	return newValidationError("", err)
+}
@@ -0,0 +1,134 @@
+package x448
+
+import (
+	"bytes"
+	"crypto"
+	cryptorand "crypto/rand"
+	"io"
+
+	"github.com/cloudflare/circl/dh/x448"
+	"github.com/pkg/errors"
+)
+
+// This mirrors the structure of the x25519 package in this module.
+// jwx requires dedicated types for these as they drive
+// serialization/deserialization logic, as well as encryption types.
+//
+// Note that with the x448 scheme, the private key is a sequence of
+// 56 bytes, while the public key is the result of X448(private,
+// basepoint).
+
+const (
+	// PublicKeySize is the size, in bytes, of public keys as used in this package.
+	PublicKeySize = x448.Size
+	// PrivateKeySize is the size, in bytes, of private keys as used in this package.
+	PrivateKeySize = 2 * x448.Size
+	// SeedSize is the size, in bytes, of private key seeds. These are the private key representations used by RFC 7748.
+	SeedSize = x448.Size
+)
+
+// PublicKey is the type of X448 public keys
+type PublicKey []byte
+
+// Any methods implemented on PublicKey might need to also be implemented on
+// PrivateKey, as the latter embeds the former and will expose its methods.
+
+// Equal reports whether pub and x have the same value.
+func (pub PublicKey) Equal(x crypto.PublicKey) bool {
+	xx, ok := x.(PublicKey)
+	if !ok {
+		return false
+	}
+	return bytes.Equal(pub, xx)
+}
+
+// PrivateKey is the type of X448 private key
+type PrivateKey []byte
+
+// Public returns the PublicKey corresponding to priv.
+func (priv PrivateKey) Public() crypto.PublicKey {
+	publicKey := make([]byte, PublicKeySize)
+	copy(publicKey, priv[SeedSize:])
+	return PublicKey(publicKey)
+}
+
+// Equal reports whether priv and x have the same value.
+func (priv PrivateKey) Equal(x crypto.PrivateKey) bool {
+	xx, ok := x.(PrivateKey)
+	if !ok {
+		return false
+	}
+	return bytes.Equal(priv, xx)
+}
+
+// Seed returns the private key seed corresponding to priv. It is provided for
+// interoperability with RFC 7748. RFC 7748's private keys correspond to seeds
+// in this package.
+func (priv PrivateKey) Seed() []byte {
+	seed := make([]byte, SeedSize)
+	copy(seed, priv[:SeedSize])
+	return seed
+}
+
+// NewKeyFromSeed calculates a private key from a seed. It will return
+// an error if len(seed) is not SeedSize. This function is provided
+// for interoperability with RFC 7748. RFC 7748's private keys
+// correspond to seeds in this package.
+func NewKeyFromSeed(seed []byte) (PrivateKey, error) {
+	if len(seed) != SeedSize {
+		return nil, errors.Errorf("unexpected seed size: %d", len(seed))
+	}
+
+	var secret, public x448.Key
+	copy(secret[:], seed)
+	x448.KeyGen(&public, &secret)
+
+	privateKey := make([]byte, PrivateKeySize)
+	copy(privateKey, seed)
+	copy(privateKey[SeedSize:], public[:])
+	return privateKey, nil
+}
+
+// GenerateKey generates a public/private key pair using entropy from rand.
+// If rand is nil, crypto/rand.Reader will be used.
+func GenerateKey(rand io.Reader) (PublicKey, PrivateKey, error) {
+	if rand == nil {
+		rand = cryptorand.Reader
+	}
+
+	seed := make([]byte, SeedSize)
+	if _, err := io.ReadFull(rand, seed); err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := NewKeyFromSeed(seed)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey := make([]byte, PublicKeySize)
+	copy(publicKey, privateKey[SeedSize:])
+
+	return publicKey, privateKey, nil
+}
+
+// X448 computes the shared secret between the private key represented
+// by `seed` and the public key `public`, as described in RFC 7748.
+// It returns an error if either of the inputs are of the wrong size,
+// or if the result is the all-zero value, which happens when `public`
+// is a low-order point.
+func X448(seed []byte, public []byte) ([]byte, error) {
+	if len(seed) != SeedSize {
+		return nil, errors.Errorf("unexpected seed size: %d", len(seed))
+	}
+	if len(public) != PublicKeySize {
+		return nil, errors.Errorf("unexpected public key size: %d", len(public))
+	}
+
+	var secret, pub, shared x448.Key
+	copy(secret[:], seed)
+	copy(pub[:], public)
+	if !x448.Shared(&shared, &secret, &pub) {
+		return nil, errors.New("bad input point: low order point")
+	}
+	return shared[:], nil
+}